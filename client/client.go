@@ -16,24 +16,50 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
-	"strings"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/entertainment-venue/sm/pkg/apputil"
+	"github.com/entertainment-venue/sm/pkg/apputil/core"
+	"github.com/entertainment-venue/sm/pkg/apputil/receiver"
 	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/entertainment-venue/sm/pkg/commonutil"
 	"github.com/entertainment-venue/sm/pkg/logutil"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 type Client struct {
-	stopper   *apputil.GoroutineStopper
+	stopper   *commonutil.GoroutineStopper
 	lg        *zap.Logger
 	container *apputil.Container
 	opts      *clientOptions
+
+	// receiver 挂接在app的gin router或者gRPC server上，container重建时复用，防止重复注册路由
+	receiver receiver.Receiver
+
+	// donec Shutdown 或者 Close 之后关闭，app处理退出信号时使用
+	donec     chan struct{}
+	closeOnce sync.Once
+}
+
+// defaultShutdownTimeout 收到退出信号后等待shard迁移的时间
+const defaultShutdownTimeout = 30 * time.Second
+
+// shardHandler receiver中处理shard请求的部分，Client 的AddShard和DropShard转发给它
+type shardHandler interface {
+	receiver.Receiver
+
+	AddShard(c *gin.Context)
+	DropShard(c *gin.Context)
 }
 
 type clientOptions struct {
@@ -43,6 +69,13 @@ type clientOptions struct {
 	etcdPrefix  string
 	etcdAddr    []string
 	v           apputil.ShardInterface
+	// v2 优先于v使用
+	v2 core.ShardPrimitivesV2
+	// etcd开启RBAC时使用
+	etcdUsername string
+	etcdPassword string
+	// 访问etcd使用的tls配置
+	etcdTLSConfig *tls.Config
 	// shard.db存储路径，默认是当前路径
 	shardDir string
 	// 日志存储路径
@@ -55,13 +88,32 @@ type clientOptions struct {
 	dropExpiredShard bool
 	// 持久存储的类型，默认是boltdb
 	storageType storage.StorageType
+	// 拉模式从etcd获取指令，不需要gin router，containerId也不需要是host:port
+	pullMode bool
+	// 兼容还没有开始签名的server，见 apputil.WithAllowUnsigned
+	allowUnsigned bool
+	// 使用app的gRPC server接收指令，不需要gin router，service的spec中receiver需要配置为grpc
+	grpcServer *grpc.Server
+	// 同时调用app实现的数量和每秒最多启动的shard数量，见 apputil.WithSyncConcurrency
+	syncConcurrency int
+	syncRate        int
+	// 心跳中container负载的采集方式，默认使用gopsutil，见 apputil.WithLoadReporter
+	loadReporter apputil.LoadReporter
+	// 心跳格式和紧凑格式下全量心跳的间隔，见 apputil.WithHeartbeatFormat
+	heartbeatFormat           apputil.HeartbeatFormat
+	heartbeatSnapshotInterval int
+	// 收到SIGINT或者SIGTERM时调用 Client.Shutdown ，app通过 Client.Done 等待退出
+	handleSignal bool
+	// Shutdown 等待shard迁移的时间
+	shutdownTimeout time.Duration
 }
 
 var defaultClientOptions = &clientOptions{
-	etcdPrefix:  "/sm",
-	logPath:     "./log",
-	logConsole:  false,
-	storageType: storage.Etcd,
+	etcdPrefix:      "/sm",
+	logPath:         "./log",
+	logConsole:      false,
+	storageType:     storage.Etcd,
+	shutdownTimeout: defaultShutdownTimeout,
 }
 
 type ClientOption func(options *clientOptions)
@@ -96,12 +148,38 @@ func ClientWithEtcdAddr(etcdAddr []string) ClientOption {
 	}
 }
 
+func ClientWithEtcdUsername(v string) ClientOption {
+	return func(co *clientOptions) {
+		co.etcdUsername = v
+	}
+}
+
+func ClientWithEtcdPassword(v string) ClientOption {
+	return func(co *clientOptions) {
+		co.etcdPassword = v
+	}
+}
+
+// ClientWithEtcdTLSConfig 可以通过 etcdutil.NewTLSConfig 从证书文件生成
+func ClientWithEtcdTLSConfig(v *tls.Config) ClientOption {
+	return func(co *clientOptions) {
+		co.etcdTLSConfig = v
+	}
+}
+
 func ClientWithImplementation(v apputil.ShardInterface) ClientOption {
 	return func(co *clientOptions) {
 		co.v = v
 	}
 }
 
+// ClientWithImplementationV2 app需要调用超时和错误分类时使用，见 core.ShardPrimitivesV2
+func ClientWithImplementationV2(v core.ShardPrimitivesV2) ClientOption {
+	return func(co *clientOptions) {
+		co.v2 = v
+	}
+}
+
 func ClientWithShardDir(v string) ClientOption {
 	return func(co *clientOptions) {
 		co.shardDir = v
@@ -138,12 +216,75 @@ func WithStorageType(v storage.StorageType) ClientOption {
 	}
 }
 
+func ClientWithPullMode(v bool) ClientOption {
+	return func(co *clientOptions) {
+		co.pullMode = v
+	}
+}
+
+func ClientWithAllowUnsigned(v bool) ClientOption {
+	return func(co *clientOptions) {
+		co.allowUnsigned = v
+	}
+}
+
+func ClientWithSyncConcurrency(v int) ClientOption {
+	return func(co *clientOptions) {
+		co.syncConcurrency = v
+	}
+}
+
+func ClientWithSyncRate(v int) ClientOption {
+	return func(co *clientOptions) {
+		co.syncRate = v
+	}
+}
+
+func ClientWithLoadReporter(v apputil.LoadReporter) ClientOption {
+	return func(co *clientOptions) {
+		co.loadReporter = v
+	}
+}
+
+func ClientWithHeartbeatFormat(v apputil.HeartbeatFormat) ClientOption {
+	return func(co *clientOptions) {
+		co.heartbeatFormat = v
+	}
+}
+
+func ClientWithHeartbeatSnapshotInterval(v int) ClientOption {
+	return func(co *clientOptions) {
+		co.heartbeatSnapshotInterval = v
+	}
+}
+
+func ClientWithHandleSignal(v bool) ClientOption {
+	return func(co *clientOptions) {
+		co.handleSignal = v
+	}
+}
+
+func ClientWithShutdownTimeout(v time.Duration) ClientOption {
+	return func(co *clientOptions) {
+		co.shutdownTimeout = v
+	}
+}
+
+// ClientWithGRPCServer 需要在app的gRPC server启动之前调用 NewClient
+func ClientWithGRPCServer(v *grpc.Server) ClientOption {
+	return func(co *clientOptions) {
+		co.grpcServer = v
+	}
+}
+
 func NewClient(opts ...ClientOption) (*Client, error) {
-	ops := defaultClientOptions
+	// 复制默认值，避免多次调用 NewClient 时互相影响
+	o := *defaultClientOptions
+	ops := &o
 	for _, opt := range opts {
 		opt(ops)
 	}
-	if ops.g == nil {
+	if ops.g == nil && ops.grpcServer == nil && !ops.pullMode {
 		return nil, errors.New("gin router empty")
 	}
 	if ops.service == "" {
@@ -155,7 +296,7 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 	if ops.etcdAddr == nil {
 		return nil, errors.New("etcdAddr empty")
 	}
-	if ops.v == nil {
+	if ops.v == nil && ops.v2 == nil {
 		return nil, errors.New("impl empty")
 	}
 
@@ -163,11 +304,21 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "new zap logger failed")
 	}
+	// pkg中的日志和client输出到相同的路径
+	logutil.SetLogger(lg)
 
 	c := &Client{
-		stopper: &apputil.GoroutineStopper{},
+		stopper: &commonutil.GoroutineStopper{},
 		lg:      lg,
 		opts:    ops,
+		donec:   make(chan struct{}),
+	}
+	switch {
+	case ops.pullMode:
+	case ops.grpcServer != nil:
+		c.receiver = receiver.NewGRPCServerReceiver(ops.grpcServer, ops.containerId)
+	default:
+		c.receiver = receiver.NewGinReceiver(ops.g, ops.containerId)
 	}
 
 	if err := c.newServer(); err != nil {
@@ -178,23 +329,6 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 		return nil, err
 	}
 
-	// 是否需要跳过给router挂接口
-	var skip bool
-	for _, route := range ops.g.Routes() {
-		if strings.HasPrefix(route.Path, "/sm/admin") {
-			skip = true
-			break
-		}
-	}
-
-	if !skip {
-		ssg := ops.g.Group("/sm/admin")
-		{
-			ssg.POST("/add-shard", c.AddShard)
-			ssg.POST("/drop-shard", c.DropShard)
-		}
-	}
-
 	c.stopper.Wrap(
 		func(ctx context.Context) {
 			for {
@@ -224,17 +358,57 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 				}
 			}
 		})
+
+	if ops.handleSignal {
+		go c.handleSignal()
+	}
 	return c, nil
 }
 
+func (c *Client) handleSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	select {
+	case sig := <-sigs:
+		c.lg.Warn("Received exit signal", zap.String("sig", sig.String()))
+		ctx, cancel := context.WithTimeout(context.Background(), c.opts.shutdownTimeout)
+		defer cancel()
+		if err := c.Shutdown(ctx); err != nil {
+			c.lg.Error("Shutdown error",
+				zap.String("service", c.opts.service),
+				zap.Error(err),
+			)
+		}
+	case <-c.donec:
+	}
+}
+
 func (c *Client) newServer() error {
+	impl := c.opts.v2
+	if impl == nil {
+		impl = core.NewShardPrimitivesV2(c.opts.v)
+	}
+
+	// 拉模式的receiver由container创建，绑定container的session
 	container, err := apputil.NewContainer(
 		apputil.WithService(c.opts.service),
 		apputil.WithId(c.opts.containerId),
 		apputil.WithEndpoints(c.opts.etcdAddr),
 		apputil.WithEtcdPrefix(c.opts.etcdPrefix),
-		apputil.WithLogger(c.lg),
-		apputil.WithShardImplementation(c.opts.v),
+		apputil.WithEtcdUsername(c.opts.etcdUsername),
+		apputil.WithEtcdPassword(c.opts.etcdPassword),
+		apputil.WithEtcdTLSConfig(c.opts.etcdTLSConfig),
+		apputil.WithShardPrimitivesV2(impl),
+		apputil.WithReceiver(c.receiver),
+		apputil.WithPullMode(c.opts.pullMode),
+		apputil.WithAllowUnsigned(c.opts.allowUnsigned),
+		apputil.WithSyncConcurrency(c.opts.syncConcurrency),
+		apputil.WithSyncRate(c.opts.syncRate),
+		apputil.WithLoadReporter(c.opts.loadReporter),
+		apputil.WithHeartbeatFormat(c.opts.heartbeatFormat),
+		apputil.WithHeartbeatSnapshotInterval(c.opts.heartbeatSnapshotInterval),
 		apputil.WithShardDir(c.opts.shardDir),
 		apputil.WithDropExpiredShard(c.opts.dropExpiredShard),
 		apputil.WithStorageType(c.opts.storageType))
@@ -253,22 +427,44 @@ func (c *Client) newServer() error {
 	return nil
 }
 
+// ReportShardState app加载shard较慢时，在Add中上报 storage.ShardStateStarting ，加载完成后上报 storage.ShardStateServing ，
+// 不上报时Add返回即认为可以提供服务
+func (c *Client) ReportShardState(id string, state storage.ShardState) error {
+	return c.container.ReportShardState(id, state)
+}
+
+// AddShard 只有gin receiver支持，和receiver注册的接口一样校验leader的签名
 func (c *Client) AddShard(g *gin.Context) {
-	if c.container != nil {
-		c.container.AddShard(g)
-	} else {
-		c.lg.Error("Container is nil or not initialized")
-		g.JSON(http.StatusInternalServerError, gin.H{"error": errors.New("Container is nil or not initialized")})
+	h, ok := c.receiver.(shardHandler)
+	if !ok {
+		g.JSON(http.StatusNotFound, gin.H{"error": "gin receiver not used"})
+		return
 	}
+	h.AddShard(g)
 }
 
 func (c *Client) DropShard(g *gin.Context) {
-	if c.container != nil {
-		c.container.DropShard(g)
-	} else {
-		c.lg.Error("Container is nil or not initialized")
-		g.JSON(http.StatusInternalServerError, gin.H{"error": errors.New("Container is nil or not initialized")})
+	h, ok := c.receiver.(shardHandler)
+	if !ok {
+		g.JSON(http.StatusNotFound, gin.H{"error": "gin receiver not used"})
+		return
+	}
+	h.DropShard(g)
+}
+
+// Shutdown 优雅退出，通知sm迁移shard，ctx结束之前等待迁移完成，见 apputil.Container.Shutdown
+func (c *Client) Shutdown(ctx context.Context) error {
+	// 先停止container的重建
+	if c.stopper != nil {
+		c.stopper.Close()
 	}
+	defer c.closeOnce.Do(func() { close(c.donec) })
+	return c.container.Shutdown(ctx)
+}
+
+// Done Shutdown 或者 Close 之后关闭
+func (c *Client) Done() <-chan struct{} {
+	return c.donec
 }
 
 // Close Client关闭以后，gin.Router不能重用。
@@ -277,4 +473,5 @@ func (c *Client) Close() {
 		c.stopper.Close()
 	}
 	c.container.Close()
+	c.closeOnce.Do(func() { close(c.donec) })
 }
@@ -1,11 +1,14 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/entertainment-venue/sm/pkg/apputil/core"
 	"github.com/entertainment-venue/sm/pkg/apputil/storage"
 	"github.com/gin-gonic/gin"
 )
@@ -28,6 +31,28 @@ func TestNewClient(t *testing.T) {
 	_ = ginSrv.Run(fmt.Sprintf(":%d", port))
 }
 
+// TestNewClient_implementationV2 依赖本地127.0.0.1:2379的etcd，只提供 core.ShardPrimitivesV2 也可以创建Client
+func TestNewClient_implementationV2(t *testing.T) {
+	opts := []ClientOption{
+		ClientWithPullMode(true),
+		ClientWithContainerId("127.0.0.1:8889"),
+		ClientWithEtcdAddr([]string{"127.0.0.1:2379"}),
+		ClientWithService("test-service"),
+		ClientWithLogPath(t.TempDir()),
+	}
+	if _, err := NewClient(opts...); err == nil || err.Error() != "impl empty" {
+		t.Fatalf("expect impl empty, got %v", err)
+	}
+
+	c, err := NewClient(append(opts, ClientWithImplementationV2(new(core.MockedShardPrimitivesV2)))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), 3*time.Second)
+	defer cancel()
+	c.Shutdown(ctx)
+}
+
 type testShard struct {
 	lock sync.Mutex
 	ids  map[string]string
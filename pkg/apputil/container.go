@@ -16,9 +16,12 @@ package apputil
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/entertainment-venue/sm/pkg/apputil/core"
@@ -28,15 +31,14 @@ import (
 	"github.com/entertainment-venue/sm/pkg/etcdutil"
 	"github.com/entertainment-venue/sm/pkg/logutil"
 	"github.com/pkg/errors"
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
-	"github.com/shirou/gopsutil/v3/mem"
-	"github.com/shirou/gopsutil/v3/net"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/concurrency"
 	"go.uber.org/zap"
 )
 
+// ShardInterface 保留给 client.ClientWithImplementation 等已有调用方，与 core.ShardPrimitives 相同
+type ShardInterface = core.ShardPrimitives
+
 // Container 1 上报container的load信息，保证container的liveness，才能够参与shard的分配
 // 2 与sm交互，下发add和drop给到Shard
 type Container struct {
@@ -58,6 +60,17 @@ type Container struct {
 
 	// opts 存储初始化传入的数据
 	opts *containerOptions
+
+	// paths 根据etcdPrefix生成etcd路径，不同的container可以属于不同的sm集群
+	paths *etcdutil.PathBuilder
+
+	// hbEncoder 紧凑格式下记录上次的全量心跳，生成增量心跳
+	hbEncoder *heartbeatEncoder
+	// hbMu Shutdown 和心跳goroutine都会调用heartbeat
+	hbMu sync.Mutex
+
+	// leaving 不为0时 Container 正在优雅退出，通过心跳通知sm迁移shard
+	leaving int32
 }
 
 type containerOptions struct {
@@ -68,12 +81,26 @@ type containerOptions struct {
 	id      string
 	service string
 
-	appShardImpl core.ShardPrimitives
+	appShardImpl core.ShardPrimitivesV2
+
+	// shardCallTimeout 单次调用 appShardImpl 的超时时间，默认 core.DefaultCallTimeout
+	shardCallTimeout time.Duration
+
+	// syncConcurrency 同时调用 appShardImpl 的数量，默认串行
+	syncConcurrency int
+	// syncRate 每秒最多add或update的shard数量，默认不限制
+	syncRate int
 
 	// etcdPrefix 作为sharded application的数据存储prefix，能通过acl做限制
-	// TODO 配合 etcdPrefix 需要有用户名和密码的字段
 	etcdPrefix string
 
+	// etcdUsername和etcdPassword 配合etcd RBAC，把app的访问限制在 etcdPrefix 下
+	etcdUsername string
+	etcdPassword string
+
+	// etcdTLSConfig 访问etcd使用的tls配置，client由外部传入时不生效
+	etcdTLSConfig *tls.Config
+
 	// client 允许外部传入
 	client *clientv3.Client
 	// shardDir shard.db的存储路径，默认是当前目录
@@ -87,6 +114,23 @@ type containerOptions struct {
 
 	// receiver 允许业务方设置，不传递，可以通过opts选择默认的http receiver
 	receiver receiver.Receiver
+
+	// tlsConfig 默认的http receiver使用，不为空时开启https
+	tlsConfig *tls.Config
+
+	// pullMode 使用etcd receiver，container从etcd拉取指令，不需要addr
+	pullMode bool
+
+	// allowUnsigned 兼容还没有开始签名的server，见 receiver.Verifier.SetAllowUnsigned
+	allowUnsigned bool
+
+	// loadReporter 采集heartbeat中的负载，默认使用gopsutil
+	loadReporter LoadReporter
+
+	// heartbeatFormat 默认 HeartbeatFull ，紧凑格式需要smserver支持
+	heartbeatFormat HeartbeatFormat
+	// heartbeatSnapshotInterval 紧凑格式下全量心跳的间隔次数，默认10
+	heartbeatSnapshotInterval int
 }
 
 type ContainerOption func(options *containerOptions)
@@ -109,12 +153,38 @@ func WithEndpoints(v []string) ContainerOption {
 	}
 }
 
+// WithShardPrimitives 兼容旧的实现，通过 core.NewShardPrimitivesV2 转换
 func WithShardPrimitives(v core.ShardPrimitives) ContainerOption {
+	return func(co *containerOptions) {
+		co.appShardImpl = core.NewShardPrimitivesV2(v)
+	}
+}
+
+func WithShardPrimitivesV2(v core.ShardPrimitivesV2) ContainerOption {
 	return func(co *containerOptions) {
 		co.appShardImpl = v
 	}
 }
 
+func WithShardCallTimeout(v time.Duration) ContainerOption {
+	return func(co *containerOptions) {
+		co.shardCallTimeout = v
+	}
+}
+
+// WithSyncConcurrency appShardImpl 支持并发调用时开启，container重启后大量shard可以并行启动
+func WithSyncConcurrency(v int) ContainerOption {
+	return func(co *containerOptions) {
+		co.syncConcurrency = v
+	}
+}
+
+func WithSyncRate(v int) ContainerOption {
+	return func(co *containerOptions) {
+		co.syncRate = v
+	}
+}
+
 func WithAddr(v string) ContainerOption {
 	return func(co *containerOptions) {
 		co.addr = v
@@ -127,6 +197,24 @@ func WithEtcdPrefix(v string) ContainerOption {
 	}
 }
 
+func WithEtcdUsername(v string) ContainerOption {
+	return func(co *containerOptions) {
+		co.etcdUsername = v
+	}
+}
+
+func WithEtcdPassword(v string) ContainerOption {
+	return func(co *containerOptions) {
+		co.etcdPassword = v
+	}
+}
+
+func WithEtcdTLSConfig(v *tls.Config) ContainerOption {
+	return func(co *containerOptions) {
+		co.etcdTLSConfig = v
+	}
+}
+
 func WithEtcdClient(v *clientv3.Client) ContainerOption {
 	return func(co *containerOptions) {
 		co.client = v
@@ -151,6 +239,53 @@ func WithStorageType(v storage.StorageType) ContainerOption {
 	}
 }
 
+func WithReceiver(v receiver.Receiver) ContainerOption {
+	return func(co *containerOptions) {
+		co.receiver = v
+	}
+}
+
+func WithTLSConfig(v *tls.Config) ContainerOption {
+	return func(co *containerOptions) {
+		co.tlsConfig = v
+	}
+}
+
+// WithPullMode container watch自己在etcd中的指令队列，替代默认的http receiver，
+// 适用于container无法被server访问的场景，此时id不需要是host:port
+func WithPullMode(v bool) ContainerOption {
+	return func(co *containerOptions) {
+		co.pullMode = v
+	}
+}
+
+// WithAllowUnsigned 升级过程中server还没有下发签名时开启，container见到secret之后仍然拒绝未签名的指令
+func WithAllowUnsigned(v bool) ContainerOption {
+	return func(co *containerOptions) {
+		co.allowUnsigned = v
+	}
+}
+
+// WithLoadReporter 容器环境中可以使用 NewCgroupLoadReporter ，也可以通过 LoadReporterFunc 自己采集
+func WithLoadReporter(v LoadReporter) ContainerOption {
+	return func(co *containerOptions) {
+		co.loadReporter = v
+	}
+}
+
+// WithHeartbeatFormat shard数量较多时使用紧凑格式，减少etcd的写入量
+func WithHeartbeatFormat(v HeartbeatFormat) ContainerOption {
+	return func(co *containerOptions) {
+		co.heartbeatFormat = v
+	}
+}
+
+func WithHeartbeatSnapshotInterval(v int) ContainerOption {
+	return func(co *containerOptions) {
+		co.heartbeatSnapshotInterval = v
+	}
+}
+
 func NewContainer(opts ...ContainerOption) (*Container, error) {
 	ops := &containerOptions{}
 	for _, opt := range opts {
@@ -169,15 +304,19 @@ func NewContainer(opts ...ContainerOption) (*Container, error) {
 	if ops.appShardImpl == nil {
 		return nil, errors.New("impl err")
 	}
-
-	// FIXME 直接刚常量有点粗糙，暂时没有更好的方案
-	etcdutil.SetPfx(ops.etcdPrefix)
+	if ops.loadReporter == nil {
+		ops.loadReporter = NewGopsutilLoadReporter()
+	}
 
 	// 允许传入etcd的client
 	var client *etcdutil.EtcdClient
 	if ops.client == nil {
 		var err error
-		client, err = etcdutil.NewEtcdClient(ops.endpoints)
+		client, err = etcdutil.NewEtcdClient(
+			ops.endpoints,
+			etcdutil.WithAuth(ops.etcdUsername, ops.etcdPassword),
+			etcdutil.WithTLSConfig(ops.etcdTLSConfig),
+		)
 		if err != nil {
 			return nil, errors.Wrap(err, "")
 		}
@@ -198,13 +337,21 @@ func NewContainer(opts ...ContainerOption) (*Container, error) {
 		Client:  client,
 		Session: session,
 		opts:    ops,
+		paths:   etcdutil.NewPathBuilder(ops.etcdPrefix),
 
 		stopper: &commonutil.GoroutineStopper{},
 		donec:   make(chan struct{}),
+
+		hbEncoder: newHeartbeatEncoder(ops.heartbeatFormat, ops.heartbeatSnapshotInterval),
+	}
+
+	// 拉模式的receiver依赖container的etcd client和session
+	if ops.receiver == nil && ops.pullMode {
+		c.opts.receiver = receiver.NewEtcdReceiver(client, c.paths, ops.service, ops.id, session.Lease())
 	}
 
 	// 提供默认的receiver
-	if ops.receiver == nil {
+	if c.opts.receiver == nil {
 		if ops.addr == "" {
 			err := errors.Errorf("Empty addr")
 			logutil.Error(
@@ -216,7 +363,9 @@ func NewContainer(opts ...ContainerOption) (*Container, error) {
 			return nil, errors.Wrap(err, "")
 		} else {
 			// TODO opts中的receiver在Close时用到
-			c.opts.receiver = receiver.NewHttpServer(ops.addr, ops.id)
+			r := receiver.NewHttpServer(ops.addr, ops.id)
+			r.SetTLSConfig(ops.tlsConfig)
+			c.opts.receiver = r
 		}
 	}
 	return &c, nil
@@ -232,7 +381,7 @@ func (ctr *Container) Run() error {
 	case storage.Boltdb:
 		st, err = storage.NewBoltdb(ctr.opts.shardDir, ctr.opts.service)
 	default:
-		st, err = storage.NewEtcddb(ctr.opts.service, ctr.opts.id, ctr.Client)
+		st, err = storage.NewEtcddb(ctr.opts.service, ctr.opts.id, ctr.Client, ctr.paths)
 	}
 	if err != nil {
 		return errors.Wrap(err, "")
@@ -245,6 +394,10 @@ func (ctr *Container) Run() error {
 		Client:           ctr.Client,
 		ShardDir:         ctr.opts.shardDir,
 		AppShardImpl:     ctr.opts.appShardImpl,
+		CallTimeout:      ctr.opts.shardCallTimeout,
+		SyncConcurrency:  ctr.opts.syncConcurrency,
+		SyncRate:         ctr.opts.syncRate,
+		Paths:            ctr.paths,
 	}
 	ctr.shardKeeper, err = core.NewShardKeeper(&skOpts, st)
 	if err != nil {
@@ -298,6 +451,21 @@ func (ctr *Container) Run() error {
 		}
 	}()
 
+	// 支持签名校验的receiver，只接受leader签名的指令
+	if v, ok := ctr.opts.receiver.(receiver.Verifiable); ok {
+		verifier, err := ctr.watchSecret()
+		if err != nil {
+			logutil.Error(
+				"watchSecret err",
+				zap.String("id", ctr.Id()),
+				zap.String("service", ctr.Service()),
+				zap.Error(err),
+			)
+			return errors.Wrap(err, "")
+		}
+		v.SetVerifier(verifier)
+	}
+
 	ctr.opts.receiver.SetShardPrimitives(ctr.shardKeeper)
 	if err := ctr.opts.receiver.Start(); err != nil {
 		logutil.Error(
@@ -311,6 +479,40 @@ func (ctr *Container) Run() error {
 	return nil
 }
 
+// watchSecret leader接管service时创建secret，container在secret变化时更新verifier
+func (ctr *Container) watchSecret() (*receiver.Verifier, error) {
+	key := ctr.paths.SecretPath(ctr.opts.service)
+	resp, err := ctr.Client.Get(context.TODO(), key)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	verifier := receiver.NewVerifier()
+	verifier.SetAllowUnsigned(ctr.opts.allowUnsigned)
+	if len(resp.Kvs) > 0 {
+		verifier.SetSecret(resp.Kvs[0].Value)
+	}
+
+	ctr.stopper.Wrap(
+		func(ctx context.Context) {
+			etcdutil.WatchLoop(
+				ctx,
+				ctr.Client,
+				key,
+				resp.Header.Revision+1,
+				func(ctx context.Context, ev *clientv3.Event) error {
+					if ev.Type == clientv3.EventTypeDelete {
+						verifier.SetSecret(nil)
+						return nil
+					}
+					verifier.SetSecret(ev.Kv.Value)
+					return nil
+				},
+			)
+		},
+	)
+	return verifier, nil
+}
+
 func (ctr *Container) Close() {
 	ctr.close()
 
@@ -320,12 +522,91 @@ func (ctr *Container) Close() {
 	)
 }
 
+// shutdownCheckInterval Shutdown 期间检查本地shard是否已经迁移的间隔
+const shutdownCheckInterval = 500 * time.Millisecond
+
+// Shutdown 优雅退出：通过心跳通知sm当前container即将退出，等待shard迁移到其他container，
+// ctx结束后按顺序drop本地剩余的shard，最后删除心跳节点，sm不需要等待maxRecoveryTime
+func (ctr *Container) Shutdown(ctx context.Context) error {
+	ctr.mu.Lock()
+	closed := ctr.closed
+	ctr.mu.Unlock()
+	if closed {
+		return nil
+	}
+
+	atomic.StoreInt32(&ctr.leaving, 1)
+	if err := ctr.heartbeat(ctx); err != nil {
+		logutil.Warn(
+			"heartbeat error",
+			zap.String("service", ctr.Service()),
+			zap.Error(err),
+		)
+	}
+	ctr.waitShardsMoved(ctx)
+
+	// close之后心跳停止，不会再创建心跳节点
+	ctr.close()
+	if _, err := ctr.Client.Delete(context.TODO(), ctr.heartbeatKey()); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	logutil.Info("container: shutdown",
+		zap.String("id", ctr.Id()),
+		zap.String("service", ctr.Service()),
+	)
+	return nil
+}
+
+// waitShardsMoved sm把shard迁移走之后，本地的shard会被drop并从storage中删除
+func (ctr *Container) waitShardsMoved(ctx context.Context) {
+	ticker := time.NewTicker(shutdownCheckInterval)
+	defer ticker.Stop()
+	for {
+		shardIDs, err := ctr.localShards()
+		if err == nil && len(shardIDs) == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			logutil.Warn(
+				"shards not moved before shutdown timeout",
+				zap.String("service", ctr.Service()),
+				zap.Strings("shardIDs", shardIDs),
+			)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// localShards storage中的shard，按照id排序
+func (ctr *Container) localShards() ([]string, error) {
+	var shardIDs []string
+	if err := ctr.shardKeeper.Storage().ForEach(
+		func(shardID string, _ *storage.ShardKeeperDbValue) error {
+			shardIDs = append(shardIDs, shardID)
+			return nil
+		},
+	); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	sort.Strings(shardIDs)
+	return shardIDs, nil
+}
+
+func (ctr *Container) heartbeatKey() string {
+	lockPfx := ctr.paths.ContainerPath(ctr.Service(), ctr.Id())
+	return fmt.Sprintf("%s/%x", lockPfx, ctr.Session.Lease())
+}
+
 func (ctr *Container) close() {
 	ctr.mu.Lock()
 	defer ctr.mu.Unlock()
 	if ctr.closed {
 		return
 	}
+	ctr.closed = true
 
 	// 先干掉srv，停止接受协议请求
 	if ctr.opts.receiver != nil {
@@ -345,20 +626,38 @@ func (ctr *Container) close() {
 
 	// 保证shard回收的手段，允许调用方启动for不断尝试重新加入存活container中
 	// FIXME session会触发drop动作，不允许失败，但也是潜在风险，一般的sdk使用者，不了解close的机制
-	dropFn := func(shardID string, dv *storage.ShardKeeperDbValue) error {
-		err := ctr.opts.appShardImpl.Drop(shardID)
-		if err == commonutil.ErrNotExist {
+	dropFn := func(shardID string) error {
+		timeout := ctr.opts.shardCallTimeout
+		if timeout <= 0 {
+			timeout = core.DefaultCallTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		err := ctr.opts.appShardImpl.Drop(ctx, shardID)
+		if core.IsNotExists(err) {
 			return nil
 		}
 		return err
 	}
-	if err := ctr.shardKeeper.Storage().ForEach(dropFn); err != nil {
+	// 按照shard id的顺序drop，单个shard失败不影响其他shard
+	shardIDs, err := ctr.localShards()
+	if err != nil {
 		logutil.Error(
-			"Drop error",
+			"ForEach error",
 			zap.String("service", ctr.Service()),
 			zap.Error(err),
 		)
 	}
+	for _, shardID := range shardIDs {
+		if err := dropFn(shardID); err != nil {
+			logutil.Error(
+				"Drop error",
+				zap.String("service", ctr.Service()),
+				zap.String("shardID", shardID),
+				zap.Error(err),
+			)
+		}
+	}
 	ctr.shardKeeper.Close()
 
 	if ctr.stopper != nil {
@@ -387,6 +686,9 @@ func (ctr *Container) SetService(s string) {
 	ctr.opts.service = s
 }
 
+// defaultLoadTimeout 心跳间隔是3s，获取container和shard负载不能占用太久
+const defaultLoadTimeout = time.Second
+
 type Heartbeat struct {
 	// Timestamp sm中用于计算container删除事件的等待时间
 	Timestamp int64 `json:"timestamp"`
@@ -401,14 +703,21 @@ type ContainerHeartbeat struct {
 	Heartbeat
 
 	// load
-	VirtualMemoryStat  *mem.VirtualMemoryStat `json:"virtualMemoryStat"`
-	CPUUsedPercent     float64                `json:"cpuUsedPercent"`
-	DiskIOCountersStat []*disk.IOCountersStat `json:"diskIOCountersStat"`
-	NetIOCountersStat  *net.IOCountersStat    `json:"netIOCountersStat"`
+	ContainerLoad
 
 	// Shards 直接带上id和lease，smserver可以基于lease做有效shard的过滤
 	// TODO 支持key-range，前提是server端改造rb算法
 	Shards []*storage.ShardKeeperDbValue `json:"shards"`
+
+	// BaseRevision 紧凑格式使用，不为0时是相对于该revision写入的全量心跳的增量
+	BaseRevision int64 `json:"baseRevision,omitempty"`
+	// CompactShards 紧凑格式的shard，此时Shards为空，增量心跳中只包含变化的shard
+	CompactShards []*CompactShard `json:"compactShards,omitempty"`
+	// Removed 增量心跳中，全量心跳里已经不存在的shard
+	Removed []string `json:"removed,omitempty"`
+
+	// Leaving container正在优雅退出，sm把shard迁移到其他container，见 Container.Shutdown
+	Leaving bool `json:"leaving,omitempty"`
 }
 
 func (l *ContainerHeartbeat) String() string {
@@ -417,38 +726,20 @@ func (l *ContainerHeartbeat) String() string {
 }
 
 func (ctr *Container) heartbeat(ctx context.Context) error {
+	ctr.hbMu.Lock()
+	defer ctr.hbMu.Unlock()
+
 	ld := ContainerHeartbeat{}
 	ld.Timestamp = time.Now().Unix()
+	ld.Leaving = atomic.LoadInt32(&ctr.leaving) == 1
 
-	// 内存使用比率
-	vm, err := mem.VirtualMemory()
-	if err != nil {
-		return errors.Wrap(err, "")
+	// 负载采集失败不影响心跳，container的liveness更重要
+	if load := ctr.containerLoad(ctx); load != nil {
+		ld.ContainerLoad = *load
 	}
-	ld.VirtualMemoryStat = vm
 
-	// cpu使用比率
-	cp, err := cpu.Percent(0, false)
-	if err != nil {
-		return errors.Wrap(err, "")
-	}
-	ld.CPUUsedPercent = cp[0]
-
-	// 磁盘io使用比率
-	diskIOCounters, err := disk.IOCounters()
-	if err != nil {
-		return errors.Wrap(err, "")
-	}
-	for _, v := range diskIOCounters {
-		ld.DiskIOCountersStat = append(ld.DiskIOCountersStat, &v)
-	}
-
-	// 网路io使用比率
-	netIOCounters, err := net.IOCounters(false)
-	if err != nil {
-		return errors.Wrap(err, "")
-	}
-	ld.NetIOCountersStat = &netIOCounters[0]
+	// app实现了 core.ShardLoadReporter 时，带上shard的负载，失败不影响心跳
+	loads := ctr.shardLoads(ctx)
 
 	// 本地分片信息带到hb中
 	var shards []*storage.ShardKeeperDbValue
@@ -463,30 +754,82 @@ func (ctr *Container) heartbeat(ctx context.Context) error {
 			// 2 未下发
 			// 		要删除，app未停止，hb要同步
 			//		要添加，app未开始，将要开始，hb要同步
-			shards = append(shards, dv)
+			// State 区分app是否已经加载完成
+			hb := *dv
+			hb.State = ctr.shardKeeper.State(shardID)
+			hb.Load = loads[shardID]
+			shards = append(shards, &hb)
 			return nil
 		},
 	); err != nil {
 		return errors.Wrap(err, "")
 	}
-	ld.Shards = shards
+	value, err := ctr.hbEncoder.encode(&ld, shards)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
 
 	// https://tangxusc.github.io/blog/2019/05/etcd-lock%E8%AF%A6%E8%A7%A3/
 	// 利用etcd内置lock，防止container冲突，这个问题在container应该比较少见，做到heartbeat即可，smserver就可以做
-	lockPfx := etcdutil.ContainerPath(ctr.Service(), ctr.Id())
+	lockPfx := ctr.paths.ContainerPath(ctr.Service(), ctr.Id())
 	mutex := concurrency.NewMutex(ctr.Session, lockPfx)
 	if err := mutex.Lock(ctr.Client.Ctx()); err != nil {
 		return errors.Wrap(err, "")
 	}
 
-	// 上传负载和基础信息
-	dataPfx := fmt.Sprintf("%s/%x", lockPfx, ctr.Session.Lease())
-	if _, err := ctr.Client.Put(ctx, dataPfx, ld.String(), clientv3.WithLease(ctr.Session.Lease())); err != nil {
+	// 上传负载和基础信息，和mutex使用同一个key
+	resp, err := ctr.Client.Put(ctx, ctr.heartbeatKey(), string(value), clientv3.WithLease(ctr.Session.Lease()))
+	if err != nil {
 		return errors.Wrap(err, "")
 	}
+	ctr.hbEncoder.written(resp.Header.Revision)
 	return nil
 }
 
+// containerLoad 部分采集失败时使用已经采集到的数据
+func (ctr *Container) containerLoad(ctx context.Context) *ContainerLoad {
+	ctx, cancel := context.WithTimeout(ctx, defaultLoadTimeout)
+	defer cancel()
+	load, err := ctr.opts.loadReporter.Load(ctx)
+	if err != nil {
+		logutil.Warn(
+			"Load error",
+			zap.String("service", ctr.Service()),
+			zap.Error(err),
+		)
+	}
+	return load
+}
+
+// shardLoads 获取app上报的shard负载，app没有实现或者出错时返回nil
+func (ctr *Container) shardLoads(ctx context.Context) map[string]*storage.ShardLoad {
+	reporter, ok := ctr.opts.appShardImpl.(core.ShardLoadReporter)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaultLoadTimeout)
+	defer cancel()
+	loads, err := reporter.ShardLoads(ctx)
+	if err != nil {
+		logutil.Warn(
+			"ShardLoads error",
+			zap.String("service", ctr.Service()),
+			zap.Error(err),
+		)
+		return nil
+	}
+	return loads
+}
+
+// ReportShardState app上报shard的加载状态，下次心跳带给smserver，见 storage.ShardState
+func (ctr *Container) ReportShardState(id string, state storage.ShardState) error {
+	return ctr.shardKeeper.ReportState(id, state)
+}
+
+func (ctr *Container) Paths() *etcdutil.PathBuilder {
+	return ctr.paths
+}
+
 func (ctr *Container) Receiver() receiver.Receiver {
 	return ctr.opts.receiver
 }
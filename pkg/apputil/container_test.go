@@ -2,12 +2,15 @@ package apputil
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/entertainment-venue/sm/pkg/apputil/core"
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
 
@@ -122,3 +125,27 @@ func newTestContainerOptions(ctx context.Context) []ContainerOption {
 		WithShardPrimitives(&core.ShardKeeper{}),
 	}
 }
+
+type loadReporter struct {
+	core.MockedShardPrimitivesV2
+
+	loads map[string]*storage.ShardLoad
+	err   error
+}
+
+func (r *loadReporter) ShardLoads(_ context.Context) (map[string]*storage.ShardLoad, error) {
+	return r.loads, r.err
+}
+
+func Test_Container_shardLoads(t *testing.T) {
+	ctr := Container{opts: &containerOptions{service: "foo", appShardImpl: new(core.MockedShardPrimitivesV2)}}
+	assert.Nil(t, ctr.shardLoads(context.TODO()))
+
+	loads := map[string]*storage.ShardLoad{"s1": {QPS: 10, Bytes: 1024, Cost: 1.5}}
+	ctr.opts.appShardImpl = &loadReporter{loads: loads}
+	assert.Equal(t, loads, ctr.shardLoads(context.TODO()))
+
+	// 出错时心跳不带负载
+	ctr.opts.appShardImpl = &loadReporter{loads: loads, err: errors.New("foo")}
+	assert.Nil(t, ctr.shardLoads(context.TODO()))
+}
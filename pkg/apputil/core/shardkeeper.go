@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/entertainment-venue/sm/pkg/apputil/storage"
@@ -16,6 +17,7 @@ import (
 	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
 type ShardPrimitives interface {
@@ -23,12 +25,26 @@ type ShardPrimitives interface {
 	Drop(id string) error
 }
 
+// ShardUpdater ShardPrimitives 的可选实现，shard只有Task变化时，app原地更新，
+// 没有实现时 ShardKeeper 先drop再add
+type ShardUpdater interface {
+	Update(id string, spec *storage.ShardSpec) error
+}
+
 const (
 	// rebalanceTrigger shardKeeper.rbTrigger 使用
 	rebalanceTrigger = "rebalanceTrigger"
+	// resyncTrigger shardKeeper.rbTrigger 使用，watch丢失事件后，用lease节点的全量数据修正状态
+	resyncTrigger = "resyncTrigger"
 
 	// defaultSyncInterval boltdb同步到app的周期
 	defaultSyncInterval = 300 * time.Millisecond
+
+	// DefaultCallTimeout 单次调用app的 ShardPrimitivesV2 的超时时间
+	DefaultCallTimeout = 10 * time.Second
+
+	// defaultSyncConcurrency 默认串行下发，app的实现不一定支持并发调用
+	defaultSyncConcurrency = 1
 )
 
 type Assignment struct {
@@ -74,6 +90,12 @@ type ShardKeeper struct {
 	// guardLease acquireGuardLease 赋值，当前guard lease，成功时才能赋值，直到下次rb
 	guardLease *storage.Lease
 
+	// states 下发给app的shard的生命周期状态，随心跳上报
+	states shardStates
+
+	// limiter SyncRate 大于0时限制每秒下发给app的add和update数量，跨sync周期生效
+	limiter *rate.Limiter
+
 	containerOpts *ShardKeeperOptions
 }
 
@@ -84,7 +106,18 @@ type ShardKeeperOptions struct {
 	DropExpiredShard bool
 	Client           etcdutil.EtcdWrapper
 	ShardDir         string
-	AppShardImpl     ShardPrimitives
+	AppShardImpl     ShardPrimitivesV2
+
+	// CallTimeout 单次调用 AppShardImpl 的超时时间，默认 DefaultCallTimeout
+	CallTimeout time.Duration
+
+	// SyncConcurrency sync时并发调用 AppShardImpl 的数量，默认 defaultSyncConcurrency
+	SyncConcurrency int
+	// SyncRate 每秒最多下发的add和update数量，小于等于0不限制
+	SyncRate int
+
+	// Paths 当前container所在sm集群的etcd路径
+	Paths *etcdutil.PathBuilder
 }
 
 func NewShardKeeper(opts *ShardKeeperOptions, st storage.Storage) (*ShardKeeper, error) {
@@ -97,8 +130,13 @@ func NewShardKeeper(opts *ShardKeeperOptions, st storage.Storage) (*ShardKeeper,
 		guardLease:  storage.NoLease,
 	}
 
+	if opts.SyncRate > 0 {
+		sk.limiter = rate.NewLimiter(rate.Limit(opts.SyncRate), opts.SyncRate)
+	}
+
 	sk.rbTrigger, _ = commonutil.NewTrigger(commonutil.WithWorkerSize(1))
 	sk.rbTrigger.Register(rebalanceTrigger, sk.handleRbEvent)
+	sk.rbTrigger.Register(resyncTrigger, sk.handleResync)
 
 	// 标记本地shard的Disp为false，等待参与rb，或者通过guard lease对比直接参与
 	if err := sk.storage.Reset(); err != nil {
@@ -110,7 +148,7 @@ func NewShardKeeper(opts *ShardKeeperOptions, st storage.Storage) (*ShardKeeper,
 		return nil, err
 	}
 
-	leasePfx := etcdutil.LeasePath(sk.containerOpts.Service)
+	leasePfx := sk.containerOpts.Paths.LeasePath(sk.containerOpts.Service)
 	gresp, err := sk.containerOpts.Client.Get(context.Background(), leasePfx, clientv3.WithPrefix())
 	if err != nil {
 		return nil, errors.Wrap(err, "")
@@ -183,7 +221,7 @@ func NewShardKeeper(opts *ShardKeeperOptions, st storage.Storage) (*ShardKeeper,
 			defaultSyncInterval,
 			fmt.Sprintf("sync exit %s", sk.containerOpts.Service),
 			func(ctx context.Context) error {
-				return sk.sync()
+				return sk.sync(ctx)
 			},
 		)
 	})
@@ -193,10 +231,10 @@ func NewShardKeeper(opts *ShardKeeperOptions, st storage.Storage) (*ShardKeeper,
 
 // WatchLease 监听lease节点，及时参与到rb中
 func (sk *ShardKeeper) WatchLease() {
-	leasePfx := etcdutil.LeasePath(sk.containerOpts.Service)
+	leasePfx := sk.containerOpts.Paths.LeasePath(sk.containerOpts.Service)
 	sk.stopper.Wrap(
 		func(ctx context.Context) {
-			etcdutil.WatchLoop(
+			etcdutil.WatchLoopWithResync(
 				ctx,
 				sk.containerOpts.Client,
 				leasePfx,
@@ -204,6 +242,10 @@ func (sk *ShardKeeper) WatchLease() {
 				func(ctx context.Context, ev *clientv3.Event) error {
 					return sk.rbTrigger.Put(&commonutil.TriggerEvent{Key: rebalanceTrigger, Value: ev})
 				},
+				func(ctx context.Context, resp *clientv3.GetResponse) error {
+					// 和lease节点的事件在同一个goroutine中顺序处理
+					return sk.rbTrigger.Put(&commonutil.TriggerEvent{Key: resyncTrigger, Value: resp})
+				},
 			)
 		},
 	)
@@ -232,7 +274,7 @@ func (sk *ShardKeeper) handleRbEvent(_ string, value interface{}) error {
 	)
 
 	switch key {
-	case etcdutil.LeaseBridgePath(sk.containerOpts.Service):
+	case sk.containerOpts.Paths.LeaseBridgePath(sk.containerOpts.Service):
 		if err := sk.acquireBridgeLease(ev, lease); err != nil {
 			logutil.Error(
 				"acquireBridgeLease error",
@@ -242,7 +284,7 @@ func (sk *ShardKeeper) handleRbEvent(_ string, value interface{}) error {
 			)
 			return nil
 		}
-	case etcdutil.LeaseGuardPath(sk.containerOpts.Service):
+	case sk.containerOpts.Paths.LeaseGuardPath(sk.containerOpts.Service):
 		if err := sk.acquireGuardLease(ev, lease); err != nil {
 			logutil.Error(
 				"acquireGuardLease error",
@@ -253,7 +295,7 @@ func (sk *ShardKeeper) handleRbEvent(_ string, value interface{}) error {
 			return nil
 		}
 	default:
-		if !strings.HasPrefix(key, etcdutil.LeaseSessionDir(sk.containerOpts.Service)) {
+		if !strings.HasPrefix(key, sk.containerOpts.Paths.LeaseSessionDir(sk.containerOpts.Service)) {
 			return errors.Errorf("unexpected key [%s]", key)
 		}
 		return sk.handleSessionKeyEvent(ev)
@@ -261,6 +303,82 @@ func (sk *ShardKeeper) handleRbEvent(_ string, value interface{}) error {
 	return nil
 }
 
+// handleResync 对比lease节点的快照和当前的bridge、guard，补齐丢失的事件：
+// 1 当前guard下发的bridge没有处理，先处理bridge
+// 2 guard发生变化，处理guard
+// 3 当前bridge已经不存在，按照bridge删除处理
+// session节点的删除事件无法通过快照还原，shard的lease会在下一次rb中修正
+func (sk *ShardKeeper) handleResync(_ string, value interface{}) error {
+	resp, ok := value.(*clientv3.GetResponse)
+	if !ok {
+		return errors.New("type error")
+	}
+
+	bridgePath := sk.containerOpts.Paths.LeaseBridgePath(sk.containerOpts.Service)
+	guardPath := sk.containerOpts.Paths.LeaseGuardPath(sk.containerOpts.Service)
+	var bridgeKv, guardKv *mvccpb.KeyValue
+	for _, kv := range resp.Kvs {
+		switch string(kv.Key) {
+		case bridgePath:
+			bridgeKv = kv
+		case guardPath:
+			guardKv = kv
+		}
+	}
+	logutil.Info(
+		"receive resync event",
+		zap.String("service", sk.containerOpts.Service),
+		zap.Reflect("bridgeLease", sk.bridgeLease),
+		zap.Reflect("guardLease", sk.guardLease),
+	)
+
+	var bridge *ShardLease
+	if bridgeKv != nil {
+		var lease ShardLease
+		if err := json.Unmarshal(bridgeKv.Value, &lease); err != nil {
+			return errors.Wrap(err, "")
+		}
+		bridge = &lease
+	}
+
+	// 1 bridge是从当前guard迁移，说明丢失了bridge的创建事件
+	if bridge != nil && bridge.ID != sk.bridgeLease.ID && bridge.GuardLeaseID == sk.guardLease.ID {
+		ev := clientv3.Event{Type: mvccpb.PUT, Kv: bridgeKv}
+		if err := sk.handleRbEvent(rebalanceTrigger, &ev); err != nil {
+			return err
+		}
+	}
+
+	// 2 guard发生变化
+	if guardKv != nil {
+		var guard ShardLease
+		if err := json.Unmarshal(guardKv.Value, &guard); err != nil {
+			return errors.Wrap(err, "")
+		}
+		if guard.ID != sk.guardLease.ID {
+			ev := clientv3.Event{Type: mvccpb.PUT, Kv: guardKv}
+			if err := sk.handleRbEvent(rebalanceTrigger, &ev); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 3 当前的bridge已经被删除，没有迁移到guard的shard需要drop
+	if !sk.bridgeLease.EqualTo(storage.NoLease) && (bridge == nil || bridge.ID != sk.bridgeLease.ID) {
+		prev := ShardLease{Lease: *sk.bridgeLease}
+		ev := clientv3.Event{
+			Type:   mvccpb.DELETE,
+			Kv:     &mvccpb.KeyValue{Key: []byte(bridgePath)},
+			PrevKv: &mvccpb.KeyValue{Key: []byte(bridgePath), Value: []byte(prev.String())},
+		}
+		if err := sk.handleRbEvent(rebalanceTrigger, &ev); err != nil {
+			return err
+		}
+		sk.bridgeLease = storage.NoLease
+	}
+	return nil
+}
+
 func (sk *ShardKeeper) parseShardLease(ev *clientv3.Event) (*ShardLease, error) {
 	var value []byte
 	if ev.Type == mvccpb.DELETE {
@@ -411,7 +529,7 @@ func (sk *ShardKeeper) acquireGuardLease(ev *clientv3.Event, lease *ShardLease)
 	)
 
 	// 存储和lease的关联节点
-	sessionPath := etcdutil.LeaseSessionPath(sk.containerOpts.Service, sk.containerOpts.ContainerId)
+	sessionPath := sk.containerOpts.Paths.LeaseSessionPath(sk.containerOpts.Service, sk.containerOpts.ContainerId)
 	leaseIDStr := strconv.FormatInt(int64(sk.guardLease.ID), 10)
 	if _, err := sk.containerOpts.Client.Put(context.TODO(), sessionPath, sk.guardLease.String(), clientv3.WithLease(sk.guardLease.ID)); err != nil {
 		logutil.Error(
@@ -440,22 +558,111 @@ func (sk *ShardKeeper) Add(id string, spec *storage.ShardSpec) error {
 	return sk.storage.Add(spec)
 }
 
+// Update shard的Task变化，记录到storage，由sync下发给app
+func (sk *ShardKeeper) Update(id string, spec *storage.ShardSpec) error {
+	if !spec.Lease.EqualTo(sk.guardLease) {
+		logutil.Warn(
+			"shard guard lease not equal with guard lease",
+			zap.String("service", sk.containerOpts.Service),
+			zap.String("shard-id", id),
+			zap.Int64("local-guard-lease", int64(sk.guardLease.ID)),
+			zap.Int64("shard-guard-lease", int64(spec.Lease.ID)),
+		)
+		return errors.New("lease mismatch")
+	}
+
+	var cur *storage.ShardKeeperDbValue
+	if err := sk.storage.ForEach(func(shardID string, dv *storage.ShardKeeperDbValue) error {
+		if shardID == id {
+			cur = dv
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if cur == nil || cur.Drop {
+		return errors.Wrap(commonutil.ErrNotExist, "")
+	}
+	// 重复的更新请求不需要再次下发
+	if cur.Spec.Task == spec.Task {
+		return nil
+	}
+
+	dv := storage.ShardKeeperDbValue{Spec: spec, Update: true}
+	if err := sk.storage.Put(id, &dv); err != nil {
+		return err
+	}
+	logutil.Info(
+		"shard updated",
+		zap.String("service", sk.containerOpts.Service),
+		zap.String("shard-id", id),
+		zap.String("task", spec.Task),
+	)
+	return nil
+}
+
 func (sk *ShardKeeper) Drop(id string) error {
 	return sk.storage.Drop([]string{id})
 }
 
-// sync 没有关注lease，boltdb中存在的就需要提交给app
-func (sk *ShardKeeper) sync() error {
+// syncTask sync中对单个shard的操作
+type syncTask struct {
+	dv *storage.ShardKeeperDbValue
+	fn func(dv *storage.ShardKeeperDbValue) error
+}
+
+// sync 没有关注lease，boltdb中存在的就需要提交给app，
+// app返回 Permanent 错误的shard不再重试：add和drop从boltdb中移除，update从app中drop之后移除。
+// 先并发完成所有drop，再并发下发add和update，结果在最后批量写入storage，期间收到新指令的shard不覆盖
+func (sk *ShardKeeper) sync(ctx context.Context) error {
 	var (
+		mu             sync.Mutex
 		dropShardIDs   []string
 		updateDbValues = make(map[string]*storage.ShardKeeperDbValue)
+		// snapshots ForEach时storage中的值，sync期间receiver可能写入新的指令，Batch时只修改没有变化的shard
+		snapshots = make(map[string]*storage.ShardKeeperDbValue)
+
+		drops  []*syncTask
+		starts []*syncTask
 	)
 
+	callTimeout := sk.containerOpts.CallTimeout
+	if callTimeout <= 0 {
+		callTimeout = DefaultCallTimeout
+	}
+	call := func(fn func(ctx context.Context) error) error {
+		ctx, cancel := context.WithTimeout(ctx, callTimeout)
+		defer cancel()
+		return fn(ctx)
+	}
+	appDrop := func(id string) error {
+		return call(func(ctx context.Context) error {
+			return sk.containerOpts.AppShardImpl.Drop(ctx, id)
+		})
+	}
+	appAdd := func(id string, spec *storage.ShardSpec) error {
+		return call(func(ctx context.Context) error {
+			return sk.containerOpts.AppShardImpl.Add(ctx, id, spec)
+		})
+	}
+
 	dropFn := func(dv *storage.ShardKeeperDbValue) error {
-		err := sk.containerOpts.AppShardImpl.Drop(dv.Spec.Id)
-		if err == nil || err == commonutil.ErrNotExist {
+		sk.states.set(dv.Spec.Id, storage.ShardStateStopping)
+		err := appDrop(dv.Spec.Id)
+		if err == nil || IsNotExists(err) || IsPermanent(err) {
+			sk.states.remove(dv.Spec.Id)
+			if err != nil && !IsNotExists(err) {
+				logutil.Error(
+					"drop shard failed permanently, remove from storage",
+					zap.String("service", sk.containerOpts.Service),
+					zap.String("shardId", dv.Spec.Id),
+					zap.Error(err),
+				)
+			}
 			// 清理掉shard
+			mu.Lock()
 			dropShardIDs = append(dropShardIDs, dv.Spec.Id)
+			mu.Unlock()
 			return nil
 		}
 		logutil.Error(
@@ -468,13 +675,33 @@ func (sk *ShardKeeper) sync() error {
 	}
 
 	addFn := func(dv *storage.ShardKeeperDbValue) error {
-		err := sk.containerOpts.AppShardImpl.Add(dv.Spec.Id, dv.Spec)
-		if err == nil || err == commonutil.ErrExist {
+		sk.states.set(dv.Spec.Id, storage.ShardStateStarting)
+		err := appAdd(dv.Spec.Id, dv.Spec)
+		if err == nil || IsAlreadyExists(err) {
+			sk.states.started(dv.Spec.Id)
 			// 下发成功后更新boltdb
 			dv.Disp = true
+			dv.Update = false
+			mu.Lock()
 			updateDbValues[dv.Spec.Id] = dv
+			mu.Unlock()
+			return nil
+		}
+		if IsPermanent(err) {
+			// shard不会被app接受，从本地移除，server在下次rb中重新分配
+			sk.states.remove(dv.Spec.Id)
+			logutil.Error(
+				"add shard failed permanently, remove from storage",
+				zap.String("service", sk.containerOpts.Service),
+				zap.String("shardId", dv.Spec.Id),
+				zap.Error(err),
+			)
+			mu.Lock()
+			dropShardIDs = append(dropShardIDs, dv.Spec.Id)
+			mu.Unlock()
 			return nil
 		}
+		sk.states.set(dv.Spec.Id, storage.ShardStateFailed)
 		logutil.Error(
 			"add shard failed",
 			zap.String("service", sk.containerOpts.Service),
@@ -484,7 +711,77 @@ func (sk *ShardKeeper) sync() error {
 		return err
 	}
 
-	sk.storage.ForEach(func(shardID string, dv *storage.ShardKeeperDbValue) error {
+	updateFn := func(dv *storage.ShardKeeperDbValue) error {
+		updater, ok := sk.containerOpts.AppShardImpl.(ShardUpdaterV2)
+		if !ok {
+			// app不支持原地更新，drop之后重新add
+			sk.states.set(dv.Spec.Id, storage.ShardStateStopping)
+			err := appDrop(dv.Spec.Id)
+			if err == nil || IsNotExists(err) {
+				// drop已经成功，add的失败按照add的规则处理
+				return addFn(dv)
+			}
+			if IsPermanent(err) {
+				// 按照drop的规则从本地移除，server在下次rb中重新分配
+				sk.states.remove(dv.Spec.Id)
+				logutil.Error(
+					"update shard failed permanently, remove from storage",
+					zap.String("service", sk.containerOpts.Service),
+					zap.String("shardId", dv.Spec.Id),
+					zap.Error(err),
+				)
+				mu.Lock()
+				dropShardIDs = append(dropShardIDs, dv.Spec.Id)
+				mu.Unlock()
+				return nil
+			}
+			sk.states.set(dv.Spec.Id, storage.ShardStateFailed)
+			logutil.Error(
+				"update shard failed",
+				zap.String("service", sk.containerOpts.Service),
+				zap.String("shardId", dv.Spec.Id),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		sk.states.set(dv.Spec.Id, storage.ShardStateStarting)
+		err := call(func(ctx context.Context) error {
+			return updater.Update(ctx, dv.Spec.Id, dv.Spec)
+		})
+		if err == nil {
+			sk.states.started(dv.Spec.Id)
+			dv.Update = false
+			mu.Lock()
+			updateDbValues[dv.Spec.Id] = dv
+			mu.Unlock()
+			return nil
+		}
+		if IsPermanent(err) {
+			// app继续使用原有的Task，storage中已经是新的Task，心跳会上报错误的task，
+			// 按照add的永久失败处理，从app和本地移除，server在下次rb中重新分配
+			logutil.Error(
+				"update shard failed permanently, drop it",
+				zap.String("service", sk.containerOpts.Service),
+				zap.String("shardId", dv.Spec.Id),
+				zap.Error(err),
+			)
+			return dropFn(dv)
+		}
+		sk.states.set(dv.Spec.Id, storage.ShardStateFailed)
+		logutil.Error(
+			"update shard failed",
+			zap.String("service", sk.containerOpts.Service),
+			zap.String("shardId", dv.Spec.Id),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := sk.storage.ForEach(func(shardID string, dv *storage.ShardKeeperDbValue) error {
+		snapshot := *dv
+		snapshots[shardID] = &snapshot
+
 		// shard的lease一定和guardLease是相等的才可以下发
 		/*
 			这种要求shardkeeper下发shard的情况，有两个通道：
@@ -498,7 +795,8 @@ func (sk *ShardKeeper) sync() error {
 				zap.Reflect("dv", dv),
 				zap.Reflect("guardLease", sk.guardLease),
 			)
-			return dropFn(dv)
+			drops = append(drops, &syncTask{dv: dv, fn: dropFn})
+			return nil
 		}
 
 		if dv.Disp && sk.initialized {
@@ -511,7 +809,19 @@ func (sk *ShardKeeper) sync() error {
 				zap.String("service", sk.containerOpts.Service),
 				zap.Reflect("shard", dv),
 			)
-			return dropFn(dv)
+			drops = append(drops, &syncTask{dv: dv, fn: dropFn})
+			return nil
+		}
+
+		// 第一次sync时app中还没有shard，直接add
+		if dv.Update && sk.initialized {
+			logutil.Info(
+				"update shard to app",
+				zap.String("service", sk.containerOpts.Service),
+				zap.Reflect("shard", dv),
+			)
+			starts = append(starts, &syncTask{dv: dv, fn: updateFn})
+			return nil
 		}
 
 		logutil.Info(
@@ -519,27 +829,83 @@ func (sk *ShardKeeper) sync() error {
 			zap.String("service", sk.containerOpts.Service),
 			zap.Reflect("shard", dv),
 		)
-		return addFn(dv)
-	})
-
-	for _, shardID := range dropShardIDs {
-		if err := sk.storage.Remove(shardID); err != nil {
-			return err
-		}
+		starts = append(starts, &syncTask{dv: dv, fn: addFn})
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	for shardID, dv := range updateDbValues {
+	// 同一个shard只会出现一次，drop全部完成再add，保证app先释放资源，
+	// update的fallback在同一个task中先drop再add
+	dropErr := sk.runParallel(ctx, drops, nil)
+	startErr := sk.runParallel(ctx, starts, sk.limiter)
+
+	for _, dv := range updateDbValues {
 		dv.Disp = true
-		if err := sk.storage.Put(shardID, dv); err != nil {
-			return err
-		}
+	}
+	if err := sk.storage.Batch(updateDbValues, dropShardIDs, snapshots); err != nil {
+		return err
 	}
 
 	// 整体sync一遍，才进入运行时根据Disp属性选择同步状态
 	if !sk.initialized {
 		sk.initialized = true
 	}
-	return nil
+	if dropErr != nil {
+		return dropErr
+	}
+	return startErr
+}
+
+// runParallel 最多 SyncConcurrency 个task同时执行，limiter不为空时每个task开始前获取令牌，
+// 等待所有已开始的task结束，返回第一个错误
+func (sk *ShardKeeper) runParallel(ctx context.Context, tasks []*syncTask, limiter *rate.Limiter) error {
+	concurrency := sk.containerOpts.SyncConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultSyncConcurrency
+	}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	setErr := func(err error) {
+		once.Do(func() { firstErr = err })
+	}
+
+	sem := make(chan struct{}, concurrency)
+	for _, task := range tasks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			// container关闭，剩余的task在下次启动后处理
+			setErr(errors.Wrap(err, ""))
+			break
+		}
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				<-sem
+				setErr(errors.Wrap(err, ""))
+				break
+			}
+		}
+
+		wg.Add(1)
+		go func(t *syncTask) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := t.fn(t.dv); err != nil {
+				setErr(err)
+			}
+		}(task)
+	}
+	wg.Wait()
+	return firstErr
 }
 
 func (sk *ShardKeeper) Close() {
@@ -1,17 +1,25 @@
 package core
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/entertainment-venue/sm/pkg/commonutil"
 	"github.com/entertainment-venue/sm/pkg/etcdutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -39,6 +47,7 @@ func (suite *ShardKeeperTestSuite) SetupTest() {
 
 		containerOpts: &ShardKeeperOptions{
 			Service: service,
+			Paths:   etcdutil.NewPathBuilder(""),
 		},
 	}
 
@@ -279,7 +288,7 @@ func (suite *ShardKeeperTestSuite) TestAcquireGuardLease_ok() {
 	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
 	mockedEtcdWrapper.On("Put",
 		mock.Anything,
-		etcdutil.LeaseSessionPath(suite.shardKeeper.containerOpts.Service, suite.shardKeeper.containerOpts.ContainerId),
+		suite.shardKeeper.containerOpts.Paths.LeaseSessionPath(suite.shardKeeper.containerOpts.Service, suite.shardKeeper.containerOpts.ContainerId),
 		mock.Anything,
 		mock.Anything).Return(&clientv3.PutResponse{}, nil)
 	suite.shardKeeper.containerOpts.Client = mockedEtcdWrapper
@@ -315,6 +324,368 @@ func (suite *ShardKeeperTestSuite) TestAdd_addOnce() {
 	assert.Nil(suite.T(), err)
 }
 
+func (suite *ShardKeeperTestSuite) TestUpdate_notExist() {
+	fakeSpec := &storage.ShardSpec{Id: defaultTestPlaceHolder, Lease: suite.shardDbValue.Spec.Lease}
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(map[string]*storage.ShardKeeperDbValue{}, nil)
+	suite.shardKeeper.storage = mockedStorage
+	err := suite.shardKeeper.Update(defaultTestPlaceHolder, fakeSpec)
+	mockedStorage.AssertExpectations(suite.T())
+	assert.True(suite.T(), errors.Is(err, commonutil.ErrNotExist))
+}
+
+func (suite *ShardKeeperTestSuite) TestUpdate_sameTask() {
+	fakeSpec := &storage.ShardSpec{Id: "bar", Lease: suite.shardDbValue.Spec.Lease}
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, nil)
+	suite.shardKeeper.storage = mockedStorage
+	err := suite.shardKeeper.Update("bar", fakeSpec)
+	mockedStorage.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *ShardKeeperTestSuite) TestUpdate_ok() {
+	fakeSpec := &storage.ShardSpec{Id: "bar", Task: "new", Lease: suite.shardDbValue.Spec.Lease}
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, nil)
+	mockedStorage.On("Put", "bar", &storage.ShardKeeperDbValue{Spec: fakeSpec, Update: true}).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+	err := suite.shardKeeper.Update("bar", fakeSpec)
+	mockedStorage.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *ShardKeeperTestSuite) TestSync_update() {
+	suite.shardKeeper.initialized = true
+	suite.shardDbValue.Disp = false
+	suite.shardDbValue.Update = true
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, nil)
+	mockedStorage.On("Batch", map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, []string(nil)).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	mockedUpdater := new(MockedShardUpdater)
+	mockedUpdater.On("Update", "bar", suite.shardDbValue.Spec).Return(nil)
+	suite.shardKeeper.containerOpts.AppShardImpl = NewShardPrimitivesV2(mockedUpdater)
+
+	err := suite.shardKeeper.sync(context.TODO())
+	assert.Nil(suite.T(), err)
+	mockedStorage.AssertExpectations(suite.T())
+	mockedUpdater.AssertExpectations(suite.T())
+	assert.False(suite.T(), suite.shardDbValue.Update)
+	assert.True(suite.T(), suite.shardDbValue.Disp)
+}
+
+func (suite *ShardKeeperTestSuite) TestSync_updateFallback() {
+	suite.shardKeeper.initialized = true
+	suite.shardDbValue.Disp = false
+	suite.shardDbValue.Update = true
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, nil)
+	mockedStorage.On("Batch", map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, []string(nil)).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	// app没有实现 ShardUpdater ，drop之后重新add
+	mockedPrimitives := new(MockedShardPrimitives)
+	mockedPrimitives.On("Drop", "bar").Return(nil)
+	mockedPrimitives.On("Add", "bar", suite.shardDbValue.Spec).Return(nil)
+	suite.shardKeeper.containerOpts.AppShardImpl = NewShardPrimitivesV2(mockedPrimitives)
+
+	err := suite.shardKeeper.sync(context.TODO())
+	assert.Nil(suite.T(), err)
+	mockedPrimitives.AssertExpectations(suite.T())
+	assert.False(suite.T(), suite.shardDbValue.Update)
+}
+
+func (suite *ShardKeeperTestSuite) TestSync_updatePermanent() {
+	suite.shardKeeper.initialized = true
+	suite.shardDbValue.Disp = false
+	suite.shardDbValue.Update = true
+
+	// app拒绝新的Task，shard从app和本地移除，不能把新的Task标记为已下发
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, nil)
+	mockedStorage.On("Batch", map[string]*storage.ShardKeeperDbValue{}, []string{"bar"}).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	mockedUpdater := new(MockedShardUpdaterV2)
+	mockedUpdater.On("Update", mock.Anything, "bar", suite.shardDbValue.Spec).Return(Permanent(errors.New("bad task")))
+	mockedUpdater.On("Drop", mock.Anything, "bar").Return(nil)
+	suite.shardKeeper.containerOpts.AppShardImpl = mockedUpdater
+
+	err := suite.shardKeeper.sync(context.TODO())
+	assert.Nil(suite.T(), err)
+	mockedStorage.AssertExpectations(suite.T())
+	mockedUpdater.AssertExpectations(suite.T())
+	assert.True(suite.T(), suite.shardDbValue.Update)
+	assert.False(suite.T(), suite.shardDbValue.Disp)
+}
+
+func (suite *ShardKeeperTestSuite) TestSync_updateFallbackPermanent() {
+	suite.shardKeeper.initialized = true
+	suite.shardDbValue.Disp = false
+	suite.shardDbValue.Update = true
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, nil)
+	mockedStorage.On("Batch", map[string]*storage.ShardKeeperDbValue{}, []string{"bar"}).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	// drop永久失败时不再add
+	mockedPrimitives := new(MockedShardPrimitivesV2)
+	mockedPrimitives.On("Drop", mock.Anything, "bar").Return(Permanent(errors.New("bad shard")))
+	suite.shardKeeper.containerOpts.AppShardImpl = mockedPrimitives
+
+	err := suite.shardKeeper.sync(context.TODO())
+	assert.Nil(suite.T(), err)
+	mockedStorage.AssertExpectations(suite.T())
+	mockedPrimitives.AssertExpectations(suite.T())
+	mockedPrimitives.AssertNotCalled(suite.T(), "Add", mock.Anything, "bar", mock.Anything)
+}
+
+func (suite *ShardKeeperTestSuite) TestSync_dropDuringSync() {
+	suite.shardKeeper.initialized = true
+
+	db, err := storage.NewBoltdb(suite.T().TempDir(), suite.shardKeeper.containerOpts.Service)
+	assert.Nil(suite.T(), err)
+	defer db.Close()
+	assert.Nil(suite.T(), db.Add(suite.shardDbValue.Spec))
+	suite.shardKeeper.storage = db
+
+	// add的过程中receiver收到drop，sync结束时不能覆盖drop
+	mockedPrimitives := new(MockedShardPrimitivesV2)
+	mockedPrimitives.On("Add", mock.Anything, "bar", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		assert.Nil(suite.T(), suite.shardKeeper.Drop("bar"))
+	})
+	mockedPrimitives.On("Drop", mock.Anything, "bar").Return(nil)
+	suite.shardKeeper.containerOpts.AppShardImpl = mockedPrimitives
+
+	assert.Nil(suite.T(), suite.shardKeeper.sync(context.TODO()))
+	v, _ := db.Get([]byte("bar"))
+	var dv storage.ShardKeeperDbValue
+	assert.Nil(suite.T(), json.Unmarshal(v, &dv))
+	assert.True(suite.T(), dv.Drop)
+	assert.False(suite.T(), dv.Disp)
+
+	// 下一轮从app中drop
+	assert.Nil(suite.T(), suite.shardKeeper.sync(context.TODO()))
+	mockedPrimitives.AssertExpectations(suite.T())
+	v, _ = db.Get([]byte("bar"))
+	assert.Nil(suite.T(), v)
+}
+
+func (suite *ShardKeeperTestSuite) TestSync_addPermanent() {
+	suite.shardKeeper.initialized = true
+	suite.shardDbValue.Disp = false
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, nil)
+	mockedStorage.On("Batch", map[string]*storage.ShardKeeperDbValue{}, []string{"bar"}).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	mockedPrimitives := new(MockedShardPrimitivesV2)
+	mockedPrimitives.On("Add", mock.Anything, "bar", suite.shardDbValue.Spec).Return(Permanent(errors.New("bad shard")))
+	suite.shardKeeper.containerOpts.AppShardImpl = mockedPrimitives
+
+	err := suite.shardKeeper.sync(context.TODO())
+	assert.Nil(suite.T(), err)
+	mockedStorage.AssertExpectations(suite.T())
+	mockedPrimitives.AssertExpectations(suite.T())
+}
+
+func (suite *ShardKeeperTestSuite) TestSync_addRetryable() {
+	suite.shardKeeper.initialized = true
+	suite.shardDbValue.Disp = false
+	suite.shardKeeper.containerOpts.CallTimeout = time.Second
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, nil)
+	mockedStorage.On("Batch", map[string]*storage.ShardKeeperDbValue{}, []string(nil)).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	mockedPrimitives := new(MockedShardPrimitivesV2)
+	mockedPrimitives.On("Add", mock.Anything, "bar", suite.shardDbValue.Spec).Return(Retryable(errors.New("busy")))
+	suite.shardKeeper.containerOpts.AppShardImpl = mockedPrimitives
+
+	err := suite.shardKeeper.sync(context.TODO())
+	assert.NotNil(suite.T(), err)
+	mockedPrimitives.AssertExpectations(suite.T())
+	mockedStorage.AssertExpectations(suite.T())
+	assert.False(suite.T(), suite.shardDbValue.Disp)
+
+	// 每次调用携带deadline
+	ctx := mockedPrimitives.Calls[0].Arguments.Get(0).(context.Context)
+	deadline, ok := ctx.Deadline()
+	assert.True(suite.T(), ok)
+	assert.True(suite.T(), time.Until(deadline) <= time.Second)
+}
+
+func (suite *ShardKeeperTestSuite) TestSync_dropAlreadyGone() {
+	suite.shardKeeper.initialized = true
+	suite.shardDbValue.Disp = false
+	suite.shardDbValue.Drop = true
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, nil)
+	mockedStorage.On("Batch", map[string]*storage.ShardKeeperDbValue{}, []string{"bar"}).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	mockedPrimitives := new(MockedShardPrimitivesV2)
+	mockedPrimitives.On("Drop", mock.Anything, "bar").Return(fmt.Errorf("drop: %w", ErrNotExists))
+	suite.shardKeeper.containerOpts.AppShardImpl = mockedPrimitives
+
+	err := suite.shardKeeper.sync(context.TODO())
+	assert.Nil(suite.T(), err)
+	mockedStorage.AssertExpectations(suite.T())
+}
+
+func (suite *ShardKeeperTestSuite) TestSync_parallel() {
+	suite.shardKeeper.initialized = true
+	suite.shardKeeper.containerOpts.SyncConcurrency = 4
+
+	dvs := make(map[string]*storage.ShardKeeperDbValue)
+	for i := 0; i < 8; i++ {
+		id := fmt.Sprintf("add-%d", i)
+		dvs[id] = &storage.ShardKeeperDbValue{Spec: &storage.ShardSpec{Id: id, Lease: suite.shardDbValue.Spec.Lease}}
+	}
+	for i := 0; i < 2; i++ {
+		id := fmt.Sprintf("drop-%d", i)
+		dvs[id] = &storage.ShardKeeperDbValue{Spec: &storage.ShardSpec{Id: id, Lease: suite.shardDbValue.Spec.Lease}, Drop: true}
+	}
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(dvs, nil)
+	mockedStorage.On("Batch", mock.Anything, mock.Anything).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	recorder := &recordedPrimitives{}
+	suite.shardKeeper.containerOpts.AppShardImpl = recorder
+
+	err := suite.shardKeeper.sync(context.TODO())
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 4, recorder.maxRunning)
+
+	// drop全部完成之后才开始add
+	assert.Len(suite.T(), recorder.calls, 10)
+	for i, call := range recorder.calls {
+		if i < 2 {
+			assert.True(suite.T(), strings.HasPrefix(call, "drop-"))
+		} else {
+			assert.True(suite.T(), strings.HasPrefix(call, "add-"))
+		}
+	}
+
+	// storage只写一次
+	mockedStorage.AssertNumberOfCalls(suite.T(), "Batch", 1)
+	puts := mockedStorage.Calls[1].Arguments.Get(0).(map[string]*storage.ShardKeeperDbValue)
+	removes := mockedStorage.Calls[1].Arguments.Get(1).([]string)
+	assert.Len(suite.T(), puts, 8)
+	assert.ElementsMatch(suite.T(), []string{"drop-0", "drop-1"}, removes)
+}
+
+func (suite *ShardKeeperTestSuite) TestSync_rateLimit() {
+	suite.shardKeeper.initialized = true
+	suite.shardKeeper.containerOpts.SyncConcurrency = 4
+	suite.shardKeeper.limiter = rate.NewLimiter(rate.Limit(10), 1)
+
+	dvs := make(map[string]*storage.ShardKeeperDbValue)
+	for i := 0; i < 4; i++ {
+		id := fmt.Sprintf("add-%d", i)
+		dvs[id] = &storage.ShardKeeperDbValue{Spec: &storage.ShardSpec{Id: id, Lease: suite.shardDbValue.Spec.Lease}}
+	}
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(dvs, nil)
+	mockedStorage.On("Batch", mock.Anything, mock.Anything).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+	suite.shardKeeper.containerOpts.AppShardImpl = &recordedPrimitives{}
+
+	start := time.Now()
+	err := suite.shardKeeper.sync(context.TODO())
+	assert.Nil(suite.T(), err)
+	// 第一个令牌立即获得，后面3个间隔100ms
+	assert.True(suite.T(), time.Since(start) >= 300*time.Millisecond)
+}
+
+func (suite *ShardKeeperTestSuite) TestSync_state() {
+	suite.shardKeeper.initialized = true
+	suite.shardDbValue.Disp = false
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("ForEach").Return(map[string]*storage.ShardKeeperDbValue{"bar": suite.shardDbValue}, nil)
+	mockedStorage.On("Batch", mock.Anything, mock.Anything).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	// app加载较慢，Add中上报starting
+	mockedPrimitives := new(MockedShardPrimitivesV2)
+	mockedPrimitives.On("Add", mock.Anything, "bar", suite.shardDbValue.Spec).Return(nil).Run(func(args mock.Arguments) {
+		assert.Equal(suite.T(), storage.ShardStateStarting, suite.shardKeeper.State("bar"))
+		assert.NoError(suite.T(), suite.shardKeeper.ReportState("bar", storage.ShardStateStarting))
+	})
+	suite.shardKeeper.containerOpts.AppShardImpl = mockedPrimitives
+
+	assert.Nil(suite.T(), suite.shardKeeper.sync(context.TODO()))
+	assert.Equal(suite.T(), storage.ShardStateStarting, suite.shardKeeper.State("bar"))
+	assert.NoError(suite.T(), suite.shardKeeper.ReportState("bar", storage.ShardStateServing))
+	assert.Equal(suite.T(), storage.ShardStateServing, suite.shardKeeper.State("bar"))
+
+	// 下发失败
+	suite.shardDbValue.Disp = false
+	mockedPrimitives = new(MockedShardPrimitivesV2)
+	mockedPrimitives.On("Add", mock.Anything, "bar", suite.shardDbValue.Spec).Return(errors.New("busy"))
+	suite.shardKeeper.containerOpts.AppShardImpl = mockedPrimitives
+	assert.NotNil(suite.T(), suite.shardKeeper.sync(context.TODO()))
+	assert.Equal(suite.T(), storage.ShardStateFailed, suite.shardKeeper.State("bar"))
+
+	// drop之后回到assigned（不再存在）
+	suite.shardDbValue.Drop = true
+	mockedPrimitives = new(MockedShardPrimitivesV2)
+	mockedPrimitives.On("Drop", mock.Anything, "bar").Return(nil).Run(func(args mock.Arguments) {
+		assert.Equal(suite.T(), storage.ShardStateStopping, suite.shardKeeper.State("bar"))
+	})
+	suite.shardKeeper.containerOpts.AppShardImpl = mockedPrimitives
+	assert.Nil(suite.T(), suite.shardKeeper.sync(context.TODO()))
+	assert.Equal(suite.T(), storage.ShardStateAssigned, suite.shardKeeper.State("bar"))
+	mockedPrimitives.AssertExpectations(suite.T())
+}
+
+// recordedPrimitives 记录调用顺序和最大并发数
+type recordedPrimitives struct {
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	calls      []string
+}
+
+func (r *recordedPrimitives) call(name string) error {
+	r.mu.Lock()
+	r.running++
+	if r.running > r.maxRunning {
+		r.maxRunning = r.running
+	}
+	r.calls = append(r.calls, name)
+	r.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	r.mu.Lock()
+	r.running--
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *recordedPrimitives) Add(_ context.Context, id string, _ *storage.ShardSpec) error {
+	return r.call(id)
+}
+
+func (r *recordedPrimitives) Drop(_ context.Context, id string) error {
+	return r.call(id)
+}
+
 func (suite *ShardKeeperTestSuite) TestDrop_notExist() {
 	fakeShardId := defaultTestPlaceHolder
 
@@ -325,3 +696,86 @@ func (suite *ShardKeeperTestSuite) TestDrop_notExist() {
 	mockedStorage.AssertExpectations(suite.T())
 	assert.Nil(suite.T(), err)
 }
+
+func (suite *ShardKeeperTestSuite) TestHandleResync_typeError() {
+	err := suite.shardKeeper.handleResync("", "")
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *ShardKeeperTestSuite) TestHandleResync_missBridge() {
+	bridge := ShardLease{
+		Lease:        storage.Lease{ID: 101},
+		GuardLeaseID: suite.shardKeeper.guardLease.ID,
+		Assignment:   &Assignment{Drops: []string{"bar"}},
+	}
+	guard := ShardLease{Lease: *suite.shardKeeper.guardLease}
+	resp := clientv3.GetResponse{
+		Kvs: []*mvccpb.KeyValue{
+			{Key: []byte(suite.shardKeeper.containerOpts.Paths.LeaseBridgePath("foo")), Value: []byte(bridge.String()), CreateRevision: 3, ModRevision: 3},
+			{Key: []byte(suite.shardKeeper.containerOpts.Paths.LeaseGuardPath("foo")), Value: []byte(guard.String()), CreateRevision: 1, ModRevision: 2},
+		},
+	}
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("Drop", []string{"bar"}).Return(nil)
+	mockedStorage.On("MigrateLease", bridge.GuardLeaseID, bridge.ID).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	err := suite.shardKeeper.handleResync(resyncTrigger, &resp)
+	mockedStorage.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), bridge.ID, suite.shardKeeper.bridgeLease.ID)
+}
+
+func (suite *ShardKeeperTestSuite) TestHandleResync_missGuard() {
+	suite.shardKeeper.bridgeLease = &storage.Lease{ID: 101}
+	bridge := ShardLease{
+		Lease:        storage.Lease{ID: 101},
+		GuardLeaseID: suite.shardKeeper.guardLease.ID,
+	}
+	guard := ShardLease{Lease: storage.Lease{ID: 102}, BridgeLeaseID: 101}
+	resp := clientv3.GetResponse{
+		Kvs: []*mvccpb.KeyValue{
+			{Key: []byte(suite.shardKeeper.containerOpts.Paths.LeaseBridgePath("foo")), Value: []byte(bridge.String()), CreateRevision: 3, ModRevision: 3},
+			{Key: []byte(suite.shardKeeper.containerOpts.Paths.LeaseGuardPath("foo")), Value: []byte(guard.String()), CreateRevision: 1, ModRevision: 4},
+		},
+	}
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("MigrateLease", bridge.ID, guard.ID).Return(nil)
+	mockedStorage.On("DropByLease", true, guard.ID).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("Put",
+		mock.Anything,
+		suite.shardKeeper.containerOpts.Paths.LeaseSessionPath(suite.shardKeeper.containerOpts.Service, suite.shardKeeper.containerOpts.ContainerId),
+		mock.Anything,
+		mock.Anything).Return(&clientv3.PutResponse{}, nil)
+	suite.shardKeeper.containerOpts.Client = mockedEtcdWrapper
+
+	err := suite.shardKeeper.handleResync(resyncTrigger, &resp)
+	mockedStorage.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), guard.ID, suite.shardKeeper.guardLease.ID)
+	assert.True(suite.T(), suite.shardKeeper.bridgeLease.EqualTo(storage.NoLease))
+}
+
+func (suite *ShardKeeperTestSuite) TestHandleResync_bridgeDeleted() {
+	suite.shardKeeper.bridgeLease = &storage.Lease{ID: 101}
+	guard := ShardLease{Lease: *suite.shardKeeper.guardLease}
+	resp := clientv3.GetResponse{
+		Kvs: []*mvccpb.KeyValue{
+			{Key: []byte(suite.shardKeeper.containerOpts.Paths.LeaseGuardPath("foo")), Value: []byte(guard.String()), CreateRevision: 1, ModRevision: 2},
+		},
+	}
+
+	mockedStorage := new(storage.MockedStorage)
+	mockedStorage.On("DropByLease", false, clientv3.LeaseID(101)).Return(nil)
+	suite.shardKeeper.storage = mockedStorage
+
+	err := suite.shardKeeper.handleResync(resyncTrigger, &resp)
+	mockedStorage.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), suite.shardKeeper.bridgeLease.EqualTo(storage.NoLease))
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/entertainment-venue/sm/pkg/commonutil"
+	"github.com/pkg/errors"
+)
+
+// ShardPrimitivesV2 带有context的 ShardPrimitives ，ctx携带单次调用的deadline，
+// 返回的error通过 Retryable 、 Permanent 、 ErrAlreadyExists 区分处理方式
+type ShardPrimitivesV2 interface {
+	Add(ctx context.Context, id string, spec *storage.ShardSpec) error
+	Drop(ctx context.Context, id string) error
+}
+
+// ShardUpdaterV2 ShardPrimitivesV2 的可选实现，同 ShardUpdater
+type ShardUpdaterV2 interface {
+	Update(ctx context.Context, id string, spec *storage.ShardSpec) error
+}
+
+// ShardLoadReporter ShardPrimitives 或 ShardPrimitivesV2 的可选实现，container心跳时获取每个shard的负载，
+// 返回值中没有的shard不上报负载
+type ShardLoadReporter interface {
+	ShardLoads(ctx context.Context) (map[string]*storage.ShardLoad, error)
+}
+
+var (
+	// ErrAlreadyExists Add时shard已经存在，认为下发成功
+	ErrAlreadyExists = commonutil.ErrExist
+	// ErrNotExists Drop时shard不存在，认为下发成功
+	ErrNotExists = commonutil.ErrNotExist
+)
+
+type shardErrorKind int
+
+const (
+	kindRetryable shardErrorKind = iota + 1
+	kindPermanent
+)
+
+// shardError app返回的分类错误，没有分类的错误按照可重试处理
+type shardError struct {
+	kind shardErrorKind
+	err  error
+}
+
+func (e *shardError) Error() string { return e.err.Error() }
+
+func (e *shardError) Cause() error { return e.err }
+
+func (e *shardError) Unwrap() error { return e.err }
+
+// Retryable 暂时性的错误，ShardKeeper 在下个sync周期重试
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &shardError{kind: kindRetryable, err: err}
+}
+
+// Permanent shard本身有问题，重试也不会成功，ShardKeeper 不再下发该shard
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &shardError{kind: kindPermanent, err: err}
+}
+
+func IsPermanent(err error) bool {
+	var se *shardError
+	return errors.As(err, &se) && se.kind == kindPermanent
+}
+
+func IsRetryable(err error) bool {
+	return err != nil && !IsPermanent(err) && !IsAlreadyExists(err) && !IsNotExists(err)
+}
+
+func IsAlreadyExists(err error) bool {
+	return err != nil && errors.Is(err, ErrAlreadyExists)
+}
+
+func IsNotExists(err error) bool {
+	return err != nil && errors.Is(err, ErrNotExists)
+}
+
+// NewShardPrimitivesV2 兼容 ShardPrimitives 的实现，v1接口不支持取消，ctx只在调用前检查，
+// v1实现了 ShardUpdater 时，返回值同时实现 ShardUpdaterV2
+func NewShardPrimitivesV2(v1 ShardPrimitives) ShardPrimitivesV2 {
+	if v1 == nil {
+		return nil
+	}
+	a := shardPrimitivesAdapter{v1: v1}
+	if updater, ok := v1.(ShardUpdater); ok {
+		return &shardUpdaterAdapter{shardPrimitivesAdapter: a, updater: updater}
+	}
+	return &a
+}
+
+type shardPrimitivesAdapter struct {
+	v1 ShardPrimitives
+}
+
+func (a *shardPrimitivesAdapter) Add(ctx context.Context, id string, spec *storage.ShardSpec) error {
+	if err := ctx.Err(); err != nil {
+		return Retryable(err)
+	}
+	return a.v1.Add(id, spec)
+}
+
+func (a *shardPrimitivesAdapter) Drop(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return Retryable(err)
+	}
+	return a.v1.Drop(id)
+}
+
+// ShardLoads v1实现了 ShardLoadReporter 时转发，否则不上报
+func (a *shardPrimitivesAdapter) ShardLoads(ctx context.Context) (map[string]*storage.ShardLoad, error) {
+	if reporter, ok := a.v1.(ShardLoadReporter); ok {
+		return reporter.ShardLoads(ctx)
+	}
+	return nil, nil
+}
+
+type shardUpdaterAdapter struct {
+	shardPrimitivesAdapter
+	updater ShardUpdater
+}
+
+func (a *shardUpdaterAdapter) Update(ctx context.Context, id string, spec *storage.ShardSpec) error {
+	if err := ctx.Err(); err != nil {
+		return Retryable(err)
+	}
+	return a.updater.Update(id, spec)
+}
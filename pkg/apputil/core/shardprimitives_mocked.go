@@ -1,6 +1,8 @@
 package core
 
 import (
+	"context"
+
 	"github.com/entertainment-venue/sm/pkg/apputil/storage"
 	"github.com/stretchr/testify/mock"
 )
@@ -22,3 +24,47 @@ func (m *MockedShardPrimitives) Drop(id string) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
+
+var (
+	_ ShardUpdater = new(MockedShardUpdater)
+)
+
+type MockedShardUpdater struct {
+	MockedShardPrimitives
+}
+
+func (m *MockedShardUpdater) Update(id string, spec *storage.ShardSpec) error {
+	args := m.Called(id, spec)
+	return args.Error(0)
+}
+
+var (
+	_ ShardPrimitivesV2 = new(MockedShardPrimitivesV2)
+)
+
+type MockedShardPrimitivesV2 struct {
+	mock.Mock
+}
+
+func (m *MockedShardPrimitivesV2) Add(ctx context.Context, id string, spec *storage.ShardSpec) error {
+	args := m.Called(ctx, id, spec)
+	return args.Error(0)
+}
+
+func (m *MockedShardPrimitivesV2) Drop(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+var (
+	_ ShardUpdaterV2 = new(MockedShardUpdaterV2)
+)
+
+type MockedShardUpdaterV2 struct {
+	MockedShardPrimitivesV2
+}
+
+func (m *MockedShardUpdaterV2) Update(ctx context.Context, id string, spec *storage.ShardSpec) error {
+	args := m.Called(ctx, id, spec)
+	return args.Error(0)
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_classify(t *testing.T) {
+	err := errors.New("foo")
+	assert.True(t, IsRetryable(err))
+	assert.True(t, IsRetryable(Retryable(err)))
+	assert.False(t, IsPermanent(err))
+
+	// 经过Wrap之后仍然可以识别
+	perm := pkgerrors.Wrap(Permanent(err), "")
+	assert.True(t, IsPermanent(perm))
+	assert.False(t, IsRetryable(perm))
+	assert.Equal(t, err, pkgerrors.Cause(Permanent(err)))
+
+	assert.True(t, IsAlreadyExists(pkgerrors.Wrap(ErrAlreadyExists, "")))
+	assert.False(t, IsRetryable(ErrAlreadyExists))
+	assert.True(t, IsNotExists(ErrNotExists))
+
+	assert.Nil(t, Retryable(nil))
+	assert.Nil(t, Permanent(nil))
+	assert.False(t, IsRetryable(nil))
+}
+
+func Test_NewShardPrimitivesV2(t *testing.T) {
+	assert.Nil(t, NewShardPrimitivesV2(nil))
+
+	spec := &storage.ShardSpec{Id: "s1"}
+	primitives := new(MockedShardPrimitives)
+	primitives.On("Add", "s1", spec).Return(nil)
+	v2 := NewShardPrimitivesV2(primitives)
+	_, ok := v2.(ShardUpdaterV2)
+	assert.False(t, ok)
+	assert.NoError(t, v2.Add(context.TODO(), "s1", spec))
+
+	// ctx已经结束，不调用v1
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	assert.True(t, IsRetryable(v2.Drop(ctx, "s1")))
+	primitives.AssertNotCalled(t, "Drop", "s1")
+
+	updater := new(MockedShardUpdater)
+	updater.On("Update", "s1", spec).Return(nil)
+	v2 = NewShardPrimitivesV2(updater)
+	u, ok := v2.(ShardUpdaterV2)
+	assert.True(t, ok)
+	assert.NoError(t, u.Update(context.TODO(), "s1", spec))
+	updater.AssertExpectations(t)
+}
+
+type loadPrimitives struct {
+	MockedShardPrimitives
+}
+
+func (p *loadPrimitives) ShardLoads(_ context.Context) (map[string]*storage.ShardLoad, error) {
+	return map[string]*storage.ShardLoad{"s1": {QPS: 1}}, nil
+}
+
+func Test_NewShardPrimitivesV2_shardLoads(t *testing.T) {
+	// v1没有实现时不上报
+	reporter, ok := NewShardPrimitivesV2(new(MockedShardPrimitives)).(ShardLoadReporter)
+	assert.True(t, ok)
+	loads, err := reporter.ShardLoads(context.TODO())
+	assert.NoError(t, err)
+	assert.Nil(t, loads)
+
+	reporter = NewShardPrimitivesV2(new(loadPrimitives)).(ShardLoadReporter)
+	loads, err = reporter.ShardLoads(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, loads["s1"].QPS)
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/pkg/errors"
+)
+
+// shardStates 记录已经开始下发给app的shard的状态，没有记录的shard是 storage.ShardStateAssigned
+type shardStates struct {
+	mu     sync.Mutex
+	states map[string]*shardStateEntry
+}
+
+type shardStateEntry struct {
+	state storage.ShardState
+
+	// reported app在本次下发之后主动上报过状态，Add返回之后不再默认设置为serving
+	reported bool
+}
+
+// set sync下发前调用，清除app上报的标记
+func (s *shardStates) set(id string, state storage.ShardState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states == nil {
+		s.states = make(map[string]*shardStateEntry)
+	}
+	s.states[id] = &shardStateEntry{state: state}
+}
+
+// started app的Add或者Update返回成功，app没有主动上报时认为已经可以提供服务
+func (s *shardStates) started(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.states[id]; ok && !entry.reported {
+		entry.state = storage.ShardStateServing
+	}
+}
+
+func (s *shardStates) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, id)
+}
+
+func (s *shardStates) report(id string, state storage.ShardState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.states[id]
+	if !ok {
+		return errors.Wrapf(ErrNotExists, "shard [%s] not dispatched", id)
+	}
+	entry.state = state
+	entry.reported = true
+	return nil
+}
+
+func (s *shardStates) get(id string) storage.ShardState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.states[id]; ok {
+		return entry.state
+	}
+	return storage.ShardStateAssigned
+}
+
+// ReportState app上报shard的状态，app加载较慢时，在Add中上报starting，加载完成后上报serving，
+// 只接受已经下发给app的shard，assigned由sm维护，不允许上报
+func (sk *ShardKeeper) ReportState(id string, state storage.ShardState) error {
+	if err := state.Validate(); err != nil {
+		return err
+	}
+	if state == storage.ShardStateAssigned {
+		return errors.New("assigned can not be reported")
+	}
+	return sk.states.report(id, state)
+}
+
+// State 心跳中携带的shard状态
+func (sk *ShardKeeper) State(id string) storage.ShardState {
+	return sk.states.get(id)
+}
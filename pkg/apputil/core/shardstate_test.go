@@ -0,0 +1,35 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ShardKeeper_ReportState(t *testing.T) {
+	sk := ShardKeeper{}
+	assert.Equal(t, storage.ShardStateAssigned, sk.State("s1"))
+
+	// 没有下发的shard不接受上报
+	assert.True(t, IsNotExists(sk.ReportState("s1", storage.ShardStateServing)))
+
+	sk.states.set("s1", storage.ShardStateStarting)
+	assert.Error(t, sk.ReportState("s1", storage.ShardStateAssigned))
+	assert.Error(t, sk.ReportState("s1", "unknown"))
+
+	// app上报过之后，Add返回不会覆盖app的状态
+	assert.NoError(t, sk.ReportState("s1", storage.ShardStateStarting))
+	sk.states.started("s1")
+	assert.Equal(t, storage.ShardStateStarting, sk.State("s1"))
+	assert.NoError(t, sk.ReportState("s1", storage.ShardStateServing))
+	assert.Equal(t, storage.ShardStateServing, sk.State("s1"))
+
+	// 重新下发时清除上报标记
+	sk.states.set("s1", storage.ShardStateStarting)
+	sk.states.started("s1")
+	assert.Equal(t, storage.ShardStateServing, sk.State("s1"))
+
+	sk.states.remove("s1")
+	assert.Equal(t, storage.ShardStateAssigned, sk.State("s1"))
+}
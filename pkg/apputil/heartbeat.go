@@ -0,0 +1,266 @@
+package apputil
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/heartbeatpb"
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// HeartbeatFormat container心跳写入etcd的格式
+type HeartbeatFormat string
+
+const (
+	// HeartbeatFull 默认格式，每次心跳携带shard的完整信息，兼容旧版本的smserver
+	HeartbeatFull HeartbeatFormat = "full"
+	// HeartbeatCompact shard只携带id、lease和状态，两次全量心跳之间只上报变化的shard
+	HeartbeatCompact HeartbeatFormat = "compact"
+	// HeartbeatCompactProto 同 HeartbeatCompact ，使用protobuf编码
+	HeartbeatCompactProto HeartbeatFormat = "compact-proto"
+)
+
+// defaultHeartbeatSnapshotInterval 紧凑格式下每10次心跳(30s)写入一次全量心跳
+const defaultHeartbeatSnapshotInterval = 10
+
+// CompactShard 紧凑格式心跳中的shard，不携带spec和task
+type CompactShard struct {
+	Id      string             `json:"id"`
+	LeaseID clientv3.LeaseID   `json:"leaseID"`
+	State   storage.ShardState `json:"state,omitempty"`
+	// TaskHash 见 storage.TaskHash
+	TaskHash uint32             `json:"taskHash"`
+	Disp     bool               `json:"disp,omitempty"`
+	Drop     bool               `json:"drop,omitempty"`
+	Load     *storage.ShardLoad `json:"load,omitempty"`
+}
+
+func newCompactShard(dv *storage.ShardKeeperDbValue) *CompactShard {
+	return &CompactShard{
+		Id:       dv.Spec.Id,
+		LeaseID:  dv.Spec.Lease.ID,
+		State:    dv.State,
+		TaskHash: storage.TaskHash(dv.Spec.Task),
+		Disp:     dv.Disp,
+		Drop:     dv.Drop,
+		Load:     dv.Load,
+	}
+}
+
+func (s *CompactShard) equal(o *CompactShard) bool {
+	if s.Id != o.Id || s.LeaseID != o.LeaseID || s.State != o.State || s.TaskHash != o.TaskHash || s.Disp != o.Disp || s.Drop != o.Drop {
+		return false
+	}
+	if s.Load == nil || o.Load == nil {
+		return s.Load == o.Load
+	}
+	return *s.Load == *o.Load
+}
+
+// IsDelta 增量心跳需要通过 Merge 合并BaseRevision对应的全量心跳之后才能使用
+func (l *ContainerHeartbeat) IsDelta() bool {
+	return l.BaseRevision != 0
+}
+
+// ShardSummaries 统一完整格式和紧凑格式的shard，增量心跳只包含变化的shard
+func (l *ContainerHeartbeat) ShardSummaries() []*CompactShard {
+	if len(l.Shards) == 0 {
+		return l.CompactShards
+	}
+	summaries := make([]*CompactShard, 0, len(l.Shards))
+	for _, dv := range l.Shards {
+		summaries = append(summaries, newCompactShard(dv))
+	}
+	return summaries
+}
+
+// Merge 把增量心跳展开为紧凑格式的全量心跳，snapshot是BaseRevision对应的全量心跳
+func (l *ContainerHeartbeat) Merge(snapshot *ContainerHeartbeat) error {
+	if !l.IsDelta() {
+		return errors.New("not delta heartbeat")
+	}
+	if snapshot.IsDelta() {
+		return errors.New("snapshot is delta heartbeat")
+	}
+
+	shards := make(map[string]*CompactShard)
+	for _, s := range snapshot.ShardSummaries() {
+		shards[s.Id] = s
+	}
+	for _, id := range l.Removed {
+		delete(shards, id)
+	}
+	for _, s := range l.CompactShards {
+		shards[s.Id] = s
+	}
+
+	l.BaseRevision = 0
+	l.Removed = nil
+	l.CompactShards = sortedShards(shards)
+	return nil
+}
+
+// Marshal HeartbeatCompactProto 使用protobuf编码，其他格式使用json
+func (l *ContainerHeartbeat) Marshal(format HeartbeatFormat) ([]byte, error) {
+	if format != HeartbeatCompactProto {
+		b, err := json.Marshal(l)
+		return b, errors.Wrap(err, "")
+	}
+
+	load, err := json.Marshal(&l.ContainerLoad)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	pb := heartbeatpb.ContainerHeartbeat{
+		Timestamp:    l.Timestamp,
+		BaseRevision: l.BaseRevision,
+		Removed:      l.Removed,
+		Load:         load,
+		Leaving:      l.Leaving,
+	}
+	for _, s := range l.ShardSummaries() {
+		shard := &heartbeatpb.Shard{
+			Id:       s.Id,
+			LeaseId:  int64(s.LeaseID),
+			State:    string(s.State),
+			TaskHash: s.TaskHash,
+			Disp:     s.Disp,
+			Drop:     s.Drop,
+		}
+		if s.Load != nil {
+			shard.Load = &heartbeatpb.ShardLoad{Qps: s.Load.QPS, Bytes: s.Load.Bytes, Cost: s.Load.Cost}
+		}
+		pb.Shards = append(pb.Shards, shard)
+	}
+	b, err := proto.Marshal(&pb)
+	return b, errors.Wrap(err, "")
+}
+
+// DecodeContainerHeartbeat 兼容json和protobuf编码，json以'{'开始，protobuf编码的第一个字节不会是'{'
+func DecodeContainerHeartbeat(b []byte) (*ContainerHeartbeat, error) {
+	if len(b) == 0 {
+		return nil, errors.New("empty heartbeat")
+	}
+
+	var hb ContainerHeartbeat
+	if b[0] == '{' {
+		if err := json.Unmarshal(b, &hb); err != nil {
+			return nil, errors.Wrap(err, string(b))
+		}
+		return &hb, nil
+	}
+
+	var pb heartbeatpb.ContainerHeartbeat
+	if err := proto.Unmarshal(b, &pb); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	hb.Timestamp = pb.Timestamp
+	hb.BaseRevision = pb.BaseRevision
+	hb.Removed = pb.Removed
+	hb.Leaving = pb.Leaving
+	if len(pb.Load) > 0 {
+		if err := json.Unmarshal(pb.Load, &hb.ContainerLoad); err != nil {
+			return nil, errors.Wrap(err, string(pb.Load))
+		}
+	}
+	for _, s := range pb.Shards {
+		shard := &CompactShard{
+			Id:       s.Id,
+			LeaseID:  clientv3.LeaseID(s.LeaseId),
+			State:    storage.ShardState(s.State),
+			TaskHash: s.TaskHash,
+			Disp:     s.Disp,
+			Drop:     s.Drop,
+		}
+		if s.Load != nil {
+			shard.Load = &storage.ShardLoad{QPS: s.Load.Qps, Bytes: s.Load.Bytes, Cost: s.Load.Cost}
+		}
+		hb.CompactShards = append(hb.CompactShards, shard)
+	}
+	return &hb, nil
+}
+
+func sortedShards(shards map[string]*CompactShard) []*CompactShard {
+	r := make([]*CompactShard, 0, len(shards))
+	for _, s := range shards {
+		r = append(r, s)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Id < r[j].Id })
+	return r
+}
+
+// heartbeatEncoder 紧凑格式下生成全量或者增量心跳，增量是相对于上次写入成功的全量心跳，
+// smserver合并或者丢弃中间的心跳事件都不影响结果
+type heartbeatEncoder struct {
+	format           HeartbeatFormat
+	snapshotInterval int
+
+	// count 上次全量心跳之后写入的增量心跳数量
+	count int
+	// baseRevision 和 base 是上次写入成功的全量心跳，baseRevision为0时下次心跳是全量
+	baseRevision int64
+	base         map[string]*CompactShard
+	// pending 正在写入的全量心跳
+	pending map[string]*CompactShard
+}
+
+func newHeartbeatEncoder(format HeartbeatFormat, snapshotInterval int) *heartbeatEncoder {
+	if format == "" {
+		format = HeartbeatFull
+	}
+	if snapshotInterval <= 0 {
+		snapshotInterval = defaultHeartbeatSnapshotInterval
+	}
+	return &heartbeatEncoder{format: format, snapshotInterval: snapshotInterval}
+}
+
+// encode 把shards按照格式填充到hb中，返回写入etcd的内容
+func (e *heartbeatEncoder) encode(hb *ContainerHeartbeat, shards []*storage.ShardKeeperDbValue) ([]byte, error) {
+	e.pending = nil
+	if e.format == HeartbeatFull {
+		hb.Shards = shards
+		return hb.Marshal(e.format)
+	}
+
+	cur := make(map[string]*CompactShard, len(shards))
+	for _, dv := range shards {
+		cur[dv.Spec.Id] = newCompactShard(dv)
+	}
+
+	if e.baseRevision == 0 || e.count+1 >= e.snapshotInterval {
+		hb.CompactShards = sortedShards(cur)
+		e.pending = cur
+		return hb.Marshal(e.format)
+	}
+
+	changed := make(map[string]*CompactShard)
+	for id, s := range cur {
+		if old, ok := e.base[id]; !ok || !old.equal(s) {
+			changed[id] = s
+		}
+	}
+	for id := range e.base {
+		if _, ok := cur[id]; !ok {
+			hb.Removed = append(hb.Removed, id)
+		}
+	}
+	sort.Strings(hb.Removed)
+	hb.BaseRevision = e.baseRevision
+	hb.CompactShards = sortedShards(changed)
+	return hb.Marshal(e.format)
+}
+
+// written 心跳写入成功，全量心跳的revision作为后续增量心跳的base
+func (e *heartbeatEncoder) written(revision int64) {
+	if e.pending == nil {
+		e.count++
+		return
+	}
+	e.base = e.pending
+	e.baseRevision = revision
+	e.pending = nil
+	e.count = 0
+}
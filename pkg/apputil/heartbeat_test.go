@@ -0,0 +1,123 @@
+package apputil
+
+import (
+	"testing"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestShard(id string, state storage.ShardState) *storage.ShardKeeperDbValue {
+	return &storage.ShardKeeperDbValue{
+		Spec:  &storage.ShardSpec{Id: id, Task: "task-" + id, Lease: &storage.Lease{ID: 1}},
+		Disp:  true,
+		State: state,
+	}
+}
+
+func encodeHeartbeat(t *testing.T, e *heartbeatEncoder, shards ...*storage.ShardKeeperDbValue) *ContainerHeartbeat {
+	value, err := e.encode(&ContainerHeartbeat{Heartbeat: Heartbeat{Timestamp: 1}}, shards)
+	assert.Nil(t, err)
+	hb, err := DecodeContainerHeartbeat(value)
+	assert.Nil(t, err)
+	return hb
+}
+
+func Test_heartbeatEncoder_full(t *testing.T) {
+	e := newHeartbeatEncoder("", 0)
+	hb := encodeHeartbeat(t, e, newTestShard("s1", storage.ShardStateServing))
+	assert.False(t, hb.IsDelta())
+	assert.Len(t, hb.Shards, 1)
+	assert.Equal(t, "task-s1", hb.Shards[0].Spec.Task)
+
+	summaries := hb.ShardSummaries()
+	assert.Len(t, summaries, 1)
+	assert.Equal(t, storage.TaskHash("task-s1"), summaries[0].TaskHash)
+	assert.Equal(t, storage.ShardStateServing, summaries[0].State)
+}
+
+func Test_heartbeatEncoder_delta(t *testing.T) {
+	for _, format := range []HeartbeatFormat{HeartbeatCompact, HeartbeatCompactProto} {
+		e := newHeartbeatEncoder(format, 3)
+		s1 := newTestShard("s1", storage.ShardStateStarting)
+		s2 := newTestShard("s2", storage.ShardStateServing)
+
+		snapshot := encodeHeartbeat(t, e, s1, s2)
+		assert.False(t, snapshot.IsDelta())
+		assert.Nil(t, snapshot.Shards)
+		assert.Len(t, snapshot.CompactShards, 2)
+		e.written(100)
+
+		// 只上报变化的shard
+		s1 = newTestShard("s1", storage.ShardStateServing)
+		s3 := newTestShard("s3", storage.ShardStateStarting)
+		delta := encodeHeartbeat(t, e, s1, s3)
+		assert.True(t, delta.IsDelta())
+		assert.Equal(t, int64(100), delta.BaseRevision)
+		assert.Equal(t, []string{"s2"}, delta.Removed)
+		assert.Len(t, delta.CompactShards, 2)
+		e.written(101)
+
+		// 相对于全量心跳，不是上次的增量心跳
+		delta = encodeHeartbeat(t, e, s1, s3)
+		assert.Equal(t, int64(100), delta.BaseRevision)
+		assert.Len(t, delta.CompactShards, 2)
+		assert.Nil(t, delta.Merge(snapshot))
+		assert.False(t, delta.IsDelta())
+		assert.Len(t, delta.CompactShards, 2)
+		assert.Equal(t, "s1", delta.CompactShards[0].Id)
+		assert.Equal(t, storage.ShardStateServing, delta.CompactShards[0].State)
+		assert.Equal(t, "s3", delta.CompactShards[1].Id)
+		e.written(102)
+
+		// 达到间隔后重新写入全量心跳
+		snapshot = encodeHeartbeat(t, e, s1, s3)
+		assert.False(t, snapshot.IsDelta(), format)
+		assert.Len(t, snapshot.CompactShards, 2)
+		e.written(103)
+		assert.Equal(t, int64(103), e.baseRevision)
+	}
+}
+
+func Test_heartbeatEncoder_writeFailed(t *testing.T) {
+	e := newHeartbeatEncoder(HeartbeatCompact, 10)
+	s1 := newTestShard("s1", storage.ShardStateServing)
+
+	// 全量心跳没有写入成功，下次仍然是全量心跳
+	hb := encodeHeartbeat(t, e, s1)
+	assert.False(t, hb.IsDelta())
+	hb = encodeHeartbeat(t, e, s1)
+	assert.False(t, hb.IsDelta())
+	e.written(10)
+
+	hb = encodeHeartbeat(t, e, s1)
+	assert.True(t, hb.IsDelta())
+	assert.Empty(t, hb.CompactShards)
+	assert.Empty(t, hb.Removed)
+}
+
+func Test_ContainerHeartbeat_Merge(t *testing.T) {
+	snapshot := &ContainerHeartbeat{Shards: []*storage.ShardKeeperDbValue{newTestShard("s1", storage.ShardStateServing)}}
+	delta := &ContainerHeartbeat{BaseRevision: 1, CompactShards: []*CompactShard{{Id: "s2"}}}
+	assert.Nil(t, delta.Merge(snapshot))
+	assert.Len(t, delta.CompactShards, 2)
+
+	assert.NotNil(t, snapshot.Merge(delta))
+	assert.NotNil(t, (&ContainerHeartbeat{BaseRevision: 1}).Merge(&ContainerHeartbeat{BaseRevision: 1}))
+}
+
+func Test_DecodeContainerHeartbeat(t *testing.T) {
+	_, err := DecodeContainerHeartbeat(nil)
+	assert.NotNil(t, err)
+
+	hb := ContainerHeartbeat{Heartbeat: Heartbeat{Timestamp: 1}, ContainerLoad: ContainerLoad{CPUUsedPercent: 10}, Leaving: true}
+	hb.CompactShards = []*CompactShard{{Id: "s1", Load: &storage.ShardLoad{QPS: 1}}}
+	value, err := hb.Marshal(HeartbeatCompactProto)
+	assert.Nil(t, err)
+	decoded, err := DecodeContainerHeartbeat(value)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), decoded.Timestamp)
+	assert.Equal(t, float64(10), decoded.CPUUsedPercent)
+	assert.True(t, decoded.Leaving)
+	assert.Equal(t, 1.0, decoded.CompactShards[0].Load.QPS)
+}
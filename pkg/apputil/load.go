@@ -0,0 +1,346 @@
+package apputil
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// defaultCgroupDir cgroup v2的挂载路径
+const defaultCgroupDir = "/sys/fs/cgroup"
+
+// ContainerLoad container的负载，通过heartbeat上报给smserver，采集失败的项保持零值
+type ContainerLoad struct {
+	VirtualMemoryStat  *mem.VirtualMemoryStat `json:"virtualMemoryStat"`
+	CPUUsedPercent     float64                `json:"cpuUsedPercent"`
+	DiskIOCountersStat []*disk.IOCountersStat `json:"diskIOCountersStat"`
+	NetIOCountersStat  *net.IOCountersStat    `json:"netIOCountersStat"`
+}
+
+// LoadReporter 采集container的负载，部分采集失败时，返回已经采集到的数据和error，
+// heartbeat使用返回的数据继续上报
+type LoadReporter interface {
+	Load(ctx context.Context) (*ContainerLoad, error)
+}
+
+// LoadReporterFunc app自己采集负载时使用
+type LoadReporterFunc func(ctx context.Context) (*ContainerLoad, error)
+
+func (f LoadReporterFunc) Load(ctx context.Context) (*ContainerLoad, error) {
+	return f(ctx)
+}
+
+// loadErrors 记录采集失败的项，不中断其他项的采集
+type loadErrors []string
+
+func (e *loadErrors) add(item string, err error) {
+	*e = append(*e, item+": "+err.Error())
+}
+
+func (e loadErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return errors.Errorf("load partially collected, %s", strings.Join(e, "; "))
+}
+
+type gopsutilLoadReporter struct{}
+
+// NewGopsutilLoadReporter 默认的 LoadReporter ，采集的是host的负载
+func NewGopsutilLoadReporter() LoadReporter {
+	return &gopsutilLoadReporter{}
+}
+
+func (r *gopsutilLoadReporter) Load(ctx context.Context) (*ContainerLoad, error) {
+	var (
+		ld   ContainerLoad
+		errs loadErrors
+	)
+
+	// 内存使用比率
+	if vm, err := mem.VirtualMemoryWithContext(ctx); err != nil {
+		errs.add("memory", err)
+	} else {
+		ld.VirtualMemoryStat = vm
+	}
+
+	// cpu使用比率
+	if cp, err := cpu.PercentWithContext(ctx, 0, false); err != nil {
+		errs.add("cpu", err)
+	} else if len(cp) > 0 {
+		ld.CPUUsedPercent = cp[0]
+	}
+
+	// 磁盘io使用比率
+	if diskIOCounters, err := disk.IOCountersWithContext(ctx); err != nil {
+		errs.add("disk", err)
+	} else {
+		ld.DiskIOCountersStat = diskStats(diskIOCounters)
+	}
+
+	// 网路io使用比率
+	if nio, err := netIOCounters(ctx); err != nil {
+		errs.add("net", err)
+	} else {
+		ld.NetIOCountersStat = nio
+	}
+	return &ld, errs.err()
+}
+
+func diskStats(counters map[string]disk.IOCountersStat) []*disk.IOCountersStat {
+	stats := make([]*disk.IOCountersStat, 0, len(counters))
+	for _, v := range counters {
+		v := v
+		stats = append(stats, &v)
+	}
+	return stats
+}
+
+// netIOCounters 网络namespace是容器隔离的，cgroup下也使用gopsutil获取
+func netIOCounters(ctx context.Context) (*net.IOCountersStat, error) {
+	counters, err := net.IOCountersWithContext(ctx, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	if len(counters) == 0 {
+		return nil, errors.New("no net io counters")
+	}
+	return &counters[0], nil
+}
+
+// cgroupLoadReporter 从cgroup v2的接口文件采集负载，在k8s等容器环境中反映的是容器自身的资源使用
+type cgroupLoadReporter struct {
+	dir string
+
+	mu sync.Mutex
+	// lastUsage 和 lastTime 记录上次采集时cpu.stat中的usage_usec，cpu使用率基于两次采集的差值计算
+	lastUsage uint64
+	lastTime  time.Time
+}
+
+// NewCgroupLoadReporter dir为空时使用 /sys/fs/cgroup ，首次采集时没有cpu使用率
+func NewCgroupLoadReporter(dir string) LoadReporter {
+	if dir == "" {
+		dir = defaultCgroupDir
+	}
+	return &cgroupLoadReporter{dir: dir}
+}
+
+func (r *cgroupLoadReporter) Load(ctx context.Context) (*ContainerLoad, error) {
+	var (
+		ld   ContainerLoad
+		errs loadErrors
+	)
+
+	if vm, err := r.memory(ctx); err != nil {
+		errs.add("memory", err)
+	} else {
+		ld.VirtualMemoryStat = vm
+	}
+
+	if percent, err := r.cpuPercent(); err != nil {
+		errs.add("cpu", err)
+	} else {
+		ld.CPUUsedPercent = percent
+	}
+
+	if stats, err := r.io(); err != nil {
+		errs.add("disk", err)
+	} else {
+		ld.DiskIOCountersStat = stats
+	}
+
+	if nio, err := netIOCounters(ctx); err != nil {
+		errs.add("net", err)
+	} else {
+		ld.NetIOCountersStat = nio
+	}
+	return &ld, errs.err()
+}
+
+// memory memory.max是max时没有限制，使用host的内存总量
+func (r *cgroupLoadReporter) memory(ctx context.Context) (*mem.VirtualMemoryStat, error) {
+	used, err := r.readUint("memory.current")
+	if err != nil {
+		return nil, err
+	}
+	vm := mem.VirtualMemoryStat{Used: used}
+
+	limit, err := r.readFile("memory.max")
+	if err != nil {
+		return nil, err
+	}
+	if limit == "max" {
+		host, err := mem.VirtualMemoryWithContext(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "")
+		}
+		vm.Total = host.Total
+	} else {
+		vm.Total, err = strconv.ParseUint(limit, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "")
+		}
+	}
+	if vm.Total > 0 {
+		if vm.Total > vm.Used {
+			vm.Available = vm.Total - vm.Used
+		}
+		vm.UsedPercent = float64(vm.Used) / float64(vm.Total) * 100
+	}
+	return &vm, nil
+}
+
+// cpuPercent 和gopsutil保持一致，100表示用满分配给容器的所有cpu
+func (r *cgroupLoadReporter) cpuPercent() (float64, error) {
+	stat, err := r.readKeyValues("cpu.stat")
+	if err != nil {
+		return 0, err
+	}
+	usage, ok := stat["usage_usec"]
+	if !ok {
+		return 0, errors.New("usage_usec not found in cpu.stat")
+	}
+	cpus, err := r.cpus()
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	lastUsage, lastTime := r.lastUsage, r.lastTime
+	r.lastUsage, r.lastTime = usage, now
+	if lastTime.IsZero() || usage < lastUsage {
+		return 0, nil
+	}
+	elapsed := float64(now.Sub(lastTime).Microseconds())
+	if elapsed <= 0 {
+		return 0, nil
+	}
+	return float64(usage-lastUsage) / (elapsed * cpus) * 100, nil
+}
+
+// cpus cpu.max格式为 "$MAX $PERIOD"，没有限制或者文件不存在时使用机器的cpu数量
+func (r *cgroupLoadReporter) cpus() (float64, error) {
+	content, err := r.readFile("cpu.max")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return float64(runtime.NumCPU()), nil
+		}
+		return 0, err
+	}
+	fields := strings.Fields(content)
+	if len(fields) != 2 || fields[0] == "max" {
+		return float64(runtime.NumCPU()), nil
+	}
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "")
+	}
+	period, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "")
+	}
+	if quota <= 0 || period <= 0 {
+		return float64(runtime.NumCPU()), nil
+	}
+	return quota / period, nil
+}
+
+// io io.stat每行一个设备，格式为 "$MAJ:$MIN rbytes=1 wbytes=2 rios=3 wios=4 ..."
+func (r *cgroupLoadReporter) io() ([]*disk.IOCountersStat, error) {
+	f, err := os.Open(filepath.Join(r.dir, "io.stat"))
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	defer f.Close()
+
+	var stats []*disk.IOCountersStat
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		stat := disk.IOCountersStat{Name: fields[0]}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			v, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch kv[0] {
+			case "rbytes":
+				stat.ReadBytes = v
+			case "wbytes":
+				stat.WriteBytes = v
+			case "rios":
+				stat.ReadCount = v
+			case "wios":
+				stat.WriteCount = v
+			}
+		}
+		stats = append(stats, &stat)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return stats, nil
+}
+
+func (r *cgroupLoadReporter) readFile(name string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(r.dir, name))
+	if err != nil {
+		return "", errors.Wrap(err, "")
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (r *cgroupLoadReporter) readUint(name string) (uint64, error) {
+	content, err := r.readFile(name)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(content, 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "")
+	}
+	return v, nil
+}
+
+// readKeyValues 读取cpu.stat这类每行 "key value" 格式的文件
+func (r *cgroupLoadReporter) readKeyValues(name string) (map[string]uint64, error) {
+	content, err := r.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	kvs := make(map[string]uint64)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		kvs[fields[0]] = v
+	}
+	return kvs, nil
+}
@@ -0,0 +1,80 @@
+package apputil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCgroupFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_cgroupLoadReporter(t *testing.T) {
+	dir := t.TempDir()
+	writeCgroupFiles(t, dir, map[string]string{
+		"memory.current": "256\n",
+		"memory.max":     "1024\n",
+		"cpu.stat":       "usage_usec 1000\nuser_usec 600\nsystem_usec 400\n",
+		"cpu.max":        "200000 100000\n",
+		"io.stat":        "8:0 rbytes=10 wbytes=20 rios=1 wios=2 dbytes=0 dios=0\n",
+	})
+
+	r := NewCgroupLoadReporter(dir)
+	ld, _ := r.Load(context.TODO())
+	assert.Equal(t, uint64(1024), ld.VirtualMemoryStat.Total)
+	assert.Equal(t, uint64(256), ld.VirtualMemoryStat.Used)
+	assert.Equal(t, uint64(768), ld.VirtualMemoryStat.Available)
+	assert.Equal(t, float64(25), ld.VirtualMemoryStat.UsedPercent)
+	// 首次采集没有cpu使用率
+	assert.Equal(t, float64(0), ld.CPUUsedPercent)
+	assert.Len(t, ld.DiskIOCountersStat, 1)
+	assert.Equal(t, "8:0", ld.DiskIOCountersStat[0].Name)
+	assert.Equal(t, uint64(10), ld.DiskIOCountersStat[0].ReadBytes)
+	assert.Equal(t, uint64(20), ld.DiskIOCountersStat[0].WriteBytes)
+	assert.Equal(t, uint64(1), ld.DiskIOCountersStat[0].ReadCount)
+	assert.Equal(t, uint64(2), ld.DiskIOCountersStat[0].WriteCount)
+
+	cpus, err := r.(*cgroupLoadReporter).cpus()
+	assert.Nil(t, err)
+	assert.Equal(t, float64(2), cpus)
+
+	writeCgroupFiles(t, dir, map[string]string{"cpu.stat": "usage_usec 1000000000\n"})
+	ld, _ = r.Load(context.TODO())
+	assert.Greater(t, ld.CPUUsedPercent, float64(0))
+}
+
+func Test_cgroupLoadReporter_partial(t *testing.T) {
+	dir := t.TempDir()
+	writeCgroupFiles(t, dir, map[string]string{
+		"memory.current": "256\n",
+		"io.stat":        "8:0 rbytes=10 wbytes=20 rios=1 wios=2\n",
+	})
+
+	// memory.max和cpu.stat缺失，其他项正常上报
+	ld, err := NewCgroupLoadReporter(dir).Load(context.TODO())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "memory")
+	assert.Contains(t, err.Error(), "cpu")
+	assert.Nil(t, ld.VirtualMemoryStat)
+	assert.Len(t, ld.DiskIOCountersStat, 1)
+}
+
+func Test_Container_containerLoad(t *testing.T) {
+	partial := &ContainerLoad{CPUUsedPercent: 10}
+	ctr := Container{opts: &containerOptions{
+		service: "foo",
+		loadReporter: LoadReporterFunc(func(ctx context.Context) (*ContainerLoad, error) {
+			return partial, errors.New("memory: foo")
+		}),
+	}}
+	assert.Equal(t, partial, ctr.containerLoad(context.TODO()))
+}
@@ -0,0 +1,256 @@
+package receiver
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/core"
+	"github.com/entertainment-venue/sm/pkg/commonutil"
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+	"github.com/entertainment-venue/sm/pkg/logutil"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	ActionAdd    = "add"
+	ActionDrop   = "drop"
+	ActionUpdate = "update"
+)
+
+var _ Receiver = new(etcdReceiver)
+
+// EtcdReceiverCommand leader写入container指令队列的内容，Action和http接口的 /sm/admin/{action}-shard 对应
+type EtcdReceiverCommand struct {
+	HttpReceiverRequest
+
+	Action string `json:"action"`
+
+	// Error container处理失败时回写，leader读到后删除指令，处理成功时container直接删除指令
+	Error string `json:"error,omitempty"`
+}
+
+func (c *EtcdReceiverCommand) String() string {
+	b, _ := json.Marshal(c)
+	return string(b)
+}
+
+// etcdReceiver 拉模式：container watch自己的指令队列，不需要对外暴露端口，
+// 适用于NAT后或者没有http server的app，containerId也不需要是可访问的host:port
+type etcdReceiver struct {
+	client      etcdutil.EtcdWrapper
+	paths       *etcdutil.PathBuilder
+	service     string
+	containerId string
+
+	// leaseID container的session lease，container异常退出时，server不再向该container写指令
+	leaseID clientv3.LeaseID
+
+	shardKeeper core.ShardPrimitives
+
+	// mu 指令按照写入顺序逐个处理，resync和watch不会并发处理同一个指令
+	mu      sync.Mutex
+	stopper *commonutil.GoroutineStopper
+}
+
+func NewEtcdReceiver(client etcdutil.EtcdWrapper, paths *etcdutil.PathBuilder, service string, containerId string, leaseID clientv3.LeaseID) *etcdReceiver {
+	return &etcdReceiver{
+		client:      client,
+		paths:       paths,
+		service:     service,
+		containerId: containerId,
+		leaseID:     leaseID,
+		stopper:     &commonutil.GoroutineStopper{},
+	}
+}
+
+func (r *etcdReceiver) Start() error {
+	// 先处理container不在线期间积累的指令，再从之后的revision开始watch
+	dir := r.paths.CommandDir(r.service, r.containerId)
+	resp, err := r.client.Get(
+		context.TODO(),
+		dir,
+		clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByCreateRevision, clientv3.SortAscend),
+	)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	if err := r.resync(context.TODO(), resp); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	r.stopper.Wrap(
+		func(ctx context.Context) {
+			etcdutil.WatchLoopWithResync(
+				ctx,
+				r.client,
+				dir,
+				resp.Header.Revision+1,
+				func(ctx context.Context, ev *clientv3.Event) error {
+					if ev.Type == clientv3.EventTypeDelete {
+						return nil
+					}
+					return r.handle(ctx, ev.Kv)
+				},
+				r.resync,
+			)
+		},
+	)
+
+	// watch开始之后再声明拉模式，server看到该节点时写入的指令都不会丢失
+	if _, err := r.client.Put(context.TODO(), r.paths.ReceiverPath(r.service, r.containerId), "", clientv3.WithLease(r.leaseID)); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	logutil.Info(
+		"etcd receiver started",
+		zap.String("service", r.service),
+		zap.String("containerId", r.containerId),
+	)
+	return nil
+}
+
+func (r *etcdReceiver) Shutdown() error {
+	// 先撤销声明，server后续的指令会发送失败，触发重新rb
+	if _, err := r.client.Delete(context.TODO(), r.paths.ReceiverPath(r.service, r.containerId)); err != nil {
+		logutil.Error(
+			"Delete receiver error",
+			zap.String("service", r.service),
+			zap.String("containerId", r.containerId),
+			zap.Error(err),
+		)
+		return errors.Wrap(err, "")
+	}
+	r.stopper.Close()
+
+	logutil.Info(
+		"etcd receiver shutdown",
+		zap.String("service", r.service),
+		zap.String("containerId", r.containerId),
+	)
+	return nil
+}
+
+// Extract 拉模式没有http engine
+func (r *etcdReceiver) Extract() interface{} {
+	return nil
+}
+
+func (r *etcdReceiver) SetShardPrimitives(sp core.ShardPrimitives) {
+	r.shardKeeper = sp
+}
+
+func (r *etcdReceiver) resync(ctx context.Context, resp *clientv3.GetResponse) error {
+	for _, kv := range resp.Kvs {
+		if err := r.handle(ctx, kv); err != nil {
+			return errors.Wrap(err, "")
+		}
+	}
+	return nil
+}
+
+// handle 执行指令并确认，成功删除指令，失败回写Error，
+// 两者都要求指令没有被leader修改过（例如leader等待超时后已经删除）
+func (r *etcdReceiver) handle(ctx context.Context, kv *mvccpb.KeyValue) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var cmd EtcdReceiverCommand
+	if err := json.Unmarshal(kv.Value, &cmd); err != nil {
+		logutil.Error(
+			"Unmarshal command err",
+			zap.String("key", string(kv.Key)),
+			zap.ByteString("value", kv.Value),
+			zap.Error(err),
+		)
+		cmd.Error = err.Error()
+	} else {
+		// 已经处理失败的指令，等待leader删除
+		if cmd.Error != "" {
+			return nil
+		}
+		if err := r.apply(&cmd); err != nil {
+			cmd.Error = errors.Cause(err).Error()
+		}
+	}
+
+	key := string(kv.Key)
+	op := clientv3.OpDelete(key)
+	if cmd.Error != "" {
+		op = clientv3.OpPut(key, cmd.String())
+	}
+	cmp := clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)
+	if _, err := r.client.CommitTxn(ctx, []clientv3.Cmp{cmp}, []clientv3.Op{op}); err != nil {
+		logutil.Error(
+			"ack command err",
+			zap.String("key", key),
+			zap.Reflect("cmd", cmd),
+			zap.Error(err),
+		)
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
+
+// apply 和http接口的校验保持一致
+func (r *etcdReceiver) apply(cmd *EtcdReceiverCommand) error {
+	if cmd.Spec == nil && cmd.Action != ActionDrop {
+		return errors.New("empty spec")
+	}
+	switch cmd.Action {
+	case ActionAdd:
+		if err := cmd.Spec.Validate(); err != nil {
+			return errors.Wrap(err, "")
+		}
+		if cmd.Spec.ManualContainerId != "" && cmd.Spec.ManualContainerId != r.containerId {
+			return errors.New("unexpected container")
+		}
+		cmd.Spec.Id = cmd.Id
+		if err := r.shardKeeper.Add(cmd.Id, cmd.Spec); err != nil {
+			logutil.Error(
+				"shardKeeper Add err",
+				zap.Reflect("cmd", cmd),
+				zap.Error(err),
+			)
+			return errors.Wrap(err, "")
+		}
+	case ActionDrop:
+		if err := r.shardKeeper.Drop(cmd.Id); err != nil && err != commonutil.ErrNotExist {
+			logutil.Error(
+				"Drop err",
+				zap.Reflect("cmd", cmd),
+				zap.Error(err),
+			)
+			return errors.Wrap(err, "")
+		}
+	case ActionUpdate:
+		if err := cmd.Spec.Validate(); err != nil {
+			return errors.Wrap(err, "")
+		}
+		updater, ok := r.shardKeeper.(core.ShardUpdater)
+		if !ok {
+			return errors.New("update not supported")
+		}
+		cmd.Spec.Id = cmd.Id
+		if err := updater.Update(cmd.Id, cmd.Spec); err != nil {
+			logutil.Error(
+				"shardKeeper Update err",
+				zap.Reflect("cmd", cmd),
+				zap.Error(err),
+			)
+			return errors.Wrap(err, "")
+		}
+	default:
+		return errors.Errorf("unknown action %s", cmd.Action)
+	}
+
+	logutil.Info(
+		"apply command success",
+		zap.Reflect("cmd", cmd),
+	)
+	return nil
+}
@@ -0,0 +1,126 @@
+package receiver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/core"
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestEtcdReceiver(t *testing.T) {
+	suite.Run(t, new(EtcdReceiverTestSuite))
+}
+
+type EtcdReceiverTestSuite struct {
+	suite.Suite
+
+	client   *etcdutil.EtcdClient
+	paths    *etcdutil.PathBuilder
+	sp       *core.MockedShardUpdater
+	receiver *etcdReceiver
+}
+
+// SetupTest 依赖本地127.0.0.1:2379的etcd
+func (suite *EtcdReceiverTestSuite) SetupTest() {
+	var err error
+	suite.client, err = etcdutil.NewEtcdClient([]string{"127.0.0.1:2379"})
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.paths = etcdutil.NewPathBuilder(fmt.Sprintf("/receiver-test-%d", time.Now().UnixNano()))
+
+	lresp, err := suite.client.Grant(context.TODO(), 10)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.sp = new(core.MockedShardUpdater)
+	suite.receiver = NewEtcdReceiver(suite.client, suite.paths, "foo.bar", "c1", lresp.ID)
+	suite.receiver.SetShardPrimitives(suite.sp)
+}
+
+func (suite *EtcdReceiverTestSuite) TearDownTest() {
+	suite.receiver.Shutdown()
+	suite.client.Delete(context.TODO(), suite.paths.Pfx(), clientv3.WithPrefix())
+	suite.client.Close()
+}
+
+func (suite *EtcdReceiverTestSuite) put(commandId string, cmd *EtcdReceiverCommand) string {
+	key := suite.paths.CommandPath("foo.bar", "c1", commandId)
+	if _, err := suite.client.Put(context.TODO(), key, cmd.String()); err != nil {
+		suite.T().Fatal(err)
+	}
+	return key
+}
+
+// wait 等待指令被确认，返回回写的指令，nil表示指令已经被删除
+func (suite *EtcdReceiverTestSuite) wait(key string) *EtcdReceiverCommand {
+	for i := 0; i < 50; i++ {
+		resp, err := suite.client.Get(context.TODO(), key)
+		assert.NoError(suite.T(), err)
+		if resp.Count == 0 {
+			return nil
+		}
+		var cmd EtcdReceiverCommand
+		json.Unmarshal(resp.Kvs[0].Value, &cmd)
+		if cmd.Error != "" {
+			return &cmd
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	suite.T().Fatal("command not ack")
+	return nil
+}
+
+func (suite *EtcdReceiverTestSuite) TestStart_pending() {
+	spec := &storage.ShardSpec{Service: "foo.bar", UpdateTime: time.Now().Unix()}
+	suite.sp.On("Add", "s1", mock.Anything).Return(nil)
+
+	// container启动前写入的指令
+	key := suite.put("1", &EtcdReceiverCommand{HttpReceiverRequest: HttpReceiverRequest{Id: "s1", Spec: spec}, Action: ActionAdd})
+	assert.NoError(suite.T(), suite.receiver.Start())
+	assert.Nil(suite.T(), suite.wait(key))
+
+	resp, err := suite.client.Get(context.TODO(), suite.paths.ReceiverPath("foo.bar", "c1"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), resp.Count)
+	suite.sp.AssertExpectations(suite.T())
+}
+
+func (suite *EtcdReceiverTestSuite) TestWatch() {
+	assert.NoError(suite.T(), suite.receiver.Start())
+
+	spec := &storage.ShardSpec{Service: "foo.bar", UpdateTime: time.Now().Unix()}
+	suite.sp.On("Drop", "s1").Return(nil)
+	suite.sp.On("Update", "s2", mock.Anything).Return(nil)
+
+	key := suite.put("1", &EtcdReceiverCommand{HttpReceiverRequest: HttpReceiverRequest{Id: "s1"}, Action: ActionDrop})
+	assert.Nil(suite.T(), suite.wait(key))
+	key = suite.put("2", &EtcdReceiverCommand{HttpReceiverRequest: HttpReceiverRequest{Id: "s2", Spec: spec}, Action: ActionUpdate})
+	assert.Nil(suite.T(), suite.wait(key))
+	suite.sp.AssertExpectations(suite.T())
+}
+
+func (suite *EtcdReceiverTestSuite) TestWatch_error() {
+	assert.NoError(suite.T(), suite.receiver.Start())
+
+	// 指定了其他container
+	spec := &storage.ShardSpec{Service: "foo.bar", UpdateTime: time.Now().Unix(), ManualContainerId: "c2"}
+	key := suite.put("1", &EtcdReceiverCommand{HttpReceiverRequest: HttpReceiverRequest{Id: "s1", Spec: spec}, Action: ActionAdd})
+	cmd := suite.wait(key)
+	assert.NotNil(suite.T(), cmd)
+	assert.Equal(suite.T(), "unexpected container", cmd.Error)
+
+	key = suite.put("2", &EtcdReceiverCommand{HttpReceiverRequest: HttpReceiverRequest{Id: "s1"}, Action: "unknown"})
+	cmd = suite.wait(key)
+	assert.NotNil(suite.T(), cmd)
+	suite.sp.AssertNotCalled(suite.T(), "Add", "s1", mock.Anything)
+}
@@ -0,0 +1,288 @@
+package receiver
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/core"
+	"github.com/entertainment-venue/sm/pkg/apputil/receiver/receiverpb"
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/entertainment-venue/sm/pkg/commonutil"
+	"github.com/entertainment-venue/sm/pkg/logutil"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	_ Receiver   = new(grpcReceiver)
+	_ Verifiable = new(grpcReceiver)
+)
+
+// grpcReceiver 提供和httpReceiver相同的接口，适用于只提供gRPC服务的app，不需要gin
+type grpcReceiver struct {
+	receiverpb.UnimplementedReceiverServer
+
+	addr        string
+	containerId string
+	shardKeeper core.ShardPrimitives
+
+	server *grpc.Server
+
+	// verifier 只接受leader签名的指令，为空时拒绝所有指令，client复用receiver时会在请求处理过程中被替换
+	mu       sync.Mutex
+	verifier *Verifier
+}
+
+// NewGRPCReceiver 在addr上启动gRPC server，tls通过 grpc.Creds(credentials.NewTLS(cfg)) 传入opts
+func NewGRPCReceiver(addr string, containerId string, opts ...grpc.ServerOption) *grpcReceiver {
+	r := grpcReceiver{
+		addr:        addr,
+		containerId: containerId,
+		server:      grpc.NewServer(opts...),
+	}
+	receiverpb.RegisterReceiverServer(r.server, &r)
+	return &r
+}
+
+// NewGRPCServerReceiver 复用app已有的gRPC server，需要在server启动之前调用，server的启停由app负责
+func NewGRPCServerReceiver(server *grpc.Server, containerId string) *grpcReceiver {
+	r := grpcReceiver{
+		containerId: containerId,
+		server:      server,
+	}
+	receiverpb.RegisterReceiverServer(r.server, &r)
+	return &r
+}
+
+// SetVerifier 在Start之前调用
+func (r *grpcReceiver) SetVerifier(v *Verifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.verifier = v
+}
+
+func (r *grpcReceiver) getVerifier() *Verifier {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.verifier
+}
+
+func (r *grpcReceiver) Start() error {
+	// NewGRPCServerReceiver 的场景不需要启动server
+	if r.addr == "" {
+		return nil
+	}
+	lis, err := net.Listen("tcp", r.addr)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	go func() {
+		if err := r.server.Serve(lis); err != nil {
+			logutil.Panic(
+				"Serve err",
+				zap.String("addr", r.addr),
+				zap.Error(err),
+			)
+			return
+		}
+		logutil.Info(
+			"Serve exit",
+			zap.String("addr", r.addr),
+		)
+	}()
+	return nil
+}
+
+func (r *grpcReceiver) Shutdown() error {
+	if r.addr == "" {
+		return nil
+	}
+	r.server.GracefulStop()
+	logutil.Info(
+		"Shutdown success",
+		zap.String("addr", r.addr),
+	)
+	return nil
+}
+
+// Extract 返回 *grpc.Server，app可以在Start之前注册自己的服务
+func (r *grpcReceiver) Extract() interface{} {
+	return r.server
+}
+
+func (r *grpcReceiver) SetShardPrimitives(sp core.ShardPrimitives) {
+	r.shardKeeper = sp
+}
+
+func (r *grpcReceiver) AddShard(ctx context.Context, req *receiverpb.ShardRequest) (*emptypb.Empty, error) {
+	if err := r.verify(ctx, req); err != nil {
+		return nil, err
+	}
+	spec, err := r.validate(req)
+	if err != nil {
+		return nil, err
+	}
+	if spec.ManualContainerId != "" && spec.ManualContainerId != r.containerId {
+		logutil.Error(
+			"ManualContainerId not match",
+			zap.Reflect("spec", spec),
+			zap.String("server-container-id", r.containerId),
+		)
+		return nil, status.Error(codes.InvalidArgument, "unexpected container")
+	}
+
+	if err := r.shardKeeper.Add(req.Id, spec); err != nil {
+		logutil.Error(
+			"shardKeeper Add err",
+			zap.Reflect("spec", spec),
+			zap.Error(err),
+		)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	logutil.Info(
+		"add shard success",
+		zap.Reflect("spec", spec),
+	)
+	return &emptypb.Empty{}, nil
+}
+
+func (r *grpcReceiver) DropShard(ctx context.Context, req *receiverpb.ShardRequest) (*emptypb.Empty, error) {
+	if err := r.verify(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := r.shardKeeper.Drop(req.Id); err != nil && err != commonutil.ErrNotExist {
+		logutil.Error(
+			"Drop err",
+			zap.Error(err),
+			zap.String("id", req.Id),
+		)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	logutil.Info(
+		"drop shard success",
+		zap.String("id", req.Id),
+	)
+	return &emptypb.Empty{}, nil
+}
+
+func (r *grpcReceiver) UpdateShard(ctx context.Context, req *receiverpb.ShardRequest) (*emptypb.Empty, error) {
+	if err := r.verify(ctx, req); err != nil {
+		return nil, err
+	}
+	spec, err := r.validate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	updater, ok := r.shardKeeper.(core.ShardUpdater)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "update not supported")
+	}
+	if err := updater.Update(req.Id, spec); err != nil {
+		logutil.Error(
+			"shardKeeper Update err",
+			zap.Reflect("spec", spec),
+			zap.Error(err),
+		)
+		if errors.Cause(err) == commonutil.ErrNotExist {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	logutil.Info(
+		"update shard success",
+		zap.Reflect("spec", spec),
+	)
+	return &emptypb.Empty{}, nil
+}
+
+// verify 检查deadline和签名，签名内容为request的确定性序列化结果
+func (r *grpcReceiver) verify(ctx context.Context, req *receiverpb.ShardRequest) error {
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	method, _ := grpc.Method(ctx)
+	body, err := MarshalShardRequest(req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := verify(r.getVerifier(), method, body, signatureFromIncomingContext(ctx)); err != nil {
+		logutil.Error(
+			"Verify err",
+			zap.String("method", method),
+			zap.String("id", req.Id),
+			zap.Error(err),
+		)
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	return nil
+}
+
+// MarshalShardRequest sender和receiver使用相同的序列化方式计算签名
+func MarshalShardRequest(req *receiverpb.ShardRequest) ([]byte, error) {
+	return proto.MarshalOptions{Deterministic: true}.Marshal(req)
+}
+
+// validate shard属性校验，和http接口一致
+func (r *grpcReceiver) validate(req *receiverpb.ShardRequest) (*storage.ShardSpec, error) {
+	if req.Spec == nil {
+		return nil, status.Error(codes.InvalidArgument, "empty spec")
+	}
+	spec := FromShardSpecPB(req.Spec)
+	if err := spec.Validate(); err != nil {
+		logutil.Error(
+			"Validate err",
+			zap.Reflect("spec", spec),
+			zap.Error(err),
+		)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	spec.Id = req.Id
+	return spec, nil
+}
+
+// NewShardRequest sender使用，把storage.ShardSpec转换为pb
+func NewShardRequest(id string, spec *storage.ShardSpec) *receiverpb.ShardRequest {
+	req := receiverpb.ShardRequest{Id: id}
+	if spec != nil {
+		req.Spec = &receiverpb.ShardSpec{
+			Id:                spec.Id,
+			Service:           spec.Service,
+			Task:              spec.Task,
+			UpdateTime:        spec.UpdateTime,
+			ManualContainerId: spec.ManualContainerId,
+			Group:             spec.Group,
+			WorkerGroup:       spec.WorkerGroup,
+		}
+		if spec.Lease != nil {
+			req.Spec.Lease = &receiverpb.Lease{Id: int64(spec.Lease.ID), Expire: spec.Lease.Expire}
+		}
+	}
+	return &req
+}
+
+func FromShardSpecPB(pb *receiverpb.ShardSpec) *storage.ShardSpec {
+	spec := storage.ShardSpec{
+		Id:                pb.Id,
+		Service:           pb.Service,
+		Task:              pb.Task,
+		UpdateTime:        pb.UpdateTime,
+		ManualContainerId: pb.ManualContainerId,
+		Group:             pb.Group,
+		WorkerGroup:       pb.WorkerGroup,
+	}
+	if pb.Lease != nil {
+		spec.Lease = &storage.Lease{ID: clientv3.LeaseID(pb.Lease.Id), Expire: pb.Lease.Expire}
+	}
+	return &spec
+}
@@ -0,0 +1,102 @@
+package receiver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/core"
+	"github.com/entertainment-venue/sm/pkg/apputil/receiver/receiverpb"
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/entertainment-venue/sm/pkg/commonutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCReceiver(t *testing.T) {
+	suite.Run(t, new(GRPCReceiverTestSuite))
+}
+
+type GRPCReceiverTestSuite struct {
+	suite.Suite
+
+	sp       *core.MockedShardUpdater
+	receiver *grpcReceiver
+	conn     *grpc.ClientConn
+	client   receiverpb.ReceiverClient
+}
+
+func (suite *GRPCReceiverTestSuite) SetupTest() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	suite.sp = new(core.MockedShardUpdater)
+	suite.receiver = NewGRPCReceiver(addr, "c1")
+	suite.receiver.SetShardPrimitives(suite.sp)
+	// 签名见 TestVerify
+	v := NewVerifier()
+	v.SetAllowUnsigned(true)
+	suite.receiver.SetVerifier(v)
+	if err := suite.receiver.Start(); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.conn, err = grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.client = receiverpb.NewReceiverClient(suite.conn)
+}
+
+func (suite *GRPCReceiverTestSuite) TearDownTest() {
+	suite.conn.Close()
+	suite.receiver.Shutdown()
+}
+
+func (suite *GRPCReceiverTestSuite) TestAddShard() {
+	spec := &storage.ShardSpec{Service: "foo.bar", UpdateTime: time.Now().Unix(), Lease: &storage.Lease{ID: 1, Expire: 2}}
+	suite.sp.On("Add", "s1", mock.Anything).Return(nil)
+
+	_, err := suite.client.AddShard(context.TODO(), NewShardRequest("s1", spec))
+	assert.NoError(suite.T(), err)
+	suite.sp.AssertExpectations(suite.T())
+	actual := suite.sp.Calls[0].Arguments.Get(1).(*storage.ShardSpec)
+	assert.Equal(suite.T(), "s1", actual.Id)
+	assert.Equal(suite.T(), spec.Lease, actual.Lease)
+
+	// 参数错误
+	_, err = suite.client.AddShard(context.TODO(), NewShardRequest("s1", &storage.ShardSpec{}))
+	assert.Equal(suite.T(), codes.InvalidArgument, status.Code(err))
+	_, err = suite.client.AddShard(context.TODO(), NewShardRequest("s1", nil))
+	assert.Equal(suite.T(), codes.InvalidArgument, status.Code(err))
+
+	spec.ManualContainerId = "c2"
+	_, err = suite.client.AddShard(context.TODO(), NewShardRequest("s1", spec))
+	assert.Equal(suite.T(), codes.InvalidArgument, status.Code(err))
+}
+
+func (suite *GRPCReceiverTestSuite) TestDropShard() {
+	suite.sp.On("Drop", "s1").Return(commonutil.ErrNotExist)
+	_, err := suite.client.DropShard(context.TODO(), NewShardRequest("s1", nil))
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *GRPCReceiverTestSuite) TestUpdateShard() {
+	spec := &storage.ShardSpec{Service: "foo.bar", UpdateTime: time.Now().Unix()}
+	suite.sp.On("Update", "s1", mock.Anything).Return(nil)
+	suite.sp.On("Update", "s2", mock.Anything).Return(commonutil.ErrNotExist)
+
+	_, err := suite.client.UpdateShard(context.TODO(), NewShardRequest("s1", spec))
+	assert.NoError(suite.T(), err)
+	_, err = suite.client.UpdateShard(context.TODO(), NewShardRequest("s2", spec))
+	assert.Equal(suite.T(), codes.NotFound, status.Code(err))
+}
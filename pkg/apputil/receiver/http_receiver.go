@@ -1,8 +1,13 @@
 package receiver
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/entertainment-venue/sm/pkg/apputil/core"
 	"github.com/entertainment-venue/sm/pkg/apputil/storage"
@@ -13,7 +18,10 @@ import (
 	"go.uber.org/zap"
 )
 
-var _ Receiver = new(httpReceiver)
+var (
+	_ Receiver   = new(httpReceiver)
+	_ Verifiable = new(httpReceiver)
+)
 
 type httpReceiver struct {
 	addr        string
@@ -22,6 +30,13 @@ type httpReceiver struct {
 
 	ginEngine *gin.Engine
 	svr       *http.Server
+
+	// tlsConfig 不为空时以https提供服务，ClientAuth决定是否校验客户端证书
+	tlsConfig *tls.Config
+
+	// verifier /sm/admin 接口只接受leader签名的指令，为空时拒绝所有指令，client复用receiver时会在请求处理过程中被替换
+	mu       sync.Mutex
+	verifier *Verifier
 }
 
 type HttpReceiverRequest struct {
@@ -40,17 +55,90 @@ func NewHttpServer(addr string, containerId string) *httpReceiver {
 	{
 		routerGroup.POST("/add-shard", svr.AddShard)
 		routerGroup.POST("/drop-shard", svr.DropShard)
+		routerGroup.POST("/update-shard", svr.UpdateShard)
+	}
+	return &svr
+}
+
+// NewGinReceiver 复用app已有的gin engine，http server的启停由app负责，
+// engine中已经存在 /sm/admin 的接口时不再注册，签名在handler中校验，app自行注册的接口同样生效
+func NewGinReceiver(engine *gin.Engine, containerId string) *httpReceiver {
+	svr := httpReceiver{
+		containerId: containerId,
+
+		ginEngine: engine,
+	}
+	for _, route := range engine.Routes() {
+		if strings.HasPrefix(route.Path, "/sm/admin") {
+			return &svr
+		}
+	}
+	routerGroup := svr.ginEngine.Group("/sm/admin")
+	{
+		routerGroup.POST("/add-shard", svr.AddShard)
+		routerGroup.POST("/drop-shard", svr.DropShard)
+		routerGroup.POST("/update-shard", svr.UpdateShard)
 	}
 	return &svr
 }
 
+// SetTLSConfig 在Start之前调用，证书需要设置在cfg的Certificates中
+func (r *httpReceiver) SetTLSConfig(cfg *tls.Config) {
+	r.tlsConfig = cfg
+}
+
+// SetVerifier 在Start之前调用
+func (r *httpReceiver) SetVerifier(v *Verifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.verifier = v
+}
+
+func (r *httpReceiver) getVerifier() *Verifier {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.verifier
+}
+
+// verify 校验签名之后恢复body，供handler读取，失败时直接返回401
+func (r *httpReceiver) verify(c *gin.Context) bool {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err := verify(r.getVerifier(), c.Request.URL.Path, body, signatureFromHeader(c.Request.Header)); err != nil {
+		logutil.Error(
+			"Verify err",
+			zap.String("path", c.Request.URL.Path),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
 func (r *httpReceiver) Start() error {
+	// NewGinReceiver 的场景不需要启动http server
+	if r.addr == "" {
+		return nil
+	}
 	r.svr = &http.Server{
-		Addr:    r.addr,
-		Handler: r.ginEngine,
+		Addr:      r.addr,
+		Handler:   r.ginEngine,
+		TLSConfig: r.tlsConfig,
 	}
 	go func() {
-		if err := r.svr.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if r.tlsConfig != nil {
+			err = r.svr.ListenAndServeTLS("", "")
+		} else {
+			err = r.svr.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logutil.Panic(
 				"ListenAndServe err",
 				zap.String("addr", r.addr),
@@ -67,6 +155,9 @@ func (r *httpReceiver) Start() error {
 }
 
 func (r *httpReceiver) Shutdown() error {
+	if r.svr == nil {
+		return nil
+	}
 	if err := r.svr.Shutdown(context.TODO()); err != nil {
 		logutil.Error(
 			"Shutdown error",
@@ -91,6 +182,10 @@ func (r *httpReceiver) SetShardPrimitives(sp core.ShardPrimitives) {
 }
 
 func (r *httpReceiver) AddShard(c *gin.Context) {
+	if !r.verify(c) {
+		return
+	}
+
 	var req HttpReceiverRequest
 	if err := c.ShouldBind(&req); err != nil {
 		logutil.Error("ShouldBind err", zap.Error(err))
@@ -140,6 +235,10 @@ func (r *httpReceiver) AddShard(c *gin.Context) {
 }
 
 func (r *httpReceiver) DropShard(c *gin.Context) {
+	if !r.verify(c) {
+		return
+	}
+
 	var req HttpReceiverRequest
 	if err := c.ShouldBind(&req); err != nil {
 		logutil.Error(
@@ -166,3 +265,52 @@ func (r *httpReceiver) DropShard(c *gin.Context) {
 	)
 	c.JSON(http.StatusOK, gin.H{})
 }
+
+func (r *httpReceiver) UpdateShard(c *gin.Context) {
+	if !r.verify(c) {
+		return
+	}
+
+	var req HttpReceiverRequest
+	if err := c.ShouldBind(&req); err != nil {
+		logutil.Error("ShouldBind err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Spec.Validate(); err != nil {
+		logutil.Error(
+			"Validate err",
+			zap.Reflect("req", req),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updater, ok := r.shardKeeper.(core.ShardUpdater)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "update not supported"})
+		return
+	}
+
+	req.Spec.Id = req.Id
+	if err := updater.Update(req.Id, req.Spec); err != nil {
+		logutil.Error(
+			"shardKeeper Update err",
+			zap.Reflect("req", req),
+			zap.Error(err),
+		)
+		if errors.Cause(err) == commonutil.ErrNotExist {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logutil.Info(
+		"update shard success",
+		zap.Reflect("req", req),
+	)
+	c.JSON(http.StatusOK, gin.H{})
+}
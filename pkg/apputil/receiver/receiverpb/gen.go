@@ -0,0 +1,4 @@
+// Package receiverpb gRPC receiver的接口定义，receiver.pb.go和receiver_grpc.pb.go由receiver.proto生成，不要手动修改
+package receiverpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative receiver.proto
@@ -0,0 +1,192 @@
+package receiver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	HeaderTimestamp = "X-Sm-Timestamp"
+	HeaderNonce     = "X-Sm-Nonce"
+	HeaderSignature = "X-Sm-Signature"
+
+	// defaultSignWindow 签名的有效期，超出的指令认为是重放，nonce在2倍有效期内去重
+	defaultSignWindow = 30 * time.Second
+)
+
+var (
+	ErrUnsigned     = errors.New("unsigned command")
+	ErrBadSignature = errors.New("bad signature")
+	ErrExpired      = errors.New("command expired")
+	ErrReplayed     = errors.New("command replayed")
+	ErrNoSecret     = errors.New("no secret to verify command")
+)
+
+// Signature leader对下发的指令签名，签名内容为 path、timestamp、nonce 和请求body，
+// path使用http的url path或者grpc的full method，保证签名不能挪用到其他接口
+type Signature struct {
+	Timestamp string
+	Nonce     string
+	Sig       string
+}
+
+// Sign secret是service在etcd中的共享密钥，见 etcdutil.PathBuilder.SecretPath
+func Sign(secret []byte, path string, body []byte) *Signature {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	s := Signature{
+		Timestamp: strconv.FormatInt(time.Now().Unix(), 10),
+		Nonce:     hex.EncodeToString(nonce),
+	}
+	s.Sig = hex.EncodeToString(s.mac(secret, path, body))
+	return &s
+}
+
+func (s *Signature) mac(secret []byte, path string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(strings.Join([]string{path, s.Timestamp, s.Nonce}, "\n")))
+	h.Write([]byte("\n"))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+func (s *Signature) SetHeader(header http.Header) {
+	header.Set(HeaderTimestamp, s.Timestamp)
+	header.Set(HeaderNonce, s.Nonce)
+	header.Set(HeaderSignature, s.Sig)
+}
+
+// AppendToOutgoingContext grpc通过metadata传递签名
+func (s *Signature) AppendToOutgoingContext(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(
+		ctx,
+		strings.ToLower(HeaderTimestamp), s.Timestamp,
+		strings.ToLower(HeaderNonce), s.Nonce,
+		strings.ToLower(HeaderSignature), s.Sig,
+	)
+}
+
+func signatureFromHeader(header http.Header) *Signature {
+	return &Signature{
+		Timestamp: header.Get(HeaderTimestamp),
+		Nonce:     header.Get(HeaderNonce),
+		Sig:       header.Get(HeaderSignature),
+	}
+}
+
+func signatureFromIncomingContext(ctx context.Context) *Signature {
+	md, _ := metadata.FromIncomingContext(ctx)
+	get := func(k string) string {
+		if v := md.Get(k); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	return &Signature{
+		Timestamp: get(strings.ToLower(HeaderTimestamp)),
+		Nonce:     get(strings.ToLower(HeaderNonce)),
+		Sig:       get(strings.ToLower(HeaderSignature)),
+	}
+}
+
+// Verifiable 支持签名校验的receiver，container在Start之前设置 Verifier
+type Verifiable interface {
+	SetVerifier(v *Verifier)
+}
+
+// Verifier receiver校验指令的签名，secret由container从etcd中watch得到，
+// secret为空时拒绝所有指令，只有开启 SetAllowUnsigned 并且还没有见过secret时才不做校验
+type Verifier struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	secret []byte
+	// seen 见过secret之后，secret被删除也不再放行未签名的指令
+	seen bool
+	// allowUnsigned 兼容升级过程，server还没有开始签名时放行
+	allowUnsigned bool
+	// nonces 有效期内见过的nonce和过期时间
+	nonces map[string]int64
+}
+
+func NewVerifier() *Verifier {
+	return &Verifier{
+		window: defaultSignWindow,
+		nonces: make(map[string]int64),
+	}
+}
+
+func (v *Verifier) SetSecret(secret []byte) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secret = secret
+	if len(secret) > 0 {
+		v.seen = true
+	}
+}
+
+// SetAllowUnsigned 显式开启兼容模式，在见到secret之前放行未签名的指令
+func (v *Verifier) SetAllowUnsigned(allow bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.allowUnsigned = allow
+}
+
+func (v *Verifier) Verify(path string, body []byte, s *Signature) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.secret) == 0 {
+		if v.allowUnsigned && !v.seen {
+			return nil
+		}
+		return ErrNoSecret
+	}
+	if s.Timestamp == "" || s.Nonce == "" || s.Sig == "" {
+		return ErrUnsigned
+	}
+	sig, err := hex.DecodeString(s.Sig)
+	if err != nil || !hmac.Equal(sig, s.mac(v.secret, path, body)) {
+		return ErrBadSignature
+	}
+
+	ts, err := strconv.ParseInt(s.Timestamp, 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	now := time.Now()
+	if d := now.Sub(time.Unix(ts, 0)); d > v.window || d < -v.window {
+		return ErrExpired
+	}
+
+	// 清理过期的nonce，过期的nonce对应的签名已经无法通过时间校验
+	for nonce, expire := range v.nonces {
+		if expire < now.Unix() {
+			delete(v.nonces, nonce)
+		}
+	}
+	if _, ok := v.nonces[s.Nonce]; ok {
+		return ErrReplayed
+	}
+	v.nonces[s.Nonce] = now.Add(2 * v.window).Unix()
+	return nil
+}
+
+// verify 所有接收指令的入口共用，verifier为空说明 Container.Run 还没有设置，拒绝指令
+func verify(v *Verifier, path string, body []byte, s *Signature) error {
+	if v == nil {
+		return ErrNoSecret
+	}
+	return v.Verify(path, body, s)
+}
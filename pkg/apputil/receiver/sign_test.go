@@ -0,0 +1,152 @@
+package receiver
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/core"
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_Verifier(t *testing.T) {
+	secret := []byte("foo")
+	body := []byte(`{"id":"s1"}`)
+	v := NewVerifier()
+
+	// 没有secret时拒绝
+	assert.Equal(t, ErrNoSecret, v.Verify("/sm/admin/add-shard", body, &Signature{}))
+
+	v.SetSecret(secret)
+	assert.Equal(t, ErrUnsigned, v.Verify("/sm/admin/add-shard", body, &Signature{}))
+
+	s := Sign(secret, "/sm/admin/add-shard", body)
+	assert.NoError(t, v.Verify("/sm/admin/add-shard", body, s))
+	assert.Equal(t, ErrReplayed, v.Verify("/sm/admin/add-shard", body, s))
+
+	// 签名不能用于其他接口或者其他内容
+	s = Sign(secret, "/sm/admin/add-shard", body)
+	assert.Equal(t, ErrBadSignature, v.Verify("/sm/admin/drop-shard", body, s))
+	assert.Equal(t, ErrBadSignature, v.Verify("/sm/admin/add-shard", []byte(`{"id":"s2"}`), s))
+	assert.Equal(t, ErrBadSignature, v.Verify("/sm/admin/add-shard", body, Sign([]byte("bar"), "/sm/admin/add-shard", body)))
+
+	// 过期的签名
+	s = &Signature{Timestamp: strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10), Nonce: "n1"}
+	s.Sig = hex.EncodeToString(s.mac(secret, "/sm/admin/add-shard", body))
+	assert.Equal(t, ErrExpired, v.Verify("/sm/admin/add-shard", body, s))
+}
+
+func Test_Verifier_allowUnsigned(t *testing.T) {
+	body := []byte(`{"id":"s1"}`)
+	v := NewVerifier()
+	v.SetAllowUnsigned(true)
+
+	// 兼容模式下，见到secret之前放行
+	assert.NoError(t, v.Verify("/sm/admin/add-shard", body, &Signature{}))
+
+	// 见到secret之后，secret被删除也不再放行
+	v.SetSecret([]byte("foo"))
+	v.SetSecret(nil)
+	assert.Equal(t, ErrNoSecret, v.Verify("/sm/admin/add-shard", body, &Signature{}))
+}
+
+func Test_httpReceiver_verify(t *testing.T) {
+	secret := []byte("foo")
+	sp := new(core.MockedShardPrimitives)
+	sp.On("Drop", "s1").Return(nil)
+
+	r := NewHttpServer("", "c1")
+	r.SetShardPrimitives(sp)
+
+	// 没有设置verifier时拒绝
+	body := []byte(`{"id":"s1"}`)
+	req := httptest.NewRequest(http.MethodPost, "/sm/admin/drop-shard", bytes.NewBuffer(body))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ginEngine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	v := NewVerifier()
+	v.SetSecret(secret)
+	r.SetVerifier(v)
+
+	req = httptest.NewRequest(http.MethodPost, "/sm/admin/drop-shard", bytes.NewBuffer(body))
+	req.Header.Add("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ginEngine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	sp.AssertNotCalled(t, "Drop", "s1")
+
+	req = httptest.NewRequest(http.MethodPost, "/sm/admin/drop-shard", bytes.NewBuffer(body))
+	req.Header.Add("Content-Type", "application/json")
+	Sign(secret, "/sm/admin/drop-shard", body).SetHeader(req.Header)
+	w = httptest.NewRecorder()
+	r.ginEngine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	sp.AssertExpectations(t)
+}
+
+// Test_ginReceiver_verify app已经注册了 /sm/admin 接口并直接调用handler，同样需要签名
+func Test_ginReceiver_verify(t *testing.T) {
+	secret := []byte("foo")
+	sp := new(core.MockedShardPrimitives)
+	sp.On("Drop", "s1").Return(nil)
+
+	engine := gin.New()
+	var r *httpReceiver
+	engine.POST("/sm/admin/drop-shard", func(c *gin.Context) { r.DropShard(c) })
+	r = NewGinReceiver(engine, "c1")
+	r.SetShardPrimitives(sp)
+	v := NewVerifier()
+	v.SetSecret(secret)
+	r.SetVerifier(v)
+
+	body := []byte(`{"id":"s1"}`)
+	req := httptest.NewRequest(http.MethodPost, "/sm/admin/drop-shard", bytes.NewBuffer(body))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	sp.AssertNotCalled(t, "Drop", "s1")
+
+	req = httptest.NewRequest(http.MethodPost, "/sm/admin/drop-shard", bytes.NewBuffer(body))
+	req.Header.Add("Content-Type", "application/json")
+	Sign(secret, "/sm/admin/drop-shard", body).SetHeader(req.Header)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	sp.AssertExpectations(t)
+}
+
+func (suite *GRPCReceiverTestSuite) TestVerify() {
+	secret := []byte("foo")
+	v := NewVerifier()
+	v.SetSecret(secret)
+	suite.receiver.SetVerifier(v)
+	defer suite.receiver.SetVerifier(nil)
+
+	spec := &storage.ShardSpec{Service: "foo.bar", UpdateTime: time.Now().Unix()}
+	suite.sp.On("Add", "s1", mock.Anything).Return(nil)
+	req := NewShardRequest("s1", spec)
+	_, err := suite.client.AddShard(context.TODO(), req)
+	assert.Equal(suite.T(), codes.Unauthenticated, status.Code(err))
+
+	body, _ := MarshalShardRequest(req)
+	ctx := Sign(secret, "/sm.receiver.v1.Receiver/AddShard", body).AppendToOutgoingContext(context.TODO())
+	_, err = suite.client.AddShard(ctx, req)
+	assert.NoError(suite.T(), err)
+
+	// 重放
+	_, err = suite.client.AddShard(ctx, req)
+	assert.Equal(suite.T(), codes.Unauthenticated, status.Code(err))
+}
@@ -255,6 +255,61 @@ func (db *boltdb) Remove(shardID string) error {
 	})
 }
 
+func (db *boltdb) Batch(puts map[string]*ShardKeeperDbValue, removes []string, snapshots map[string]*ShardKeeperDbValue) error {
+	if len(puts) == 0 && len(removes) == 0 {
+		return nil
+	}
+	return db.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(db.service))
+		// current 读取storage中的值，不存在或者sync期间被修改过时返回false
+		current := func(shardID string) bool {
+			v := b.Get([]byte(shardID))
+			if v == nil {
+				return false
+			}
+			var cur ShardKeeperDbValue
+			if err := json.Unmarshal(v, &cur); err != nil {
+				return false
+			}
+			if changed(&cur, snapshots[shardID]) {
+				logutil.Info(
+					"shard changed during sync, skip",
+					zap.String("service", db.service),
+					zap.String("shard-id", shardID),
+				)
+				return false
+			}
+			return true
+		}
+
+		for shardID, dv := range puts {
+			if b.Get([]byte(shardID)) == nil {
+				logutil.Warn(
+					"shard not exist when try to put",
+					zap.String("service", db.service),
+					zap.String("shard-id", shardID),
+				)
+				continue
+			}
+			if !current(shardID) {
+				continue
+			}
+			if err := b.Put([]byte(shardID), []byte(dv.String())); err != nil {
+				return errors.Wrap(err, "")
+			}
+		}
+		for _, shardID := range removes {
+			if !current(shardID) {
+				continue
+			}
+			if err := b.Delete([]byte(shardID)); err != nil {
+				return errors.Wrap(err, "")
+			}
+		}
+		return nil
+	})
+}
+
 func (db *boltdb) Update(k, v []byte) error {
 	return db.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(db.service))
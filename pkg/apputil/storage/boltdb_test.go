@@ -25,7 +25,7 @@ func TestBoltdb(t *testing.T) {
 
 func (suite *BoltdbTestSuite) SetupTest() {
 	service := "foo"
-	suite.db, _ = NewBoltdb("./", service)
+	suite.db, _ = NewBoltdb(suite.T().TempDir(), service)
 	suite.shardId = "bar"
 
 	spec := ShardSpec{
@@ -82,6 +82,50 @@ func (suite *BoltdbTestSuite) TestDrop() {
 	suite.db.Close()
 }
 
+func (suite *BoltdbTestSuite) TestBatch() {
+	other := ShardSpec{Id: "baz", Lease: &Lease{ID: 1}}
+	assert.Nil(suite.T(), suite.db.Add(&other))
+
+	// 不存在的shard忽略
+	puts := map[string]*ShardKeeperDbValue{
+		suite.shardId: {Spec: suite.spec, Disp: true},
+		mock.Anything: {Spec: &ShardSpec{Id: mock.Anything}},
+	}
+	err := suite.db.Batch(puts, []string{other.Id}, nil)
+	assert.Nil(suite.T(), err)
+
+	v, _ := suite.db.Get([]byte(suite.shardId))
+	var dbValue ShardKeeperDbValue
+	json.Unmarshal(v, &dbValue)
+	assert.True(suite.T(), dbValue.Disp)
+	v, _ = suite.db.Get([]byte(other.Id))
+	assert.Nil(suite.T(), v)
+	v, _ = suite.db.Get([]byte(mock.Anything))
+	assert.Nil(suite.T(), v)
+
+	suite.db.Clear()
+	suite.db.Close()
+}
+
+func (suite *BoltdbTestSuite) TestBatch_changed() {
+	snapshots := map[string]*ShardKeeperDbValue{suite.shardId: {Spec: suite.spec}}
+
+	// sync期间收到drop，不能覆盖
+	assert.Nil(suite.T(), suite.db.Drop([]string{suite.shardId}))
+	puts := map[string]*ShardKeeperDbValue{suite.shardId: {Spec: suite.spec, Disp: true}}
+	assert.Nil(suite.T(), suite.db.Batch(puts, nil, snapshots))
+	assert.Nil(suite.T(), suite.db.Batch(nil, []string{suite.shardId}, snapshots))
+
+	v, _ := suite.db.Get([]byte(suite.shardId))
+	var dbValue ShardKeeperDbValue
+	json.Unmarshal(v, &dbValue)
+	assert.True(suite.T(), dbValue.Drop)
+	assert.False(suite.T(), dbValue.Disp)
+
+	suite.db.Clear()
+	suite.db.Close()
+}
+
 func (suite *BoltdbTestSuite) TestMigrateLease() {
 	// already to
 	var err error
@@ -26,14 +26,17 @@ type etcddb struct {
 	// 单纯通过etcd做，要实现shard额软删除，需要标记etcd的key中的value，然后有goroutine感知，并同步到内存中的snapshot，
 	// shardkeeper感知的是这部分数据的变化。
 	client etcdutil.EtcdWrapper
+
+	paths *etcdutil.PathBuilder
 }
 
-func NewEtcddb(service string, containerId string, client etcdutil.EtcdWrapper) (*etcddb, error) {
+func NewEtcddb(service string, containerId string, client etcdutil.EtcdWrapper, paths *etcdutil.PathBuilder) (*etcddb, error) {
 	db := &etcddb{
 		service:     service,
 		containerId: containerId,
 
 		client: client,
+		paths:  paths,
 	}
 	db.mu.kvs = make(map[string]*ShardKeeperDbValue)
 	return db, nil
@@ -53,7 +56,7 @@ func (db *etcddb) Add(shard *ShardSpec) error {
 		Disp: false,
 		Drop: false,
 	}
-	shardPath := etcdutil.ShardPath(db.service, db.containerId, shard.Id)
+	shardPath := db.paths.ShardPath(db.service, db.containerId, shard.Id)
 
 	// 下面这段是辅助追查问题
 	gresp, err := db.client.GetKV(context.TODO(), shardPath, nil)
@@ -128,8 +131,10 @@ func (db *etcddb) ForEach(visitor func(shardID string, dv *ShardKeeperDbValue) e
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	// visitor拿到的是副本，和boltdb一样，修改需要通过Put或者Batch写回
 	for _, dv := range db.mu.kvs {
-		if err := visitor(dv.Spec.Id, dv); err != nil {
+		cp := *dv
+		if err := visitor(dv.Spec.Id, &cp); err != nil {
 			return err
 		}
 	}
@@ -147,7 +152,7 @@ func (db *etcddb) MigrateLease(from, to clientv3.LeaseID) error {
 
 	for shardID, dv := range db.mu.kvs {
 		if dv.SoftMigrate(from, to) {
-			if err := db.client.UpdateKV(context.TODO(), etcdutil.ShardPath(db.service, db.containerId, shardID), dv.String()); err != nil {
+			if err := db.client.UpdateKV(context.TODO(), db.paths.ShardPath(db.service, db.containerId, shardID), dv.String()); err != nil {
 				return err
 			}
 		}
@@ -204,7 +209,7 @@ func (db *etcddb) Reset() error {
 	}
 
 	// etcd场景，通过Reset重新加载etcd中的分片
-	dir := etcdutil.ShardDir(db.service, db.containerId)
+	dir := db.paths.ShardDir(db.service, db.containerId)
 	kvs, err := db.client.GetKVs(context.TODO(), dir)
 	if err != nil {
 		return err
@@ -226,7 +231,7 @@ func (db *etcddb) Reset() error {
 
 // Put 写入etcd后才能，标记缓存
 func (db *etcddb) Put(shardID string, dv *ShardKeeperDbValue) error {
-	if err := db.client.UpdateKV(context.TODO(), etcdutil.ShardPath(db.service, db.containerId, shardID), dv.String()); err != nil {
+	if err := db.client.UpdateKV(context.TODO(), db.paths.ShardPath(db.service, db.containerId, shardID), dv.String()); err != nil {
 		return err
 	}
 
@@ -238,7 +243,7 @@ func (db *etcddb) Put(shardID string, dv *ShardKeeperDbValue) error {
 
 // Remove 移除etcd后才能，删除缓存
 func (db *etcddb) Remove(shardID string) error {
-	if err := db.client.DelKV(context.TODO(), etcdutil.ShardPath(db.service, db.containerId, shardID)); err != nil {
+	if err := db.client.DelKV(context.TODO(), db.paths.ShardPath(db.service, db.containerId, shardID)); err != nil {
 		return err
 	}
 
@@ -248,6 +253,89 @@ func (db *etcddb) Remove(shardID string) error {
 	return nil
 }
 
+// Batch 按照 etcdutil.DefaultMaxTxnOps 分段提交，每段成功后更新缓存，
+// 提交期间缓存中的值发生变化时不覆盖
+func (db *etcddb) Batch(puts map[string]*ShardKeeperDbValue, removes []string, snapshots map[string]*ShardKeeperDbValue) error {
+	var (
+		ops      []clientv3.Op
+		shardIDs []string
+	)
+	// current 缓存中的值和snapshot一致时返回，不一致时返回nil
+	current := func(shardID string) *ShardKeeperDbValue {
+		cur, ok := db.mu.kvs[shardID]
+		if !ok {
+			return nil
+		}
+		if changed(cur, snapshots[shardID]) {
+			logutil.Info(
+				"shard changed during sync, skip",
+				zap.String("service", db.service),
+				zap.String("shard-id", shardID),
+			)
+			return nil
+		}
+		return cur
+	}
+
+	// expected 提交前缓存中的值，提交成功后缓存没有被修改过才更新
+	expected := make(map[string]*ShardKeeperDbValue)
+	db.mu.Lock()
+	for shardID, dv := range puts {
+		if _, ok := db.mu.kvs[shardID]; !ok {
+			logutil.Warn(
+				"shard not exist when try to put",
+				zap.String("service", db.service),
+				zap.String("shard-id", shardID),
+			)
+			continue
+		}
+		cur := current(shardID)
+		if cur == nil {
+			continue
+		}
+		e := *cur
+		expected[shardID] = &e
+		ops = append(ops, clientv3.OpPut(db.paths.ShardPath(db.service, db.containerId, shardID), dv.String()))
+		shardIDs = append(shardIDs, shardID)
+	}
+	for _, shardID := range removes {
+		cur := current(shardID)
+		if cur == nil {
+			continue
+		}
+		e := *cur
+		expected[shardID] = &e
+		ops = append(ops, clientv3.OpDelete(db.paths.ShardPath(db.service, db.containerId, shardID)))
+		shardIDs = append(shardIDs, shardID)
+	}
+	db.mu.Unlock()
+
+	for start := 0; start < len(ops); start += etcdutil.DefaultMaxTxnOps {
+		end := start + etcdutil.DefaultMaxTxnOps
+		if end > len(ops) {
+			end = len(ops)
+		}
+		if _, err := db.client.CommitTxn(context.TODO(), nil, ops[start:end]); err != nil {
+			return err
+		}
+
+		db.mu.Lock()
+		for _, shardID := range shardIDs[start:end] {
+			if cur, ok := db.mu.kvs[shardID]; ok && changed(cur, expected[shardID]) {
+				// 提交过程中收到的指令修改了缓存，保留缓存，由下次sync写入etcd
+				continue
+			}
+			if dv, ok := puts[shardID]; ok {
+				db.mu.kvs[shardID] = dv
+			} else {
+				delete(db.mu.kvs, shardID)
+			}
+		}
+		db.mu.Unlock()
+	}
+	return nil
+}
+
 func (db *etcddb) Update(k, v []byte) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
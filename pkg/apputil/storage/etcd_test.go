@@ -23,12 +23,12 @@ func TestEtcd(t *testing.T) {
 func (suite *EtcdTestSuite) SetupTest() {
 	service := "foo"
 	containerId := "127.0.0.1:8801"
-	suite.db, _ = NewEtcddb(service, containerId, nil)
+	suite.db, _ = NewEtcddb(service, containerId, nil, etcdutil.NewPathBuilder(""))
 }
 
 func (suite *EtcdTestSuite) TestAdd() {
 	shard := &ShardSpec{Id: mock.Anything}
-	shardPath := etcdutil.ShardPath(suite.db.service, suite.db.containerId, shard.Id)
+	shardPath := suite.db.paths.ShardPath(suite.db.service, suite.db.containerId, shard.Id)
 
 	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
 	mockedEtcdWrapper.On("GetKV", mock.Anything, shardPath, mock.Anything).Return(&clientv3.GetResponse{}, nil)
@@ -62,6 +62,55 @@ func (suite *EtcdTestSuite) TestDrop() {
 	assert.False(suite.T(), suite.db.mu.kvs["foo"].Drop)
 }
 
+func (suite *EtcdTestSuite) TestBatch() {
+	suite.db.mu.kvs["s1"] = &ShardKeeperDbValue{Spec: &ShardSpec{Id: "s1"}}
+	suite.db.mu.kvs["s2"] = &ShardKeeperDbValue{Spec: &ShardSpec{Id: "s2"}}
+
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("CommitTxn", mock.Anything, mock.Anything, mock.Anything).Return(&clientv3.TxnResponse{Succeeded: true}, nil)
+	suite.db.client = mockedEtcdWrapper
+
+	puts := map[string]*ShardKeeperDbValue{
+		"s1": {Spec: &ShardSpec{Id: "s1"}, Disp: true},
+		"s3": {Spec: &ShardSpec{Id: "s3"}},
+	}
+	err := suite.db.Batch(puts, []string{"s2"}, nil)
+	assert.Nil(suite.T(), err)
+	mockedEtcdWrapper.AssertNumberOfCalls(suite.T(), "CommitTxn", 1)
+	ops := mockedEtcdWrapper.Calls[0].Arguments.Get(2).([]clientv3.Op)
+	assert.Len(suite.T(), ops, 2)
+
+	assert.True(suite.T(), suite.db.mu.kvs["s1"].Disp)
+	assert.NotContains(suite.T(), suite.db.mu.kvs, "s2")
+	assert.NotContains(suite.T(), suite.db.mu.kvs, "s3")
+}
+
+func (suite *EtcdTestSuite) TestBatch_changed() {
+	suite.db.mu.kvs["s1"] = &ShardKeeperDbValue{Spec: &ShardSpec{Id: "s1"}}
+	suite.db.mu.kvs["s2"] = &ShardKeeperDbValue{Spec: &ShardSpec{Id: "s2"}}
+	snapshots := map[string]*ShardKeeperDbValue{
+		"s1": {Spec: &ShardSpec{Id: "s1"}},
+		"s2": {Spec: &ShardSpec{Id: "s2"}},
+	}
+
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("CommitTxn", mock.Anything, mock.Anything, mock.Anything).Return(&clientv3.TxnResponse{Succeeded: true}, nil)
+	suite.db.client = mockedEtcdWrapper
+
+	// sync期间s1收到drop，只提交s2
+	assert.Nil(suite.T(), suite.db.Drop([]string{"s1"}))
+	puts := map[string]*ShardKeeperDbValue{
+		"s1": {Spec: &ShardSpec{Id: "s1"}, Disp: true},
+		"s2": {Spec: &ShardSpec{Id: "s2"}, Disp: true},
+	}
+	assert.Nil(suite.T(), suite.db.Batch(puts, nil, snapshots))
+	ops := mockedEtcdWrapper.Calls[0].Arguments.Get(2).([]clientv3.Op)
+	assert.Len(suite.T(), ops, 1)
+	assert.True(suite.T(), suite.db.mu.kvs["s1"].Drop)
+	assert.False(suite.T(), suite.db.mu.kvs["s1"].Disp)
+	assert.True(suite.T(), suite.db.mu.kvs["s2"].Disp)
+}
+
 func (suite *EtcdTestSuite) TestMigrateLease() {
 	suite.db.mu.kvs[mock.Anything] = &ShardKeeperDbValue{
 		Spec: &ShardSpec{
@@ -72,7 +121,7 @@ func (suite *EtcdTestSuite) TestMigrateLease() {
 	}
 
 	// ok
-	shardPath := etcdutil.ShardPath(suite.db.service, suite.db.containerId, mock.Anything)
+	shardPath := suite.db.paths.ShardPath(suite.db.service, suite.db.containerId, mock.Anything)
 	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
 	mockedEtcdWrapper.On("UpdateKV", mock.Anything, shardPath, mock.Anything).Return(nil)
 	suite.db.client = mockedEtcdWrapper
@@ -140,7 +189,7 @@ func (suite *EtcdTestSuite) TestReset() {
 	kvs[mock.Anything] = dv.String()
 
 	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
-	mockedEtcdWrapper.On("GetKVs", mock.Anything, etcdutil.ShardDir(suite.db.service, suite.db.containerId)).Return(kvs, nil)
+	mockedEtcdWrapper.On("GetKVs", mock.Anything, suite.db.paths.ShardDir(suite.db.service, suite.db.containerId)).Return(kvs, nil)
 	suite.db.client = mockedEtcdWrapper
 
 	err := suite.db.Reset()
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"encoding/json"
+	"hash/crc32"
 	"math"
 	"time"
 
@@ -103,6 +104,54 @@ type ShardKeeperDbValue struct {
 
 	// Drop 软删除，在异步协程中清理
 	Drop bool `json:"drop"`
+
+	// Update 只有Task变化，在异步协程中原地更新
+	Update bool `json:"update"`
+
+	// State 只在心跳中携带，不持久化，见 ShardState
+	State ShardState `json:"state,omitempty"`
+
+	// Load 只在心跳中携带，app实现了 core.ShardLoadReporter 时才有值
+	Load *ShardLoad `json:"load,omitempty"`
+}
+
+// ShardLoad app上报的单个shard的负载，smserver用来判断container的热点
+type ShardLoad struct {
+	// QPS shard当前的请求量
+	QPS float64 `json:"qps"`
+	// Bytes shard占用或者处理的数据量，具体含义由app决定
+	Bytes int64 `json:"bytes"`
+	// Cost app自定义的开销，不同shard之间可以比较即可
+	Cost float64 `json:"cost"`
+}
+
+// TaskHash 紧凑格式的心跳不携带task，smserver通过hash判断是否需要原地更新
+func TaskHash(task string) uint32 {
+	return crc32.ChecksumIEEE([]byte(task))
+}
+
+// ShardState shard在app中的生命周期：assigned -> starting -> serving -> stopping，任何阶段都可能进入failed
+type ShardState string
+
+const (
+	// ShardStateAssigned 已经分配到container，还没有下发给app
+	ShardStateAssigned ShardState = "assigned"
+	// ShardStateStarting 已经下发给app，app还在加载
+	ShardStateStarting ShardState = "starting"
+	// ShardStateServing app加载完成，可以提供服务
+	ShardStateServing ShardState = "serving"
+	// ShardStateStopping 正在从app中移除
+	ShardStateStopping ShardState = "stopping"
+	// ShardStateFailed 下发失败或者app上报异常
+	ShardStateFailed ShardState = "failed"
+)
+
+func (s ShardState) Validate() error {
+	switch s {
+	case ShardStateAssigned, ShardStateStarting, ShardStateServing, ShardStateStopping, ShardStateFailed:
+		return nil
+	}
+	return errors.Errorf("unknown shard state [%s]", s)
 }
 
 func (dv *ShardKeeperDbValue) String() string {
@@ -110,6 +159,14 @@ func (dv *ShardKeeperDbValue) String() string {
 	return string(b)
 }
 
+// changed storage中的当前值和snapshot不一致，snapshot为空时认为没有变化
+func changed(cur, snapshot *ShardKeeperDbValue) bool {
+	if snapshot == nil {
+		return false
+	}
+	return cur.String() != snapshot.String()
+}
+
 func (dv *ShardKeeperDbValue) SoftMigrate(from, to clientv3.LeaseID) bool {
 	// 不需要做移动，逻辑幂等的一部分
 	if dv.Spec.Lease.ID == to {
@@ -183,6 +240,11 @@ type Storage interface {
 	// 区分于Delete，语序包含特定于实现的逻辑
 	Remove(shardID string) error
 
+	// Batch
+	// sync结束后一次性提交，puts中已经不存在的shard忽略，
+	// snapshots中存在的shard，storage中的值和snapshot不一致（sync期间收到新的指令）时跳过，由下次sync处理
+	Batch(puts map[string]*ShardKeeperDbValue, removes []string, snapshots map[string]*ShardKeeperDbValue) error
+
 	// Update
 	// for unittest
 	Update(k, v []byte) error
@@ -27,7 +27,13 @@ func (m *MockedStorage) Drop(ids []string) error {
 }
 
 func (m *MockedStorage) ForEach(visitor func(shardID string, dv *ShardKeeperDbValue) error) error {
-	panic("implement me")
+	args := m.Called()
+	for shardID, dv := range args.Get(0).(map[string]*ShardKeeperDbValue) {
+		if err := visitor(shardID, dv); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
 }
 
 func (m *MockedStorage) MigrateLease(from, to clientv3.LeaseID) error {
@@ -50,7 +56,13 @@ func (m *MockedStorage) Put(shardID string, dv *ShardKeeperDbValue) error {
 }
 
 func (m *MockedStorage) Remove(shardID string) error {
-	panic("implement me")
+	args := m.Called(shardID)
+	return args.Error(0)
+}
+
+func (m *MockedStorage) Batch(puts map[string]*ShardKeeperDbValue, removes []string, snapshots map[string]*ShardKeeperDbValue) error {
+	args := m.Called(puts, removes)
+	return args.Error(0)
 }
 
 func (m *MockedStorage) Update(k, v []byte) error {
@@ -0,0 +1,156 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commonutil
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/entertainment-venue/sm/pkg/logutil"
+)
+
+// DelayCallback key到期后回调调用方，在 DelayQueue 的goroutine中执行，不能阻塞
+type DelayCallback func(key string)
+
+// DelayQueue 基于 PriorityQueue 的延迟调度，同一个key只保留最后一次调度，
+// 到期时间作为优先级（取负数，最早到期的在堆顶），只需要一个goroutine等待堆顶的元素到期
+type DelayQueue struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	pq PriorityQueue
+	// items key和堆中元素的映射，支持取消和重新调度
+	items map[string]*Item
+
+	// ch 堆顶变化时唤醒等待的goroutine
+	ch chan struct{}
+
+	callback DelayCallback
+}
+
+func NewDelayQueue(callback DelayCallback) *DelayQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	dq := DelayQueue{
+		ctx:      ctx,
+		cancel:   cancel,
+		items:    make(map[string]*Item),
+		ch:       make(chan struct{}, 1),
+		callback: callback,
+	}
+	dq.wg.Add(1)
+	go dq.run()
+	return &dq
+}
+
+// Schedule key在at时刻到期，key已经存在时更新到期时间
+func (dq *DelayQueue) Schedule(key string, at time.Time) {
+	dq.mu.Lock()
+	if item, ok := dq.items[key]; ok {
+		item.Priority = -at.UnixNano()
+		heap.Fix(&dq.pq, item.Index)
+	} else {
+		item := &Item{Value: key, Priority: -at.UnixNano()}
+		heap.Push(&dq.pq, item)
+		dq.items[key] = item
+	}
+	dq.mu.Unlock()
+	dq.wakeUp()
+}
+
+// Cancel 取消key的调度，key不存在返回false
+func (dq *DelayQueue) Cancel(key string) bool {
+	dq.mu.Lock()
+	defer dq.mu.Unlock()
+
+	item, ok := dq.items[key]
+	if !ok {
+		return false
+	}
+	heap.Remove(&dq.pq, item.Index)
+	delete(dq.items, key)
+	return true
+}
+
+// Len 等待到期的key数量
+func (dq *DelayQueue) Len() int {
+	dq.mu.Lock()
+	defer dq.mu.Unlock()
+	return len(dq.items)
+}
+
+func (dq *DelayQueue) Close() {
+	dq.cancel()
+	dq.wg.Wait()
+}
+
+func (dq *DelayQueue) wakeUp() {
+	select {
+	case dq.ch <- struct{}{}:
+	default:
+	}
+}
+
+func (dq *DelayQueue) run() {
+	defer dq.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		dq.mu.Lock()
+		var (
+			key  string
+			wait time.Duration = -1
+		)
+		if len(dq.pq) > 0 {
+			top := dq.pq[0]
+			wait = time.Until(time.Unix(0, -top.Priority))
+			if wait <= 0 {
+				heap.Pop(&dq.pq)
+				delete(dq.items, top.Value)
+				key = top.Value
+			}
+		}
+		dq.mu.Unlock()
+
+		if key != "" {
+			dq.callback(key)
+			continue
+		}
+
+		var timeout <-chan time.Time
+		if wait > 0 {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			timeout = timer.C
+		}
+
+		select {
+		case <-dq.ctx.Done():
+			logutil.Info("delay queue exit")
+			return
+		case <-dq.ch:
+		case <-timeout:
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commonutil
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_DelayQueue_order(t *testing.T) {
+	ch := make(chan string, 3)
+	dq := NewDelayQueue(func(key string) { ch <- key })
+	defer dq.Close()
+
+	now := time.Now()
+	dq.Schedule("c", now.Add(300*time.Millisecond))
+	dq.Schedule("a", now.Add(100*time.Millisecond))
+	dq.Schedule("b", now.Add(200*time.Millisecond))
+
+	for _, expect := range []string{"a", "b", "c"} {
+		select {
+		case actual := <-ch:
+			if actual != expect {
+				t.Errorf("expect %s, actual %s", expect, actual)
+				t.SkipNow()
+			}
+		case <-time.After(time.Second):
+			t.Errorf("expect %s timeout", expect)
+			t.SkipNow()
+		}
+	}
+	if dq.Len() != 0 {
+		t.Errorf("expect empty, actual %d", dq.Len())
+	}
+}
+
+func Test_DelayQueue_cancel(t *testing.T) {
+	ch := make(chan string, 2)
+	dq := NewDelayQueue(func(key string) { ch <- key })
+	defer dq.Close()
+
+	now := time.Now()
+	dq.Schedule("a", now.Add(100*time.Millisecond))
+	dq.Schedule("b", now.Add(200*time.Millisecond))
+	if !dq.Cancel("a") {
+		t.Error("cancel a should be true")
+		t.SkipNow()
+	}
+	if dq.Cancel("c") {
+		t.Error("cancel c should be false")
+		t.SkipNow()
+	}
+
+	select {
+	case actual := <-ch:
+		if actual != "b" {
+			t.Errorf("expect b, actual %s", actual)
+		}
+	case <-time.After(time.Second):
+		t.Error("expect b timeout")
+	}
+}
+
+func Test_DelayQueue_reschedule(t *testing.T) {
+	ch := make(chan string, 2)
+	dq := NewDelayQueue(func(key string) { ch <- key })
+	defer dq.Close()
+
+	now := time.Now()
+	dq.Schedule("a", now.Add(100*time.Millisecond))
+	dq.Schedule("b", now.Add(200*time.Millisecond))
+	// a延后到b之后
+	dq.Schedule("a", now.Add(300*time.Millisecond))
+	if dq.Len() != 2 {
+		t.Errorf("expect 2, actual %d", dq.Len())
+		t.SkipNow()
+	}
+
+	for _, expect := range []string{"b", "a"} {
+		select {
+		case actual := <-ch:
+			if actual != expect {
+				t.Errorf("expect %s, actual %s", expect, actual)
+				t.SkipNow()
+			}
+		case <-time.After(time.Second):
+			t.Errorf("expect %s timeout", expect)
+			t.SkipNow()
+		}
+	}
+}
@@ -3,6 +3,7 @@ package commonutil
 import (
 	"context"
 	"errors"
+	"hash/fnv"
 	"sync"
 
 	"github.com/entertainment-venue/sm/pkg/logutil"
@@ -18,6 +19,19 @@ var (
 	ErrCallbackNil   = errors.New("trigger: callback nil")
 	ErrCallbackExist = errors.New("trigger: callback exist")
 	ErrEventIllegal  = errors.New("trigger: event error")
+	ErrTriggerFull   = errors.New("trigger: full")
+)
+
+// BackpressurePolicy 设置容量后，队列满时 Put 的处理方式
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock Put阻塞，直到有空间或者trigger关闭
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureDropOldest 丢弃最早进入队列的事件
+	BackpressureDropOldest
+	// BackpressureReject Put返回 ErrTriggerFull
+	BackpressureReject
 )
 
 // https://github.com/grpc/grpc-go/blob/689f7b154ee8a3f3ab6a6107ff7ad78189baae06/internal/transport/controlbuf.go#L40
@@ -29,16 +43,19 @@ type itemNode struct {
 type itemList struct {
 	head *itemNode
 	tail *itemNode
+	len  int
 }
 
-func (il *itemList) enqueue(i interface{}) {
+func (il *itemList) enqueue(i interface{}) *itemNode {
 	n := &itemNode{it: i}
+	il.len++
 	if il.tail == nil {
 		il.head, il.tail = n, n
-		return
+		return n
 	}
 	il.tail.next = n
 	il.tail = n
+	return n
 }
 
 func (il *itemList) dequeue() interface{} {
@@ -50,21 +67,16 @@ func (il *itemList) dequeue() interface{} {
 	if il.head == nil {
 		il.tail = nil
 	}
+	il.len--
 	return i
 }
 
 func (il *itemList) ForEach(visitor func(it interface{}) error) error {
-	if il.head == nil {
-		return nil
-	}
-
 	// 保持head位置不变，临时变量ih走到tail，逐个调用visitor
-	ih := il.head
-	for ih != il.tail {
+	for ih := il.head; ih != nil; ih = ih.next {
 		if err := visitor(ih.it); err != nil {
 			return err
 		}
-		ih = ih.next
 	}
 	return nil
 }
@@ -79,6 +91,21 @@ type Trigger interface {
 type TriggerEvent struct {
 	Key   string
 	Value interface{}
+
+	// PartitionKey 开启 WithOrdered 后，相同PartitionKey的事件由同一个goroutine顺序执行，
+	// 开启 WithCoalesce 后，Key和PartitionKey相同的事件只保留最新的，为空时使用Key
+	PartitionKey string
+}
+
+func (ev *TriggerEvent) partition() string {
+	if ev.PartitionKey == "" {
+		return ev.Key
+	}
+	return ev.PartitionKey
+}
+
+func (ev *TriggerEvent) coalesceKey() string {
+	return ev.Key + "/" + ev.PartitionKey
 }
 
 type trigger struct {
@@ -88,11 +115,15 @@ type trigger struct {
 	wg sync.WaitGroup
 
 	listMu sync.Mutex
-	// list 无边界的缓存，使用chan需要有边界，存储外部事件。
-	// 这里的 itemList 没有按照key做区分，同key的事件在goroutine池有资源的情况下，
-	// 可能并发执行，所以调用方提供的callback需要保证threadsafe，即便做到同key顺序下发，
-	// 不同key的配置更新，对于调用方的callback方法也可能访问相同的资源。
+	// list 默认是无边界的缓存，使用chan需要有边界，存储外部事件。
+	// 默认情况下 itemList 没有按照key做区分，同key的事件在goroutine池有资源的情况下，
+	// 可能并发执行，所以调用方提供的callback需要保证threadsafe，开启 WithOrdered 可以做到同PartitionKey顺序下发，
+	// 但不同PartitionKey的事件，对于调用方的callback方法也可能访问相同的资源。
 	list *itemList
+	// pending 开启 WithCoalesce 后，记录list中还未被消费的事件
+	pending map[string]*itemNode
+	// notFull 开启 WithCapacity 并且是 BackpressureBlock 时，等待list有空间
+	notFull *sync.Cond
 
 	// ch 和 consumerWaiting 的设定参考：
 	// https://github.com/grpc/grpc-go/blob/689f7b154ee8a3f3ab6a6107ff7ad78189baae06/internal/transport/controlbuf.go#L286
@@ -107,6 +138,10 @@ type trigger struct {
 
 	// buffer 存储提交给goroutine池的
 	buffer chan *TriggerEvent
+	// buffers 开启 WithOrdered 后，每个goroutine有单独的buffer，PartitionKey哈希到固定的goroutine
+	buffers []chan *TriggerEvent
+
+	opts *triggerOptions
 }
 
 // TriggerCallback 把event的value给到调用方
@@ -115,6 +150,17 @@ type TriggerCallback func(key string, value interface{}) error
 type triggerOptions struct {
 	// workerSize 处理callback的goroutine数量
 	workerSize int
+
+	// ordered 相同PartitionKey的事件顺序执行
+	ordered bool
+
+	// coalesce 相同Key和PartitionKey的事件在队列中只保留最新的
+	coalesce bool
+
+	// capacity 队列中未处理事件的上限，<=0不限制
+	capacity int
+	// backpressure 队列满时的处理方式
+	backpressure BackpressurePolicy
 }
 
 type TriggerOption func(options *triggerOptions)
@@ -125,6 +171,30 @@ func WithWorkerSize(v int) TriggerOption {
 	}
 }
 
+func WithOrdered(v bool) TriggerOption {
+	return func(options *triggerOptions) {
+		options.ordered = v
+	}
+}
+
+func WithCoalesce(v bool) TriggerOption {
+	return func(options *triggerOptions) {
+		options.coalesce = v
+	}
+}
+
+func WithCapacity(v int) TriggerOption {
+	return func(options *triggerOptions) {
+		options.capacity = v
+	}
+}
+
+func WithBackpressure(v BackpressurePolicy) TriggerOption {
+	return func(options *triggerOptions) {
+		options.backpressure = v
+	}
+}
+
 func NewTrigger(opts ...TriggerOption) (Trigger, error) {
 	ops := &triggerOptions{}
 	for _, opt := range opts {
@@ -139,10 +209,13 @@ func NewTrigger(opts ...TriggerOption) (Trigger, error) {
 		wg:         sync.WaitGroup{},
 
 		list:      &itemList{},
+		pending:   make(map[string]*itemNode),
 		buffer:    make(chan *TriggerEvent, defaultBufferSize),
 		ch:        make(chan struct{}, 1),
 		callbacks: make(map[string]TriggerCallback),
+		opts:      ops,
 	}
+	tgr.notFull = sync.NewCond(&tgr.listMu)
 
 	// 运行worker
 	workerSize := ops.workerSize
@@ -150,8 +223,13 @@ func NewTrigger(opts ...TriggerOption) (Trigger, error) {
 		workerSize = defaultWorkerSize
 	}
 	for i := 0; i < workerSize; i++ {
+		buffer := tgr.buffer
+		if ops.ordered {
+			buffer = make(chan *TriggerEvent, defaultBufferSize)
+			tgr.buffers = append(tgr.buffers, buffer)
+		}
 		tgr.wg.Add(1)
-		go tgr.run()
+		go tgr.run(buffer)
 	}
 
 	// 运行event获取
@@ -182,6 +260,12 @@ func (tgr *trigger) Close() {
 	if tgr.cancelFunc != nil {
 		tgr.cancelFunc()
 	}
+
+	// 唤醒阻塞在Put中的goroutine
+	tgr.listMu.Lock()
+	tgr.notFull.Broadcast()
+	tgr.listMu.Unlock()
+
 	tgr.wg.Wait()
 }
 
@@ -192,11 +276,47 @@ func (tgr *trigger) Put(event *TriggerEvent) error {
 
 	var wakeUp bool
 	tgr.listMu.Lock()
+
+	// 未处理的事件只保留最新的，位置不变
+	if tgr.opts.coalesce {
+		if n, ok := tgr.pending[event.coalesceKey()]; ok {
+			n.it = event
+			tgr.listMu.Unlock()
+			return nil
+		}
+	}
+
+	if tgr.opts.capacity > 0 {
+		for tgr.list.len >= tgr.opts.capacity {
+			switch tgr.opts.backpressure {
+			case BackpressureReject:
+				tgr.listMu.Unlock()
+				return ErrTriggerFull
+			case BackpressureDropOldest:
+				dropped := tgr.dequeue()
+				logutil.Warn(
+					"trigger full, drop oldest event",
+					zap.String("ev-key", dropped.Key),
+					zap.String("ev-partition-key", dropped.PartitionKey),
+				)
+			default:
+				if tgr.ctx.Err() != nil {
+					tgr.listMu.Unlock()
+					return ErrClosing
+				}
+				tgr.notFull.Wait()
+			}
+		}
+	}
+
 	if tgr.consumerWaiting {
 		wakeUp = true
 		tgr.consumerWaiting = false
 	}
-	tgr.list.enqueue(event)
+	n := tgr.list.enqueue(event)
+	if tgr.opts.coalesce {
+		tgr.pending[event.coalesceKey()] = n
+	}
 	tgr.listMu.Unlock()
 	if wakeUp {
 		select {
@@ -207,15 +327,38 @@ func (tgr *trigger) Put(event *TriggerEvent) error {
 	return nil
 }
 
+// dequeue 需要在listMu的保护下调用
+func (tgr *trigger) dequeue() *TriggerEvent {
+	h := tgr.list.dequeue()
+	if h == nil {
+		return nil
+	}
+	ev := h.(*TriggerEvent)
+	if tgr.opts.coalesce {
+		delete(tgr.pending, ev.coalesceKey())
+	}
+	tgr.notFull.Signal()
+	return ev
+}
+
 func (tgr *trigger) get() {
 	defer tgr.wg.Done()
 	for {
 		tgr.listMu.Lock()
-		h := tgr.list.dequeue()
-		if h != nil {
+		ev := tgr.dequeue()
+		if ev != nil {
 			tgr.listMu.Unlock()
-			ev := h.(*TriggerEvent)
-			tgr.buffer <- ev
+
+			buffer := tgr.buffer
+			if tgr.opts.ordered {
+				buffer = tgr.buffers[tgr.index(ev)]
+			}
+			select {
+			case buffer <- ev:
+			case <-tgr.ctx.Done():
+				logutil.Info("get exit")
+				return
+			}
 			continue
 		}
 
@@ -232,15 +375,30 @@ func (tgr *trigger) get() {
 	}
 }
 
-func (tgr *trigger) run() {
+// index PartitionKey哈希到固定的goroutine
+func (tgr *trigger) index(ev *TriggerEvent) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(ev.partition()))
+	return int(h.Sum32() % uint32(len(tgr.buffers)))
+}
+
+func (tgr *trigger) run(buffer chan *TriggerEvent) {
 	defer tgr.wg.Done()
 	for {
 		select {
 		case <-tgr.ctx.Done():
 			logutil.Info("run exit")
 			return
-		case ev := <-tgr.buffer:
-			if err := tgr.callbacks[ev.Key](ev.Key, ev.Value); err != nil {
+		case ev := <-buffer:
+			callback, ok := tgr.callbacks[ev.Key]
+			if !ok {
+				logutil.Error(
+					"callback not found",
+					zap.String("ev-key", ev.Key),
+				)
+				continue
+			}
+			if err := callback(ev.Key, ev.Value); err != nil {
 				logutil.Error(
 					"callback error",
 					zap.Error(err),
@@ -252,5 +410,7 @@ func (tgr *trigger) run() {
 }
 
 func (tgr *trigger) ForEach(visitor func(it interface{}) error) error {
+	tgr.listMu.Lock()
+	defer tgr.listMu.Unlock()
 	return tgr.list.ForEach(visitor)
 }
@@ -0,0 +1,216 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commonutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_trigger_ordered(t *testing.T) {
+	tgr, _ := NewTrigger(WithWorkerSize(4), WithOrdered(true))
+	defer tgr.Close()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		result = make(map[string][]int)
+	)
+	_ = tgr.Register("foo", func(key string, value interface{}) error {
+		defer wg.Done()
+		ev := value.([]interface{})
+		mu.Lock()
+		result[ev[0].(string)] = append(result[ev[0].(string)], ev[1].(int))
+		mu.Unlock()
+		return nil
+	})
+
+	partitions := []string{"a", "b", "c", "d", "e"}
+	for i := 0; i < 100; i++ {
+		for _, p := range partitions {
+			wg.Add(1)
+			if err := tgr.Put(&TriggerEvent{Key: "foo", PartitionKey: p, Value: []interface{}{p, i}}); err != nil {
+				t.Error(err)
+				t.SkipNow()
+			}
+		}
+	}
+	wg.Wait()
+
+	for _, p := range partitions {
+		if len(result[p]) != 100 {
+			t.Errorf("partition %s expect 100, actual %d", p, len(result[p]))
+			t.SkipNow()
+		}
+		for i, v := range result[p] {
+			if i != v {
+				t.Errorf("partition %s out of order at %d: %d", p, i, v)
+				t.SkipNow()
+			}
+		}
+	}
+}
+
+func Test_trigger_coalesce(t *testing.T) {
+	tgr, _ := NewTrigger(WithCoalesce(true))
+	defer tgr.Close()
+
+	var (
+		mu     sync.Mutex
+		result []string
+		block  = make(chan struct{})
+		done   = make(chan struct{})
+	)
+	_ = tgr.Register("foo", func(key string, value interface{}) error {
+		if value.(string) == "block" {
+			<-block
+			return nil
+		}
+		mu.Lock()
+		result = append(result, value.(string))
+		n := len(result)
+		mu.Unlock()
+		if n == 2 {
+			close(done)
+		}
+		return nil
+	})
+
+	// 第一个事件阻塞worker，后续事件在队列中合并
+	_ = tgr.Put(&TriggerEvent{Key: "foo", PartitionKey: "x", Value: "block"})
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		_ = tgr.Put(&TriggerEvent{Key: "foo", PartitionKey: "a", Value: fmt.Sprintf("a%d", i)})
+		_ = tgr.Put(&TriggerEvent{Key: "foo", PartitionKey: "b", Value: fmt.Sprintf("b%d", i)})
+	}
+	close(block)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("coalesce timeout")
+		t.SkipNow()
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(result) != 2 || result[0] != "a9" || result[1] != "b9" {
+		t.Errorf("unexpected result %v", result)
+	}
+}
+
+func Test_trigger_reject(t *testing.T) {
+	tgr, _ := NewTrigger(WithCapacity(1), WithBackpressure(BackpressureReject))
+	defer tgr.Close()
+
+	block := make(chan struct{})
+	defer close(block)
+	_ = tgr.Register("foo", func(key string, value interface{}) error {
+		<-block
+		return nil
+	})
+
+	// worker和shared buffer都占满之后，队列中只能存放capacity个事件
+	var err error
+	for i := 0; i < defaultBufferSize+10; i++ {
+		if err = tgr.Put(&TriggerEvent{Key: "foo", Value: i}); err != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err != ErrTriggerFull {
+		t.Errorf("expect ErrTriggerFull, actual %v", err)
+	}
+}
+
+// newIdleTrigger 不启动goroutine，事件停留在队列中，方便观察容量限制
+func newIdleTrigger(opts ...TriggerOption) *trigger {
+	ops := &triggerOptions{}
+	for _, opt := range opts {
+		opt(ops)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	tgr := &trigger{
+		ctx:        ctx,
+		cancelFunc: cancel,
+		list:       &itemList{},
+		pending:    make(map[string]*itemNode),
+		ch:         make(chan struct{}, 1),
+		callbacks:  make(map[string]TriggerCallback),
+		opts:       ops,
+	}
+	tgr.notFull = sync.NewCond(&tgr.listMu)
+	return tgr
+}
+
+func Test_trigger_dropOldest(t *testing.T) {
+	tgr := newIdleTrigger(WithCapacity(2), WithBackpressure(BackpressureDropOldest))
+	defer tgr.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := tgr.Put(&TriggerEvent{Key: "foo", Value: i}); err != nil {
+			t.Error(err)
+			t.SkipNow()
+		}
+	}
+
+	var values []int
+	_ = tgr.ForEach(func(it interface{}) error {
+		values = append(values, it.(*TriggerEvent).Value.(int))
+		return nil
+	})
+	if len(values) != 2 || values[0] != 3 || values[1] != 4 {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func Test_trigger_block(t *testing.T) {
+	tgr := newIdleTrigger(WithCapacity(1))
+	_ = tgr.Put(&TriggerEvent{Key: "foo", Value: 0})
+
+	putc := make(chan error)
+	go func() {
+		putc <- tgr.Put(&TriggerEvent{Key: "foo", Value: 1})
+	}()
+	select {
+	case <-putc:
+		t.Error("put should block when full")
+		t.SkipNow()
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 消费后，阻塞的Put继续
+	tgr.listMu.Lock()
+	tgr.dequeue()
+	tgr.listMu.Unlock()
+	if err := <-putc; err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	// 关闭后，阻塞的Put返回 ErrClosing
+	go func() {
+		putc <- tgr.Put(&TriggerEvent{Key: "foo", Value: 2})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	tgr.Close()
+	if err := <-putc; err != ErrClosing {
+		t.Errorf("expect ErrClosing, actual %v", err)
+	}
+}
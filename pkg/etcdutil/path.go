@@ -4,55 +4,85 @@ import (
 	"path"
 )
 
-var (
-	pfx string
-)
+// DefaultPfx 没有指定etcdPrefix时sm使用的前缀
+const DefaultPfx = "/sm"
 
-func init() {
-	pfx = "/sm"
+// PathBuilder 生成sm在etcd中的路径，prefix由实例持有，同一个进程中可以接入多个sm集群
+type PathBuilder struct {
+	pfx string
 }
 
-func SetPfx(v string) {
-	if v != "" {
-		pfx = v
+func NewPathBuilder(pfx string) *PathBuilder {
+	if pfx == "" {
+		pfx = DefaultPfx
 	}
+	return &PathBuilder{pfx: pfx}
+}
+
+func (b *PathBuilder) Pfx() string {
+	return b.pfx
 }
 
-func ServicePath(service string) string {
-	return path.Join(pfx, "app", service)
+func (b *PathBuilder) ServicePath(service string) string {
+	return path.Join(b.pfx, "app", service)
 }
 
-func ShardPath(service, containerId, shardId string) string {
+func (b *PathBuilder) ShardPath(service, containerId, shardId string) string {
 	// s的命名方式参考开源项目；pd
-	return path.Join(ShardDir(service, containerId), shardId)
+	return path.Join(b.ShardDir(service, containerId), shardId)
 }
 
-func ShardDir(service, containerId string) string {
-	return path.Join(ServicePath(service), "s", containerId) + "/"
+func (b *PathBuilder) ShardDir(service, containerId string) string {
+	return path.Join(b.ServicePath(service), "s", containerId) + "/"
 }
 
-func ContainerPath(service, id string) string {
-	return path.Join(ServicePath(service), "containerhb", id)
+func (b *PathBuilder) ContainerPath(service, id string) string {
+	return path.Join(b.ServicePath(service), "containerhb", id)
 }
 
-func LeasePath(service string) string {
-	return path.Join(ServicePath(service), "lease")
+func (b *PathBuilder) LeasePath(service string) string {
+	return path.Join(b.ServicePath(service), "lease")
 }
 
-func LeaseBridgePath(service string) string {
-	return path.Join(LeasePath(service), "bridge")
+func (b *PathBuilder) LeaseBridgePath(service string) string {
+	return path.Join(b.LeasePath(service), "bridge")
 }
 
-func LeaseGuardPath(service string) string {
-	return path.Join(LeasePath(service), "guard")
+func (b *PathBuilder) LeaseGuardPath(service string) string {
+	return path.Join(b.LeasePath(service), "guard")
 }
 
-func LeaseSessionDir(service string) string {
-	return path.Join(LeasePath(service), "session")
+func (b *PathBuilder) LeaseSessionDir(service string) string {
+	return path.Join(b.LeasePath(service), "session")
 }
 
 // LeaseSessionPath 作为guard lease过期的监控点，在得到新的lease的时候创建，server可以通过不续约让这个节点过期，
 // 这样shardkeeper感知到guard lease被过期，发起shard drop动作，注意
-func LeaseSessionPath(service string, container string) string {
-	return path.Join(LeasePath(service), "session", container)
+func (b *PathBuilder) LeaseSessionPath(service string, container string) string {
+	return path.Join(b.LeasePath(service), "session", container)
+}
+
+// ReceiverPath container声明自己使用拉模式接收指令，绑定container的session lease，
+// server在下发指令前检查该节点，存在时把指令写入 CommandDir 而不是发http请求
+func (b *PathBuilder) ReceiverPath(service string, containerId string) string {
+	return path.Join(b.ReceiverDir(service), containerId)
+}
+
+// ReceiverDir service下所有声明拉模式的container，server通过watch维护
+func (b *PathBuilder) ReceiverDir(service string) string {
+	return path.Join(b.ServicePath(service), "receiver") + "/"
+}
+
+// CommandDir container的指令队列，container处理完指令后删除对应节点作为确认
+func (b *PathBuilder) CommandDir(service string, containerId string) string {
+	return path.Join(b.ServicePath(service), "command", containerId) + "/"
+}
+
+func (b *PathBuilder) CommandPath(service string, containerId string, commandId string) string {
+	return path.Join(b.CommandDir(service, containerId), commandId)
+}
+
+// SecretPath leader和receiver之间签名指令使用的共享密钥，leader接管service时创建
+func (b *PathBuilder) SecretPath(service string) string {
+	return path.Join(b.ServicePath(service), "secret")
 }
@@ -5,48 +5,86 @@ import (
 )
 
 func Test_EtcdPath(t *testing.T) {
-	if ServicePath("foo") != "/sm/app/foo" {
+	b := NewPathBuilder("")
+	if b.ServicePath("foo") != "/sm/app/foo" {
 		t.Errorf("path error")
 		t.SkipNow()
 	}
 
-	if ShardPath("foo", "127.0.0.1:80", "bar") != "/sm/app/foo/s/127.0.0.1:80/bar" {
+	if b.ShardPath("foo", "127.0.0.1:80", "bar") != "/sm/app/foo/s/127.0.0.1:80/bar" {
 		t.Errorf("path error")
 		t.SkipNow()
 	}
 
-	if ShardDir("foo", "127.0.0.1:80") != "/sm/app/foo/s/127.0.0.1:80/" {
+	if b.ShardDir("foo", "127.0.0.1:80") != "/sm/app/foo/s/127.0.0.1:80/" {
 		t.Errorf("path error")
 		t.SkipNow()
 	}
 
-	if ContainerPath("foo", "bar") != "/sm/app/foo/containerhb/bar" {
+	if b.ContainerPath("foo", "bar") != "/sm/app/foo/containerhb/bar" {
 		t.Errorf("path error")
 		t.SkipNow()
 	}
 
-	if LeasePath("foo") != "/sm/app/foo/lease" {
+	if b.LeasePath("foo") != "/sm/app/foo/lease" {
 		t.Errorf("path error")
 		t.SkipNow()
 	}
 
-	if LeaseBridgePath("foo") != "/sm/app/foo/lease/bridge" {
+	if b.LeaseBridgePath("foo") != "/sm/app/foo/lease/bridge" {
 		t.Errorf("path error")
 		t.SkipNow()
 	}
 
-	if LeaseGuardPath("foo") != "/sm/app/foo/lease/guard" {
+	if b.LeaseGuardPath("foo") != "/sm/app/foo/lease/guard" {
 		t.Errorf("path error")
 		t.SkipNow()
 	}
 
-	if LeaseSessionDir("foo") != "/sm/app/foo/lease/session" {
+	if b.LeaseSessionDir("foo") != "/sm/app/foo/lease/session" {
 		t.Errorf("path error")
 		t.SkipNow()
 	}
 
-	if LeaseSessionPath("foo", "127.0.0.1:80") != "/sm/app/foo/lease/session/127.0.0.1:80" {
+	if b.LeaseSessionPath("foo", "127.0.0.1:80") != "/sm/app/foo/lease/session/127.0.0.1:80" {
 		t.Errorf("path error")
 		t.SkipNow()
 	}
+
+	if b.ReceiverPath("foo", "c1") != "/sm/app/foo/receiver/c1" {
+		t.Errorf("path error")
+		t.SkipNow()
+	}
+
+	if b.ReceiverDir("foo") != "/sm/app/foo/receiver/" {
+		t.Errorf("path error")
+		t.SkipNow()
+	}
+
+	if b.CommandDir("foo", "c1") != "/sm/app/foo/command/c1/" {
+		t.Errorf("path error")
+		t.SkipNow()
+	}
+
+	if b.CommandPath("foo", "c1", "1") != "/sm/app/foo/command/c1/1" {
+		t.Errorf("path error")
+		t.SkipNow()
+	}
+
+	if b.SecretPath("foo") != "/sm/app/foo/secret" {
+		t.Errorf("path error")
+		t.SkipNow()
+	}
+}
+
+func Test_EtcdPath_pfx(t *testing.T) {
+	// 不同prefix的实例互不影响
+	foo := NewPathBuilder("/foo")
+	bar := NewPathBuilder("/bar")
+	if foo.ServicePath("s") != "/foo/app/s" {
+		t.Errorf("path error")
+	}
+	if bar.ServicePath("s") != "/bar/app/s" {
+		t.Errorf("path error")
+	}
 }
@@ -10,7 +10,16 @@ import (
 	"go.uber.org/zap"
 )
 
+// ResyncFunc watch丢失事件（例如compaction）后，提供key下的全量数据，调用方用快照修正内存中的状态
+type ResyncFunc func(ctx context.Context, resp *clientv3.GetResponse) error
+
 func WatchLoop(ctx context.Context, client EtcdWrapper, key string, rev int64, fn func(ctx context.Context, ev *clientv3.Event) error) {
+	WatchLoopWithResync(ctx, client, key, rev, fn, nil)
+}
+
+// WatchLoopWithResync 在 WatchLoop 的基础上，遇到compaction，startRev之后的事件已经无法获取，
+// 通过Get获取全量数据交给resync，然后从Get的revision继续watch
+func WatchLoopWithResync(ctx context.Context, client EtcdWrapper, key string, rev int64, fn func(ctx context.Context, ev *clientv3.Event) error, resync ResyncFunc) {
 	var (
 		startRev int64
 		opts     []clientv3.OpOption
@@ -25,7 +34,7 @@ loop:
 		zap.Int64("startRev", startRev),
 	)
 
-	opts = append(opts, clientv3.WithPrefix())
+	opts = []clientv3.OpOption{clientv3.WithPrefix()}
 	// 允许不关注rev的watch
 	if startRev >= 0 {
 		opts = append(opts, clientv3.WithRev(startRev))
@@ -36,9 +45,12 @@ loop:
 	}
 	wch = client.Watch(ctx, key, opts...)
 	for {
-		var wr clientv3.WatchResponse
+		var (
+			wr clientv3.WatchResponse
+			ok bool
+		)
 		select {
-		case wr = <-wch:
+		case wr, ok = <-wch:
 		case <-ctx.Done():
 			logutil.Info(
 				"WatchLoop exit",
@@ -47,6 +59,17 @@ loop:
 			)
 			return
 		}
+		// watch chan被关闭（例如和etcd的连接断开），从startRev重新watch，
+		// 直接使用零值的response会导致startRev被重置
+		if !ok {
+			logutil.Warn(
+				"WatchLoop chan closed",
+				zap.String("key", key),
+				zap.Int64("startRev", startRev),
+			)
+			time.Sleep(300 * time.Millisecond)
+			goto loop
+		}
 		if err := wr.Err(); err != nil {
 			logutil.Error(
 				"WatchLoop error",
@@ -57,7 +80,7 @@ loop:
 			)
 			// https://github.com/etcd-io/etcd/issues/8668
 			if err == rpctypes.ErrCompacted {
-				// 需要重新当前key的最新revision，修正startRev
+				// 需要重新当前key的最新revision，修正startRev，startRev之后的事件已经丢失，需要全量修正
 				resp, err := client.Get(context.Background(), key, clientv3.WithPrefix())
 				if err != nil {
 					logutil.Error(
@@ -67,6 +90,16 @@ loop:
 						zap.Error(err),
 					)
 				} else {
+					if resync != nil {
+						if err := resync(ctx, resp); err != nil {
+							logutil.Error(
+								"WatchLoop error when call resync",
+								zap.String("key", key),
+								zap.Int64("startRev", startRev),
+								zap.Error(err),
+							)
+						}
+					}
 					logutil.Info(
 						"WatchLoop correct startRev",
 						zap.String("key", key),
@@ -91,7 +124,9 @@ loop:
 			}
 		}
 
-		// 发生错误时，从上次的rev开始watch
-		startRev = wr.Header.GetRevision() + 1
+		// 发生错误时，从上次的rev开始watch，progress notify等没有header的response不修改startRev
+		if rev := wr.Header.GetRevision(); rev > 0 {
+			startRev = rev + 1
+		}
 	}
 }
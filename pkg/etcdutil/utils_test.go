@@ -72,3 +72,86 @@ func Test_WatchLoop_close(t *testing.T) {
 	wg.Wait()
 	fmt.Println("TestWatchLoop exit")
 }
+
+func Test_WatchLoopWithResync(t *testing.T) {
+	client, err := NewEtcdClient([]string{"127.0.0.1:2379"})
+	if err != nil {
+		t.Errorf("err: %v", err)
+		t.SkipNow()
+	}
+
+	pfx := fmt.Sprintf("/resync/%d/", time.Now().UnixNano())
+	if _, err := client.Put(context.TODO(), pfx+"a", "1"); err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+	resp, err := client.Put(context.TODO(), pfx+"b", "1")
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+	startRev := resp.Header.Revision + 1
+
+	// startRev之后的事件被compaction掉
+	if _, err := client.Delete(context.TODO(), pfx+"a"); err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+	presp, err := client.Put(context.TODO(), pfx+"c", "1")
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+	if _, err := client.Compact(context.TODO(), presp.Header.Revision); err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	snapshot := make(chan []string, 1)
+	events := make(chan string, 1)
+	go WatchLoopWithResync(
+		ctx,
+		client,
+		pfx,
+		startRev,
+		func(ctx context.Context, ev *clientv3.Event) error {
+			events <- string(ev.Kv.Key)
+			return nil
+		},
+		func(ctx context.Context, resp *clientv3.GetResponse) error {
+			var keys []string
+			for _, kv := range resp.Kvs {
+				keys = append(keys, string(kv.Key))
+			}
+			snapshot <- keys
+			return nil
+		},
+	)
+
+	select {
+	case keys := <-snapshot:
+		if len(keys) != 2 || keys[0] != pfx+"b" || keys[1] != pfx+"c" {
+			t.Errorf("unexpected snapshot %v", keys)
+			t.SkipNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("resync timeout")
+		t.SkipNow()
+	}
+
+	// resync之后继续watch新的事件
+	if _, err := client.Put(context.TODO(), pfx+"d", "1"); err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+	select {
+	case key := <-events:
+		if key != pfx+"d" {
+			t.Errorf("unexpected event %s", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("watch timeout")
+	}
+}
@@ -16,6 +16,7 @@ package etcdutil
 
 import (
 	"context"
+	"crypto/tls"
 	"io"
 	"path/filepath"
 	"strconv"
@@ -23,6 +24,8 @@ import (
 
 	"github.com/entertainment-venue/sm/pkg/logutil"
 	"github.com/pkg/errors"
+	"go.etcd.io/etcd/client/pkg/v3/tlsutil"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/concurrency"
 	"go.uber.org/zap"
@@ -35,6 +38,9 @@ var (
 
 var (
 	DefaultRequestTimeout = 1 * time.Second
+
+	// DefaultMaxTxnOps 和etcd的--max-txn-ops默认值保持一致，单个txn中compare和op的数量都不能超过该值
+	DefaultMaxTxnOps = 128
 )
 
 var (
@@ -59,6 +65,7 @@ type EtcdWrapper interface {
 	CreateAndGet(ctx context.Context, nodes []string, values []string, leaseID clientv3.LeaseID) error
 	CompareAndSwap(_ context.Context, node string, curValue string, newValue string, leaseID clientv3.LeaseID) (string, error)
 	Inc(_ context.Context, pfx string) (string, error)
+	CommitTxn(ctx context.Context, cmps []clientv3.Cmp, ops []clientv3.Op) (*clientv3.TxnResponse, error)
 	NewSession(ctx context.Context, client *clientv3.Client, opts ...concurrency.SessionOption) (*concurrency.Session, error)
 
 	Ctx() context.Context
@@ -72,11 +79,77 @@ type EtcdClient struct {
 	*clientv3.Client
 }
 
-func NewEtcdClient(endpoints []string) (*EtcdClient, error) {
+type etcdClientOptions struct {
+	// username和password 开启etcd RBAC时使用
+	username string
+	password string
+
+	// tlsConfig etcd开启https时使用，包含客户端证书时支持etcd的client cert认证
+	tlsConfig *tls.Config
+}
+
+type EtcdClientOption func(options *etcdClientOptions)
+
+func WithAuth(username, password string) EtcdClientOption {
+	return func(options *etcdClientOptions) {
+		options.username = username
+		options.password = password
+	}
+}
+
+func WithTLSConfig(v *tls.Config) EtcdClientOption {
+	return func(options *etcdClientOptions) {
+		options.tlsConfig = v
+	}
+}
+
+// NewTLSConfig 从文件加载etcd的tls配置，caFile为空时使用系统CA，certFile和keyFile需要同时配置
+func NewTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("certFile and keyFile must be set together")
+	}
+	info := transport.TLSInfo{
+		TrustedCAFile: caFile,
+		CertFile:      certFile,
+		KeyFile:       keyFile,
+	}
+	if certFile == "" {
+		// 没有客户端证书时，只校验server证书
+		tc := tls.Config{MinVersion: tls.VersionTLS12}
+		if caFile != "" {
+			pool, err := tlsutil.NewCertPool([]string{caFile})
+			if err != nil {
+				return nil, errors.Wrap(err, "")
+			}
+			tc.RootCAs = pool
+		}
+		return &tc, nil
+	}
+	tc, err := info.ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return tc, nil
+}
+
+func NewEtcdClient(endpoints []string, opts ...EtcdClientOption) (*EtcdClient, error) {
 	if len(endpoints) < 1 {
 		return nil, errors.New("You must provide at least one etcd address")
 	}
-	client, err := clientv3.New(clientv3.Config{Endpoints: endpoints, DialTimeout: 3 * time.Second, DialOptions: []grpc.DialOption{grpc.WithBlock()}})
+	ops := etcdClientOptions{}
+	for _, opt := range opts {
+		opt(&ops)
+	}
+	client, err := clientv3.New(
+		clientv3.Config{
+			Endpoints:   endpoints,
+			DialTimeout: 3 * time.Second,
+			DialOptions: []grpc.DialOption{grpc.WithBlock()},
+			Username:    ops.username,
+			Password:    ops.password,
+			TLS:         ops.tlsConfig,
+		},
+	)
 	if err != nil {
 		return nil, errors.Wrap(err, "")
 	}
@@ -319,6 +392,21 @@ func (w *EtcdClient) CompareAndSwap(ctx context.Context, node string, curValue s
 	return realValue, ErrEtcdValueNotMatch
 }
 
+// CommitTxn cmps全部满足时执行ops，调用方需要保证数量不超过 DefaultMaxTxnOps
+func (w *EtcdClient) CommitTxn(ctx context.Context, cmps []clientv3.Cmp, ops []clientv3.Op) (*clientv3.TxnResponse, error) {
+	var cancelFunc context.CancelFunc
+	if ctx == context.TODO() || ctx == context.Background() {
+		ctx, cancelFunc = context.WithTimeout(ctx, DefaultRequestTimeout)
+		defer cancelFunc()
+	}
+
+	resp, err := w.Txn(ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return resp, nil
+}
+
 func (w *EtcdClient) Inc(ctx context.Context, pfx string) (string, error) {
 	if pfx == "" {
 		return "", nil
@@ -62,6 +62,11 @@ func (m *MockedEtcdWrapper) Inc(ctx context.Context, pfx string) (string, error)
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockedEtcdWrapper) CommitTxn(ctx context.Context, cmps []clientv3.Cmp, ops []clientv3.Op) (*clientv3.TxnResponse, error) {
+	args := m.Called(ctx, cmps, ops)
+	return args.Get(0).(*clientv3.TxnResponse), args.Error(1)
+}
+
 func (m *MockedEtcdWrapper) NewSession(ctx context.Context, client *clientv3.Client, opts ...concurrency.SessionOption) (*concurrency.Session, error) {
 	panic("implement me")
 }
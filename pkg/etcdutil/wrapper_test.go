@@ -1,7 +1,24 @@
 package etcdutil
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestEtcdClient_Close(t *testing.T) {
 
 }
+
+func TestNewTLSConfig(t *testing.T) {
+	_, err := NewTLSConfig("", "client.pem", "")
+	assert.Error(t, err)
+
+	_, err = NewTLSConfig("not-exist-ca.pem", "", "")
+	assert.Error(t, err)
+
+	tc, err := NewTLSConfig("", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, tc.RootCAs)
+	assert.Empty(t, tc.Certificates)
+}
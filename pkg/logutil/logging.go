@@ -3,6 +3,8 @@ package logutil
 import (
 	"fmt"
 	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -154,29 +156,50 @@ func WithEncodingConsole(v bool) logOptionsFunc {
 // Sync implements zap.Sink
 func (logRotationConfig) Sync() error { return nil }
 
-func NewLogger(opt ...logOptionsFunc) (*zap.Logger, error) {
-	opts := defaultLogOptions
-	for _, o := range opt {
-		o(&opts)
-	}
+var (
+	registerOnce sync.Once
+	registerErr  error
+)
 
-	cfg := logRotationConfig{
+func newRotateSink(u *url.URL) (zap.Sink, error) {
+	q := u.Query()
+	maxSize, _ := strconv.Atoi(q.Get("maxSize"))
+	maxBackups, _ := strconv.Atoi(q.Get("maxBackups"))
+	maxAge, _ := strconv.Atoi(q.Get("maxAge"))
+	return &logRotationConfig{
 		&lumberjack.Logger{
+			Filename: u.Path[1:],
+
 			// 每个文件1g
-			MaxSize: opts.MaxSize,
+			MaxSize: maxSize,
 
 			// 最多50个文件
-			MaxBackups: opts.MaxBackups,
+			MaxBackups: maxBackups,
 
 			// 最多保留3天
-			MaxAge: opts.MaxAge,
+			MaxAge: maxAge,
 		},
+	}, nil
+}
+
+// SetLogger 替换包内使用的logger，app可以把sm的日志输出到自己的路径
+func SetLogger(l *zap.Logger) {
+	lg = l
+	sugerLg = l.Sugar()
+}
+
+func NewLogger(opt ...logOptionsFunc) (*zap.Logger, error) {
+	opts := defaultLogOptions
+	for _, o := range opt {
+		o(&opts)
 	}
-	if err := zap.RegisterSink("rotate", func(u *url.URL) (zap.Sink, error) {
-		cfg.Filename = u.Path[1:]
-		return &cfg, nil
-	}); err != nil {
-		return nil, errors.Wrap(err, "")
+
+	// sink只能注册一次，init中已经创建过logger，轮转参数通过url的query传递给sink
+	registerOnce.Do(func() {
+		registerErr = zap.RegisterSink("rotate", newRotateSink)
+	})
+	if registerErr != nil {
+		return nil, errors.Wrap(registerErr, "")
 	}
 
 	zap.AddCallerSkip(1)
@@ -189,7 +212,9 @@ func NewLogger(opt ...logOptionsFunc) (*zap.Logger, error) {
 		}
 		zapCfg.EncoderConfig.EncodeTime = func(t time.Time, encoder zapcore.PrimitiveArrayEncoder) {}
 	}
-	zapCfg.OutputPaths = []string{fmt.Sprintf("rotate://%s", opts.Path)}
+	zapCfg.OutputPaths = []string{
+		fmt.Sprintf("rotate://%s?maxSize=%d&maxBackups=%d&maxAge=%d", opts.Path, opts.MaxSize, opts.MaxBackups, opts.MaxAge),
+	}
 	if opts.Stdout {
 		zapCfg.OutputPaths = append(zapCfg.OutputPaths, "stdout")
 	}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/entertainment-venue/sm/server/smctl"
+)
+
+// main smctl命令行入口，go install github.com/entertainment-venue/sm/server/cmd/smctl
+func main() {
+	smctl.Main()
+}
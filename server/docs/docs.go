@@ -23,6 +23,83 @@ var doc = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/sm/server/acl/del-principal": {
+            "get": {
+                "description": "del acl principal",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "acl"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "param",
+                        "name": "name",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/sm/server/acl/get-principal": {
+            "get": {
+                "description": "get all acl principals",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "acl"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/smserver.aclPrincipal"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/sm/server/acl/set-principal": {
+            "post": {
+                "description": "add or replace acl principal",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "acl"
+                ],
+                "parameters": [
+                    {
+                        "description": "param",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/smserver.aclPrincipalRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
         "/sm/server/add-shard": {
             "post": {
                 "description": "add shard",
@@ -113,6 +190,151 @@ var doc = `{
                 }
             }
         },
+        "/sm/server/apply": {
+            "post": {
+                "description": "apply service manifest, only changes are committed, shards and workers not in manifest are deleted when prune is set",
+                "consumes": [
+                    "application/json",
+                    "application/x-yaml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "spec"
+                ],
+                "parameters": [
+                    {
+                        "description": "param",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/smserver.serviceManifest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "only output diff",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "delete shards and workers not in manifest",
+                        "name": "prune",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/smserver.manifestDiff"
+                        }
+                    }
+                }
+            }
+        },
+        "/sm/server/batch-add-shard": {
+            "post": {
+                "description": "batch add shard",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "shard"
+                ],
+                "parameters": [
+                    {
+                        "description": "param",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/smserver.batchShardRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/smserver.batchShardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/sm/server/batch-del-shard": {
+            "post": {
+                "description": "batch del shard",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "shard"
+                ],
+                "parameters": [
+                    {
+                        "description": "param",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/smserver.batchDelShardRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/smserver.batchShardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/sm/server/batch-update-shard": {
+            "post": {
+                "description": "batch update shard",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "shard"
+                ],
+                "parameters": [
+                    {
+                        "description": "param",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/smserver.batchShardRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/smserver.batchShardResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/sm/server/del-shard": {
             "post": {
                 "description": "del shard",
@@ -229,6 +451,36 @@ var doc = `{
                 }
             }
         },
+        "/sm/server/drain-container": {
+            "post": {
+                "description": "drain container, shards on the container will be moved to others",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "worker"
+                ],
+                "parameters": [
+                    {
+                        "description": "param",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/smserver.drainRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
         "/sm/server/get-shard": {
             "get": {
                 "description": "get service all shard",
@@ -303,9 +555,209 @@ var doc = `{
                     }
                 }
             }
+        },
+        "/sm/server/leader": {
+            "get": {
+                "description": "get sm leader, clients use it to send leader only requests",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "server"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/smserver.leaderEtcdValue"
+                        }
+                    }
+                }
+            }
+        },
+        "/sm/server/resign": {
+            "post": {
+                "description": "leader resign",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "server"
+                ],
+                "parameters": [
+                    {
+                        "description": "param",
+                        "name": "param",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/smserver.resignRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/sm/server/undrain-container": {
+            "post": {
+                "description": "undrain container, the container can be assigned shards again",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "worker"
+                ],
+                "parameters": [
+                    {
+                        "description": "param",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/smserver.drainRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/sm/server/update-shard": {
+            "post": {
+                "description": "update shard, shard which only task changed will be updated in place",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "shard"
+                ],
+                "parameters": [
+                    {
+                        "description": "param",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/smserver.updateShardRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/sm/server/update-spec": {
+            "post": {
+                "description": "update spec, running leader shard applies the change without restart",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "spec"
+                ],
+                "parameters": [
+                    {
+                        "description": "param",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/smserver.smAppSpec"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
         }
     },
     "definitions": {
+        "smserver.aclPrincipal": {
+            "type": "object",
+            "properties": {
+                "identities": {
+                    "description": "Identities 客户端证书的CommonName",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "roles": {
+                    "description": "Roles service到角色的映射，key为 aclAnyService 时对所有service生效",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "tokenHashes": {
+                    "description": "TokenHashes token的sha256，etcd中不保存明文",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "smserver.aclPrincipalRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "roles"
+            ],
+            "properties": {
+                "identities": {
+                    "description": "Identities mTLS客户端证书的CommonName",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "roles": {
+                    "description": "Roles service到角色（read、write、admin）的映射，\"*\"对所有service生效",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "tokens": {
+                    "description": "Tokens 明文token，只用于计算hash，不会写入etcd",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "smserver.addShardRequest": {
             "type": "object",
             "required": [
@@ -337,6 +789,92 @@ var doc = `{
                 }
             }
         },
+        "smserver.batchDelShardRequest": {
+            "type": "object",
+            "required": [
+                "service",
+                "shardIds"
+            ],
+            "properties": {
+                "service": {
+                    "type": "string"
+                },
+                "shardIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "smserver.batchShardItem": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "type": "string"
+                },
+                "manualContainerId": {
+                    "type": "string"
+                },
+                "shardId": {
+                    "type": "string"
+                },
+                "task": {
+                    "description": "业务app自己定义task内容",
+                    "type": "string"
+                },
+                "workerGroup": {
+                    "type": "string"
+                }
+            }
+        },
+        "smserver.batchShardRequest": {
+            "type": "object",
+            "required": [
+                "service",
+                "shards"
+            ],
+            "properties": {
+                "service": {
+                    "type": "string"
+                },
+                "shards": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/smserver.batchShardItem"
+                    }
+                }
+            }
+        },
+        "smserver.batchShardResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/smserver.batchShardResult"
+                    }
+                },
+                "succeeded": {
+                    "type": "integer"
+                }
+            }
+        },
+        "smserver.batchShardResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error 为空表示成功",
+                    "type": "string"
+                },
+                "shardId": {
+                    "type": "string"
+                }
+            }
+        },
         "smserver.delShardRequest": {
             "type": "object",
             "required": [
@@ -352,6 +890,118 @@ var doc = `{
                 }
             }
         },
+        "smserver.drainRequest": {
+            "type": "object",
+            "required": [
+                "container",
+                "service"
+            ],
+            "properties": {
+                "container": {
+                    "description": "Container 需要摘除的container，摘除后不再分配shard，已有的shard会被迁移到其他container",
+                    "type": "string"
+                },
+                "service": {
+                    "type": "string"
+                }
+            }
+        },
+        "smserver.leaderEtcdValue": {
+            "type": "object",
+            "properties": {
+                "containerId": {
+                    "type": "string"
+                },
+                "createTime": {
+                    "type": "integer"
+                }
+            }
+        },
+        "smserver.manifestChange": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Error 为空表示成功，dryRun时不设置",
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "smserver.manifestDiff": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/smserver.manifestChange"
+                    }
+                },
+                "dryRun": {
+                    "type": "boolean"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "prune": {
+                    "type": "boolean"
+                },
+                "succeeded": {
+                    "type": "integer"
+                },
+                "unchanged": {
+                    "description": "Unchanged 和etcd中一致的shard和worker数量",
+                    "type": "integer"
+                }
+            }
+        },
+        "smserver.resignRequest": {
+            "type": "object",
+            "properties": {
+                "successor": {
+                    "description": "Successor 提名的继任container，需要在竞选队列中，为空时由election的顺序决定",
+                    "type": "string"
+                },
+                "timeout": {
+                    "description": "Timeout 等待进行中rb结束的时间，单位s，超时后中断rb",
+                    "type": "integer"
+                }
+            }
+        },
+        "smserver.serviceManifest": {
+            "type": "object",
+            "required": [
+                "spec"
+            ],
+            "properties": {
+                "shards": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/smserver.batchShardItem"
+                    }
+                },
+                "spec": {
+                    "$ref": "#/definitions/smserver.smAppSpec"
+                },
+                "workers": {
+                    "description": "Workers workerGroup和worker列表的映射",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "smserver.smAppSpec": {
             "type": "object",
             "properties": {
@@ -366,12 +1016,47 @@ var doc = `{
                     "description": "MaxShardCount 单container承载的最大分片数量，防止雪崩",
                     "type": "integer"
                 },
+                "receiver": {
+                    "description": "Receiver leader向container下发指令使用的协议，http（默认）或者grpc，拉模式的container不受影响",
+                    "type": "string"
+                },
                 "service": {
                     "description": "Service 目前app的spec更多承担的是管理职能，shard配置的一个起点，先只配置上service，可以唯一标记一个app",
                     "type": "string"
                 }
             }
         },
+        "smserver.updateShardRequest": {
+            "type": "object",
+            "required": [
+                "service",
+                "shardId"
+            ],
+            "properties": {
+                "group": {
+                    "type": "string"
+                },
+                "manualContainerId": {
+                    "type": "string"
+                },
+                "revision": {
+                    "description": "Revision 读取shard时的ModRevision，不为0时要求shard在此期间没有被修改",
+                    "type": "integer"
+                },
+                "service": {
+                    "type": "string"
+                },
+                "shardId": {
+                    "type": "string"
+                },
+                "task": {
+                    "type": "string"
+                },
+                "workerGroup": {
+                    "type": "string"
+                }
+            }
+        },
         "smserver.workerRequest": {
             "type": "object",
             "required": [
@@ -0,0 +1,445 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package smapi sm管理接口（/sm/server/*）的go客户端
+package smapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRetry         = 2
+	defaultRetryInterval = 300 * time.Millisecond
+	defaultTimeout       = 10 * time.Second
+)
+
+// errNotLeader 和smserver中leader相关接口的错误信息保持一致
+const errNotLeader = "not leader"
+
+// APIError smserver返回的非200响应
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Message    string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("smapi: status %d: %s", e.StatusCode, e.Message)
+}
+
+// IsConflict 带revision的更新遇到并发修改，需要重新读取后再提交
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+type clientOptions struct {
+	httpClient *http.Client
+	tlsConfig  *tls.Config
+
+	// token 开启acl时使用的bearer token
+	token string
+
+	// retry 连接失败或者5xx时换下一个endpoint重试的次数，非GET请求只在连接失败时重试
+	retry         int
+	retryInterval time.Duration
+}
+
+type ClientOption func(options *clientOptions)
+
+func WithHTTPClient(v *http.Client) ClientOption {
+	return func(options *clientOptions) {
+		options.httpClient = v
+	}
+}
+
+// WithTLSConfig 没有指定scheme的endpoint使用https
+func WithTLSConfig(v *tls.Config) ClientOption {
+	return func(options *clientOptions) {
+		options.tlsConfig = v
+	}
+}
+
+func WithToken(v string) ClientOption {
+	return func(options *clientOptions) {
+		options.token = v
+	}
+}
+
+func WithRetry(v int) ClientOption {
+	return func(options *clientOptions) {
+		options.retry = v
+	}
+}
+
+func WithRetryInterval(v time.Duration) ClientOption {
+	return func(options *clientOptions) {
+		options.retryInterval = v
+	}
+}
+
+// Client 可以访问sm集群中的任意节点，leader相关的请求通过 Leader 找到leader后发送
+type Client struct {
+	opts *clientOptions
+
+	// endpoints 带scheme的sm节点地址
+	endpoints []string
+	scheme    string
+
+	mu sync.Mutex
+	// next 最近一次成功的endpoint，后续请求优先使用
+	next int
+}
+
+// NewClient endpoints是sm节点的管理地址，例如127.0.0.1:8888，也可以带上scheme
+func NewClient(endpoints []string, opts ...ClientOption) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("endpoints empty")
+	}
+	ops := clientOptions{retry: defaultRetry, retryInterval: defaultRetryInterval}
+	for _, opt := range opts {
+		opt(&ops)
+	}
+
+	c := Client{opts: &ops, scheme: "http"}
+	if ops.tlsConfig != nil {
+		c.scheme = "https"
+	}
+	if ops.httpClient == nil {
+		ops.httpClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: ops.tlsConfig},
+			Timeout:   defaultTimeout,
+		}
+	}
+	for _, endpoint := range endpoints {
+		c.endpoints = append(c.endpoints, c.withScheme(strings.TrimSuffix(endpoint, "/")))
+	}
+	return &c, nil
+}
+
+func (c *Client) withScheme(endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		return endpoint
+	}
+	return c.scheme + "://" + endpoint
+}
+
+func (c *Client) AddSpec(ctx context.Context, spec *AppSpec) error {
+	return c.do(ctx, http.MethodPost, "/sm/server/add-spec", nil, spec, nil)
+}
+
+func (c *Client) UpdateSpec(ctx context.Context, spec *AppSpec) error {
+	return c.do(ctx, http.MethodPost, "/sm/server/update-spec", nil, spec, nil)
+}
+
+func (c *Client) DelSpec(ctx context.Context, service string) error {
+	return c.do(ctx, http.MethodGet, "/sm/server/del-spec", url.Values{"service": {service}}, nil, nil)
+}
+
+// GetSpec 返回所有接入的service
+func (c *Client) GetSpec(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Services []string `json:"services"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/sm/server/get-spec", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Services, nil
+}
+
+func (c *Client) AddShard(ctx context.Context, req *AddShardRequest) error {
+	return c.do(ctx, http.MethodPost, "/sm/server/add-shard", nil, req, nil)
+}
+
+// UpdateShard 返回更新后etcd的revision，req.Revision不匹配时返回的错误满足 IsConflict
+func (c *Client) UpdateShard(ctx context.Context, req *UpdateShardRequest) (int64, error) {
+	var resp struct {
+		Revision int64 `json:"revision"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/sm/server/update-shard", nil, req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Revision, nil
+}
+
+func (c *Client) DelShard(ctx context.Context, req *DelShardRequest) error {
+	return c.do(ctx, http.MethodPost, "/sm/server/del-shard", nil, req, nil)
+}
+
+// GetShard 返回service下所有shard的id
+func (c *Client) GetShard(ctx context.Context, service string) ([]string, error) {
+	var resp struct {
+		Shards []string `json:"shards"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/sm/server/get-shard", url.Values{"service": {service}}, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Shards, nil
+}
+
+func (c *Client) BatchAddShard(ctx context.Context, req *BatchShardRequest) (*BatchShardResponse, error) {
+	var resp BatchShardResponse
+	if err := c.do(ctx, http.MethodPost, "/sm/server/batch-add-shard", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) BatchUpdateShard(ctx context.Context, req *BatchShardRequest) (*BatchShardResponse, error) {
+	var resp BatchShardResponse
+	if err := c.do(ctx, http.MethodPost, "/sm/server/batch-update-shard", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) BatchDelShard(ctx context.Context, req *BatchDelShardRequest) (*BatchShardResponse, error) {
+	var resp BatchShardResponse
+	if err := c.do(ctx, http.MethodPost, "/sm/server/batch-del-shard", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) Apply(ctx context.Context, manifest *ServiceManifest, opts ApplyOptions) (*ManifestDiff, error) {
+	query := url.Values{
+		"dryRun": {strconv.FormatBool(opts.DryRun)},
+		"prune":  {strconv.FormatBool(opts.Prune)},
+	}
+	var resp ManifestDiff
+	if err := c.do(ctx, http.MethodPost, "/sm/server/apply", query, manifest, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) AddWorker(ctx context.Context, req *WorkerRequest) error {
+	return c.do(ctx, http.MethodPost, "/sm/server/add-worker", nil, req, nil)
+}
+
+func (c *Client) DelWorker(ctx context.Context, req *WorkerRequest) error {
+	return c.do(ctx, http.MethodPost, "/sm/server/del-worker", nil, req, nil)
+}
+
+// GetWorker 返回workerGroup和worker列表的映射
+func (c *Client) GetWorker(ctx context.Context, service string) (map[string][]string, error) {
+	var resp struct {
+		Workers map[string][]string `json:"workers"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/sm/server/get-worker", url.Values{"service": {service}}, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Workers, nil
+}
+
+func (c *Client) DrainContainer(ctx context.Context, req *DrainRequest) error {
+	return c.do(ctx, http.MethodPost, "/sm/server/drain-container", nil, req, nil)
+}
+
+func (c *Client) UndrainContainer(ctx context.Context, req *DrainRequest) error {
+	return c.do(ctx, http.MethodPost, "/sm/server/undrain-container", nil, req, nil)
+}
+
+func (c *Client) Detail(ctx context.Context, service string) (*ServiceDetail, error) {
+	var resp ServiceDetail
+	if err := c.do(ctx, http.MethodGet, "/sm/server/detail", url.Values{"service": {service}}, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/sm/server/health", nil, nil, nil)
+}
+
+func (c *Client) Leader(ctx context.Context) (*Leader, error) {
+	var resp Leader
+	if err := c.do(ctx, http.MethodGet, "/sm/server/leader", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Resign 发送给当前leader，leader在请求过程中切换时重新查找leader
+func (c *Client) Resign(ctx context.Context, req *ResignRequest) error {
+	return c.doLeader(ctx, http.MethodPost, "/sm/server/resign", req, nil)
+}
+
+func (c *Client) SetPrincipal(ctx context.Context, req *PrincipalRequest) error {
+	return c.do(ctx, http.MethodPost, "/sm/server/acl/set-principal", nil, req, nil)
+}
+
+func (c *Client) DelPrincipal(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodGet, "/sm/server/acl/del-principal", url.Values{"name": {name}}, nil, nil)
+}
+
+func (c *Client) GetPrincipal(ctx context.Context) ([]*Principal, error) {
+	var resp []*Principal
+	if err := c.do(ctx, http.MethodGet, "/sm/server/acl/get-principal", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// do 从最近一次成功的endpoint开始，连接失败或者5xx时换下一个endpoint，
+// 非GET请求不是幂等的，只有请求没有发出（建立连接失败）时才重试，见 retryable
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}, out interface{}) error {
+	c.mu.Lock()
+	start := c.next
+	c.mu.Unlock()
+
+	var lastErr error
+	for i := 0; i <= c.opts.retry; i++ {
+		if i > 0 {
+			if err := c.sleep(ctx); err != nil {
+				return err
+			}
+		}
+		idx := (start + i) % len(c.endpoints)
+		err := c.send(ctx, c.endpoints[idx], method, path, query, body, out)
+		if err == nil {
+			c.mu.Lock()
+			c.next = idx
+			c.mu.Unlock()
+			return nil
+		}
+		if !retryable(ctx, method, err) {
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// doLeader leader的地址不在endpoints中也可以访问，和endpoints使用相同的scheme
+func (c *Client) doLeader(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var lastErr error
+	for i := 0; i <= c.opts.retry; i++ {
+		if i > 0 {
+			if err := c.sleep(ctx); err != nil {
+				return err
+			}
+		}
+		leader, err := c.Leader(ctx)
+		if err != nil {
+			return err
+		}
+		err = c.send(ctx, c.withScheme(leader.ContainerId), method, path, nil, body, out)
+		if err == nil {
+			return nil
+		}
+		// 查询到leader之后，leader发生了切换，非leader不会执行请求，可以重试
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Message == errNotLeader {
+			lastErr = err
+			continue
+		}
+		if !retryable(ctx, method, err) {
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+func (c *Client) sleep(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(c.opts.retryInterval):
+		return nil
+	}
+}
+
+func (c *Client) send(ctx context.Context, endpoint, method, path string, query url.Values, body interface{}, out interface{}) error {
+	urlStr := endpoint + path
+	if len(query) > 0 {
+		urlStr += "?" + query.Encode()
+	}
+
+	var reader *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		reader = bytes.NewReader(b)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, reader)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.opts.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.token)
+	}
+
+	resp, err := c.opts.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	defer resp.Body.Close()
+	rb, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := APIError{StatusCode: resp.StatusCode}
+		if err := json.Unmarshal(rb, &apiErr); err != nil || apiErr.Message == "" {
+			apiErr.Message = string(rb)
+		}
+		return &apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(rb, out), "")
+}
+
+// retryable 4xx是请求本身的问题，换endpoint也不会成功。
+// 非GET请求在5xx或者请求发出后的连接错误时，server可能已经提交，重试会导致重复执行，
+// 只有dial失败时请求一定没有到达server
+func retryable(ctx context.Context, method string, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if method != http.MethodGet {
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package smapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/entertainment-venue/sm/server/smserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestClient(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
+
+type ClientTestSuite struct {
+	suite.Suite
+
+	addr   string
+	server *smserver.Server
+	client *Client
+}
+
+// SetupSuite 启动进程内的smserver，依赖本地127.0.0.1:2379的etcd
+func (suite *ClientTestSuite) SetupSuite() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.addr = l.Addr().String()
+	l.Close()
+
+	suite.server, err = smserver.NewServer(
+		smserver.WithId(suite.addr),
+		smserver.WithService("smapi.test"),
+		smserver.WithAddr(suite.addr),
+		smserver.WithEndpoints([]string{"127.0.0.1:2379"}),
+		// 每次使用新的prefix，不受上次运行残留数据的影响
+		smserver.WithEtcdPrefix(fmt.Sprintf("/smapi-test-%d", time.Now().UnixNano())),
+	)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.client, _ = NewClient([]string{suite.addr}, WithRetryInterval(100*time.Millisecond))
+	for i := 0; i < 100; i++ {
+		if _, err = suite.client.Leader(context.TODO()); err == nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	suite.T().Fatal(err)
+}
+
+func (suite *ClientTestSuite) TearDownSuite() {
+	if suite.server != nil {
+		suite.server.Close()
+	}
+}
+
+func (suite *ClientTestSuite) TestLeader() {
+	leader, err := suite.client.Leader(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.addr, leader.ContainerId)
+}
+
+func (suite *ClientTestSuite) TestSpecAndShard() {
+	ctx := context.TODO()
+	service := "foo.spec"
+
+	assert.NoError(suite.T(), suite.client.AddSpec(ctx, &AppSpec{Service: service}))
+	services, err := suite.client.GetSpec(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), services, service)
+
+	assert.NoError(suite.T(), suite.client.UpdateSpec(ctx, &AppSpec{Service: service, MaxShardCount: 10}))
+	detail, err := suite.client.Detail(ctx, service)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 10, detail.Spec.MaxShardCount)
+
+	assert.NoError(suite.T(), suite.client.AddShard(ctx, &AddShardRequest{ShardId: "s1", Service: service, Task: "t1"}))
+	_, err = suite.client.UpdateShard(ctx, &UpdateShardRequest{ShardId: "s1", Service: service, Task: "t2", Revision: 1})
+	assert.True(suite.T(), IsConflict(err))
+	rev, err := suite.client.UpdateShard(ctx, &UpdateShardRequest{ShardId: "s1", Service: service, Task: "t2"})
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), rev > 0)
+
+	bresp, err := suite.client.BatchAddShard(ctx, &BatchShardRequest{
+		Service: service,
+		Shards:  []*BatchShardItem{{ShardId: "s1"}, {ShardId: "s2"}},
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, bresp.Succeeded)
+	assert.Equal(suite.T(), 1, bresp.Failed)
+
+	shards, err := suite.client.GetShard(ctx, service)
+	assert.NoError(suite.T(), err)
+	assert.ElementsMatch(suite.T(), []string{"s1", "s2"}, shards)
+
+	assert.NoError(suite.T(), suite.client.DelShard(ctx, &DelShardRequest{ShardId: "s1", Service: service}))
+	bresp, err = suite.client.BatchDelShard(ctx, &BatchDelShardRequest{Service: service, ShardIds: []string{"s2"}})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, bresp.Succeeded)
+
+	assert.NoError(suite.T(), suite.client.DelSpec(ctx, service))
+}
+
+func (suite *ClientTestSuite) TestWorker() {
+	ctx := context.TODO()
+	service := "foo.worker"
+	assert.NoError(suite.T(), suite.client.AddSpec(ctx, &AppSpec{Service: service}))
+
+	req := WorkerRequest{WorkerGroup: "g1", Service: service, Worker: "127.0.0.1:8801"}
+	assert.NoError(suite.T(), suite.client.AddWorker(ctx, &req))
+	workers, err := suite.client.GetWorker(ctx, service)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), map[string][]string{"g1": {"127.0.0.1:8801"}}, workers)
+
+	assert.NoError(suite.T(), suite.client.DelWorker(ctx, &req))
+	workers, err = suite.client.GetWorker(ctx, service)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), workers)
+
+	dreq := DrainRequest{Service: service, Container: "127.0.0.1:8801"}
+	assert.NoError(suite.T(), suite.client.DrainContainer(ctx, &dreq))
+	detail, err := suite.client.Detail(ctx, service)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"127.0.0.1:8801"}, detail.DrainedContainers)
+
+	assert.NoError(suite.T(), suite.client.UndrainContainer(ctx, &dreq))
+	detail, err = suite.client.Detail(ctx, service)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), detail.DrainedContainers)
+}
+
+func (suite *ClientTestSuite) TestApply_dryRun() {
+	manifest := ServiceManifest{
+		Spec:    &AppSpec{Service: "foo.apply"},
+		Shards:  []*BatchShardItem{{ShardId: "s1"}},
+		Workers: map[string][]string{"g1": {"127.0.0.1:8801"}},
+	}
+	diff, err := suite.client.Apply(context.TODO(), &manifest, ApplyOptions{DryRun: true})
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), diff.DryRun)
+	assert.Len(suite.T(), diff.Changes, 3)
+
+	// dryRun不写入etcd
+	_, err = suite.client.Detail(context.TODO(), "foo.apply")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *ClientTestSuite) TestAPIError() {
+	err := suite.client.AddShard(context.TODO(), &AddShardRequest{ShardId: "s1", Service: "not.exist"})
+	var apiErr *APIError
+	assert.ErrorAs(suite.T(), err, &apiErr)
+	assert.Equal(suite.T(), 400, apiErr.StatusCode)
+}
+
+func (suite *ClientTestSuite) TestFailover() {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := l.Addr().String()
+	l.Close()
+
+	c, _ := NewClient([]string{dead, suite.addr}, WithRetryInterval(10*time.Millisecond))
+	assert.NoError(suite.T(), c.Health(context.TODO()))
+	// 成功的endpoint后续优先使用
+	assert.Equal(suite.T(), 1, c.next)
+}
+
+func TestClient_noRetryOnPost(t *testing.T) {
+	var hits int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer svr.Close()
+
+	c, _ := NewClient([]string{svr.URL, svr.URL}, WithRetryInterval(10*time.Millisecond))
+	// server可能已经提交，POST不重试
+	err := c.AddShard(context.TODO(), &AddShardRequest{ShardId: "s1", Service: "foo"})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	// GET是幂等的，5xx时换endpoint重试
+	atomic.StoreInt32(&hits, 0)
+	assert.Error(t, c.Health(context.TODO()))
+	assert.Greater(t, atomic.LoadInt32(&hits), int32(1))
+}
+
+func TestClient_retryPostOnDial(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := l.Addr().String()
+	l.Close()
+
+	var hits int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte("{}"))
+	}))
+	defer svr.Close()
+
+	// 连接失败时请求没有发出，POST可以换endpoint
+	c, _ := NewClient([]string{dead, svr.URL}, WithRetryInterval(10*time.Millisecond))
+	assert.NoError(t, c.AddShard(context.TODO(), &AddShardRequest{ShardId: "s1", Service: "foo"}))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
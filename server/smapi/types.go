@@ -0,0 +1,219 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smapi
+
+import (
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+)
+
+// AppSpec 接入sm的service配置，对应 /sm/server/add-spec
+type AppSpec struct {
+	Service string `json:"service" yaml:"service"`
+
+	CreateTime int64 `json:"createTime" yaml:"createTime"`
+
+	// MaxShardCount 单container承载的最大分片数量，防止雪崩
+	MaxShardCount int `json:"maxShardCount" yaml:"maxShardCount"`
+
+	// MaxRecoveryTime 遇到container删除的场景，等待的时间，超时认为该container被清理
+	MaxRecoveryTime int `json:"maxRecoveryTime" yaml:"maxRecoveryTime"`
+
+	// Receiver leader向container下发指令使用的协议，http（默认）或者grpc
+	Receiver string `json:"receiver" yaml:"receiver"`
+}
+
+type AddShardRequest struct {
+	ShardId string `json:"shardId"`
+
+	// Service 为哪个业务app增加shard
+	Service string `json:"service"`
+
+	// Task 业务app自己定义task内容
+	Task string `json:"task"`
+
+	ManualContainerId string `json:"manualContainerId"`
+
+	// Group 同一个service需要区分不同种类的shard，这些shard之间不相关的balance到现有container上
+	Group string `json:"group"`
+
+	// WorkerGroup 同一个service需要区分不同种类的container，shard可以指定分配到那一组container上
+	WorkerGroup string `json:"workerGroup"`
+}
+
+type UpdateShardRequest struct {
+	ShardId string `json:"shardId"`
+
+	Service string `json:"service"`
+
+	Task string `json:"task"`
+
+	ManualContainerId string `json:"manualContainerId"`
+
+	Group string `json:"group"`
+
+	WorkerGroup string `json:"workerGroup"`
+
+	// Revision 读取shard时的ModRevision，不为0时要求shard在此期间没有被修改
+	Revision int64 `json:"revision"`
+}
+
+type DelShardRequest struct {
+	ShardId string `json:"shardId"`
+	Service string `json:"service"`
+}
+
+type BatchShardItem struct {
+	ShardId string `json:"shardId" yaml:"shardId"`
+
+	// Task 业务app自己定义task内容
+	Task string `json:"task" yaml:"task"`
+
+	ManualContainerId string `json:"manualContainerId" yaml:"manualContainerId"`
+
+	Group string `json:"group" yaml:"group"`
+
+	WorkerGroup string `json:"workerGroup" yaml:"workerGroup"`
+}
+
+type BatchShardRequest struct {
+	Service string `json:"service"`
+
+	Shards []*BatchShardItem `json:"shards"`
+}
+
+type BatchDelShardRequest struct {
+	Service string `json:"service"`
+
+	ShardIds []string `json:"shardIds"`
+}
+
+type BatchShardResult struct {
+	ShardId string `json:"shardId"`
+
+	// Error 为空表示成功
+	Error string `json:"error,omitempty"`
+}
+
+type BatchShardResponse struct {
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Results   []*BatchShardResult `json:"results"`
+}
+
+// ServiceManifest 声明式的service配置，对应 /sm/server/apply
+type ServiceManifest struct {
+	Spec *AppSpec `json:"spec" yaml:"spec"`
+
+	Shards []*BatchShardItem `json:"shards" yaml:"shards"`
+
+	// Workers workerGroup和worker列表的映射
+	Workers map[string][]string `json:"workers" yaml:"workers"`
+}
+
+type ApplyOptions struct {
+	// DryRun 只返回变化，不写入etcd
+	DryRun bool
+
+	// Prune 删除manifest中没有的shard和worker
+	Prune bool
+}
+
+type ManifestChange struct {
+	Kind   string `json:"kind"`
+	Action string `json:"action"`
+	Name   string `json:"name"`
+
+	// Error 为空表示成功，dryRun时不设置
+	Error string `json:"error,omitempty"`
+}
+
+type ManifestDiff struct {
+	DryRun bool `json:"dryRun"`
+	Prune  bool `json:"prune"`
+
+	Changes []*ManifestChange `json:"changes"`
+
+	// Unchanged 和etcd中一致的shard和worker数量
+	Unchanged int `json:"unchanged"`
+
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+type WorkerRequest struct {
+	// WorkerGroup 在哪个资源组下面添加worker
+	WorkerGroup string `json:"workerGroup"`
+
+	// Service 为哪个业务app增加worker
+	Service string `json:"service"`
+
+	// Worker 需要添加的资源，添加后，shard中如果存在WorkerGroup，只会将shard分配到该WorkerGroup下的worker中。
+	Worker string `json:"worker"`
+}
+
+type DrainRequest struct {
+	Service string `json:"service"`
+
+	// Container 摘除后不再分配shard，已有的shard会被迁移到其他container
+	Container string `json:"container"`
+}
+
+type ServiceDetail struct {
+	Spec              *AppSpec                      `json:"spec"`
+	ShardSpec         map[string]*storage.ShardSpec `json:"shardSpec"`
+	WorkerGroup       map[string][]string           `json:"workerGroup"`
+	Allocate          map[string][]string           `json:"allocate"`
+	AliveContainers   []string                      `json:"aliveContainers"`
+	NotAllocateShards []string                      `json:"notAllocateShards"`
+	DrainedContainers []string                      `json:"drainedContainers"`
+	// ShardState shard在app中的生命周期状态，container没有上报时不存在
+	ShardState map[string]storage.ShardState `json:"shardState"`
+	// ShardLoad app上报的shard负载，app没有实现 core.ShardLoadReporter 时不存在
+	ShardLoad map[string]*storage.ShardLoad `json:"shardLoad"`
+}
+
+type ResignRequest struct {
+	// Successor 提名的继任container，需要在竞选队列中，为空时由election的顺序决定
+	Successor string `json:"successor"`
+
+	// Timeout 等待进行中rb结束的时间，单位s，超时后中断rb
+	Timeout int `json:"timeout"`
+}
+
+// Leader sm集群当前的leader，ContainerId是leader的管理地址
+type Leader struct {
+	ContainerId string `json:"containerId"`
+	CreateTime  int64  `json:"createTime"`
+}
+
+type PrincipalRequest struct {
+	Name string `json:"name"`
+
+	// Tokens 明文token，只用于计算hash，不会写入etcd
+	Tokens []string `json:"tokens"`
+
+	// Identities mTLS客户端证书的CommonName
+	Identities []string `json:"identities"`
+
+	// Roles service到角色（read、write、admin）的映射，"*"对所有service生效
+	Roles map[string]string `json:"roles"`
+}
+
+type Principal struct {
+	Name        string            `json:"name"`
+	TokenHashes []string          `json:"tokenHashes"`
+	Identities  []string          `json:"identities"`
+	Roles       map[string]string `json:"roles"`
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smctl
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+	"github.com/entertainment-venue/sm/server/smapi"
+	"github.com/entertainment-venue/sm/server/smserver"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// defaultConfigFile 相对于用户home目录
+	defaultConfigFile = ".smctl.yml"
+
+	envConfigFile   = "SMCTL_CONFIG"
+	envToken        = "SMCTL_TOKEN"
+	envEtcdPassword = "SMCTL_ETCD_PASSWORD"
+)
+
+// Config smctl的配置文件，默认 ~/.smctl.yml
+type Config struct {
+	// Endpoints sm集群的管理地址，host:port
+	Endpoints []string `yaml:"endpoints"`
+
+	// Token sm开启acl时使用，也可以通过环境变量 SMCTL_TOKEN 传入
+	Token string `yaml:"token"`
+
+	// TLS sm管理端口开启tls时使用
+	TLS *TLSConfig `yaml:"tls"`
+
+	// Etcd sm集群不可用时，只读命令直接读取etcd
+	Etcd *EtcdConfig `yaml:"etcd"`
+}
+
+type TLSConfig struct {
+	CAFile   string `yaml:"caFile"`
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+type EtcdConfig struct {
+	Endpoints []string `yaml:"endpoints"`
+
+	// Prefix 和sm集群的etcdPrefix保持一致
+	Prefix string `yaml:"prefix"`
+
+	// Service sm集群自身的service
+	Service string `yaml:"service"`
+
+	Username string `yaml:"username"`
+	// Password 也可以通过环境变量 SMCTL_ETCD_PASSWORD 传入
+	Password string `yaml:"password"`
+
+	TLS *TLSConfig `yaml:"tls"`
+}
+
+// LoadConfig file为空时依次尝试 SMCTL_CONFIG 和 ~/.smctl.yml，默认的配置文件不存在不报错
+func LoadConfig(file string) (*Config, error) {
+	var cfg Config
+	explicit := file != ""
+	if !explicit {
+		file = os.Getenv(envConfigFile)
+		explicit = file != ""
+	}
+	if !explicit {
+		if home, err := os.UserHomeDir(); err == nil {
+			file = filepath.Join(home, defaultConfigFile)
+		}
+	}
+	if file != "" {
+		b, err := ioutil.ReadFile(file)
+		switch {
+		case err == nil:
+			if err := yaml.Unmarshal(b, &cfg); err != nil {
+				return nil, errors.Wrap(err, "")
+			}
+		case os.IsNotExist(err) && !explicit:
+		default:
+			return nil, errors.Wrap(err, "")
+		}
+	}
+
+	if v := os.Getenv(envToken); v != "" {
+		cfg.Token = v
+	}
+	if v := os.Getenv(envEtcdPassword); v != "" && cfg.Etcd != nil {
+		cfg.Etcd.Password = v
+	}
+	return &cfg, nil
+}
+
+// load sm管理端口和etcd的客户端tls配置格式相同
+func (cfg *TLSConfig) load() (*tls.Config, error) {
+	return etcdutil.NewTLSConfig(cfg.CAFile, cfg.CertFile, cfg.KeyFile)
+}
+
+// newAPIClient 访问sm集群的 /sm/server/* 接口
+func (cfg *Config) newAPIClient() (*smapi.Client, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, errors.New("endpoints not configured")
+	}
+	var opts []smapi.ClientOption
+	if cfg.Token != "" {
+		opts = append(opts, smapi.WithToken(cfg.Token))
+	}
+	if cfg.TLS != nil {
+		tc, err := cfg.TLS.load()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, smapi.WithTLSConfig(tc))
+	}
+	return smapi.NewClient(cfg.Endpoints, opts...)
+}
+
+// newInspector 直接读取etcd，调用方负责关闭返回的etcd client
+func (cfg *Config) newInspector() (*smserver.Inspector, *etcdutil.EtcdClient, error) {
+	if cfg.Etcd == nil || len(cfg.Etcd.Endpoints) == 0 {
+		return nil, nil, errors.New("etcd endpoints not configured")
+	}
+	if cfg.Etcd.Service == "" {
+		return nil, nil, errors.New("etcd service not configured")
+	}
+	var opts []etcdutil.EtcdClientOption
+	if cfg.Etcd.Username != "" {
+		opts = append(opts, etcdutil.WithAuth(cfg.Etcd.Username, cfg.Etcd.Password))
+	}
+	if cfg.Etcd.TLS != nil {
+		tc, err := cfg.Etcd.TLS.load()
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, etcdutil.WithTLSConfig(tc))
+	}
+	client, err := etcdutil.NewEtcdClient(cfg.Etcd.Endpoints, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return smserver.NewInspector(client, cfg.Etcd.Service, cfg.Etcd.Prefix), client, nil
+}
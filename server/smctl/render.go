@@ -0,0 +1,141 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smctl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/entertainment-venue/sm/server/smapi"
+)
+
+// none 表格中空值的占位
+const none = "-"
+
+func orNone(v string) string {
+	if v == "" {
+		return none
+	}
+	return v
+}
+
+// renderDetail 分别输出service配置、container和shard三部分
+func renderDetail(out io.Writer, detail *smapi.ServiceDetail) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if detail.Spec != nil {
+		fmt.Fprintf(w, "Service:\t%s\n", detail.Spec.Service)
+		fmt.Fprintf(w, "MaxShardCount:\t%d\n", detail.Spec.MaxShardCount)
+		fmt.Fprintf(w, "MaxRecoveryTime:\t%d\n", detail.Spec.MaxRecoveryTime)
+		fmt.Fprintf(w, "Receiver:\t%s\n", orNone(detail.Spec.Receiver))
+	}
+	fmt.Fprintf(w, "Shards:\t%d (%d not allocated)\n", len(detail.ShardSpec), len(detail.NotAllocateShards))
+	fmt.Fprintln(w)
+
+	// container可能只在workerGroup或者drain中出现，没有心跳
+	containerGroups := make(map[string][]string)
+	for group, containers := range detail.WorkerGroup {
+		for _, container := range containers {
+			containerGroups[container] = append(containerGroups[container], group)
+		}
+	}
+	status := make(map[string]string)
+	for container := range containerGroups {
+		status[container] = "dead"
+	}
+	for _, container := range detail.AliveContainers {
+		status[container] = "alive"
+	}
+	for _, container := range detail.DrainedContainers {
+		if status[container] == "alive" {
+			status[container] = "alive,drained"
+		} else {
+			status[container] = "dead,drained"
+		}
+	}
+	containers := make([]string, 0, len(status))
+	for container := range status {
+		containers = append(containers, container)
+	}
+	sort.Strings(containers)
+
+	fmt.Fprintln(w, "CONTAINER\tSTATUS\tWORKER GROUPS\tSHARDS")
+	for _, container := range containers {
+		groups := containerGroups[container]
+		sort.Strings(groups)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", container, status[container], orNone(strings.Join(groups, ",")), len(detail.Allocate[container]))
+	}
+	fmt.Fprintln(w)
+
+	assigned := shardContainers(detail)
+	shards := make([]string, 0, len(detail.ShardSpec))
+	for shard := range detail.ShardSpec {
+		shards = append(shards, shard)
+	}
+	sort.Strings(shards)
+
+	fmt.Fprintln(w, "SHARD\tCONTAINER\tGROUP\tWORKER GROUP\tMANUAL CONTAINER\tSTATE")
+	for _, shard := range shards {
+		spec := detail.ShardSpec[shard]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", shard, orNone(assigned[shard]), orNone(spec.Group), orNone(spec.WorkerGroup), orNone(spec.ManualContainerId), orNone(string(detail.ShardState[shard])))
+	}
+	return w.Flush()
+}
+
+// shardContainers shard到所在container的映射
+func shardContainers(detail *smapi.ServiceDetail) map[string]string {
+	r := make(map[string]string)
+	for container, shards := range detail.Allocate {
+		for _, shard := range shards {
+			r[shard] = container
+		}
+	}
+	return r
+}
+
+// diffShardContainers 两次查询之间shard的移动，按照shard排序
+func diffShardContainers(prev, cur map[string]string) []string {
+	shards := make(map[string]struct{})
+	for shard := range prev {
+		shards[shard] = struct{}{}
+	}
+	for shard := range cur {
+		shards[shard] = struct{}{}
+	}
+	ids := make([]string, 0, len(shards))
+	for shard := range shards {
+		ids = append(ids, shard)
+	}
+	sort.Strings(ids)
+
+	var lines []string
+	for _, shard := range ids {
+		from, to := prev[shard], cur[shard]
+		if from == to {
+			continue
+		}
+		switch {
+		case from == "":
+			lines = append(lines, fmt.Sprintf("%s assigned to %s", shard, to))
+		case to == "":
+			lines = append(lines, fmt.Sprintf("%s dropped from %s", shard, from))
+		default:
+			lines = append(lines, fmt.Sprintf("%s moved %s -> %s", shard, from, to))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,439 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smctl
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/entertainment-venue/sm/server/smapi"
+	"github.com/entertainment-venue/sm/server/smserver"
+	"github.com/pkg/errors"
+)
+
+// reader 只读命令的数据来源，smapi.Client 访问sm集群，etcdReader 直接读取etcd
+type reader interface {
+	GetSpec(ctx context.Context) ([]string, error)
+	Leader(ctx context.Context) (*smapi.Leader, error)
+	Detail(ctx context.Context, service string) (*smapi.ServiceDetail, error)
+}
+
+// etcdReader 把 smserver.Inspector 返回的json转换为smapi的类型，保证两种模式的输出一致
+type etcdReader struct {
+	inspector *smserver.Inspector
+}
+
+func (r *etcdReader) GetSpec(ctx context.Context) ([]string, error) {
+	return r.inspector.Services(ctx)
+}
+
+func (r *etcdReader) Leader(ctx context.Context) (*smapi.Leader, error) {
+	b, err := r.inspector.Leader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var leader smapi.Leader
+	if err := json.Unmarshal(b, &leader); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return &leader, nil
+}
+
+func (r *etcdReader) Detail(ctx context.Context, service string) (*smapi.ServiceDetail, error) {
+	b, err := r.inspector.Detail(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	var detail smapi.ServiceDetail
+	if err := json.Unmarshal(b, &detail); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return &detail, nil
+}
+
+type command struct {
+	name  string
+	usage string
+
+	// readOnly 只读命令支持 -offline 直接读取etcd
+	readOnly bool
+
+	run func(ctl *smctl, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []*command{
+	{name: "services", usage: "list services managed by sm", readOnly: true, run: runServices},
+	{name: "leader", usage: "show current sm leader", readOnly: true, run: runLeader},
+	{name: "detail", usage: "show shards, workers and containers of a service", readOnly: true, run: runDetail},
+	{name: "watch", usage: "watch shard movements of a service", readOnly: true, run: runWatch},
+	{name: "add-shard", usage: "add a shard to a service", run: runAddShard},
+	{name: "del-shard", usage: "delete a shard from a service", run: runDelShard},
+	{name: "add-worker", usage: "add a container to a worker group", run: runAddWorker},
+	{name: "del-worker", usage: "remove a container from a worker group", run: runDelWorker},
+	{name: "drain", usage: "move all shards off a container and stop assigning new ones", run: runDrain},
+	{name: "undrain", usage: "allow a drained container to receive shards again", run: runUndrain},
+}
+
+type smctl struct {
+	ctx context.Context
+	out io.Writer
+
+	// output table或者json
+	output string
+
+	reader reader
+
+	// api 只读命令在offline模式下为nil
+	api *smapi.Client
+}
+
+// Main smctl的入口，收到SIGINT/SIGTERM时取消正在执行的命令
+func Main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	if err := Run(ctx, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "smctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Run 解析全局参数和子命令并执行，结果写入out
+func Run(ctx context.Context, args []string, out io.Writer) error {
+	var (
+		configFile string
+		endpoints  string
+		token      string
+		offline    bool
+		output     string
+	)
+	gfs := flag.NewFlagSet("smctl", flag.ContinueOnError)
+	gfs.SetOutput(out)
+	gfs.StringVar(&configFile, "config", "", "config file, default $SMCTL_CONFIG or ~/.smctl.yml")
+	gfs.StringVar(&endpoints, "endpoints", "", "comma separated sm endpoints, override config file")
+	gfs.StringVar(&token, "token", "", "bearer token, override config file")
+	gfs.BoolVar(&offline, "offline", false, "read etcd directly, only for read-only commands")
+	gfs.StringVar(&output, "o", "table", "output format: table or json")
+	gfs.Usage = func() {
+		fmt.Fprint(out, "Usage: smctl [global flags] <command> [flags]\n\nCommands:\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(out, "  %-12s %s\n", cmd.name, cmd.usage)
+		}
+		fmt.Fprint(out, "\nGlobal flags:\n")
+		gfs.PrintDefaults()
+	}
+	if err := gfs.Parse(args); err != nil {
+		return err
+	}
+	if gfs.NArg() == 0 {
+		gfs.Usage()
+		return errors.New("command required")
+	}
+	if output != "table" && output != "json" {
+		return errors.Errorf("unknown output format %s", output)
+	}
+
+	var cmd *command
+	for _, c := range commands {
+		if c.name == gfs.Arg(0) {
+			cmd = c
+			break
+		}
+	}
+	if cmd == nil {
+		gfs.Usage()
+		return errors.Errorf("unknown command %s", gfs.Arg(0))
+	}
+	if offline && !cmd.readOnly {
+		return errors.Errorf("command %s can not run in offline mode", cmd.name)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	if endpoints != "" {
+		cfg.Endpoints = strings.Split(endpoints, ",")
+	}
+	if token != "" {
+		cfg.Token = token
+	}
+
+	ctl := smctl{ctx: ctx, out: out, output: output}
+	if offline {
+		inspector, client, err := cfg.newInspector()
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		ctl.reader = &etcdReader{inspector: inspector}
+	} else {
+		ctl.api, err = cfg.newAPIClient()
+		if err != nil {
+			return err
+		}
+		ctl.reader = ctl.api
+	}
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprintf(out, "Usage: smctl %s [flags]\n\n%s\n\nFlags:\n", cmd.name, cmd.usage)
+		fs.PrintDefaults()
+	}
+	return cmd.run(&ctl, fs, gfs.Args()[1:])
+}
+
+// parse 解析子命令参数，required中的参数不能为空
+func parse(fs *flag.FlagSet, args []string, required map[string]*string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var missing []string
+	for name, v := range required {
+		if *v == "" {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		fs.Usage()
+		return errors.Errorf("%s required", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (ctl *smctl) printJSON(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	_, err = fmt.Fprintln(ctl.out, string(b))
+	return err
+}
+
+func runServices(ctl *smctl, fs *flag.FlagSet, args []string) error {
+	if err := parse(fs, args, nil); err != nil {
+		return err
+	}
+	services, err := ctl.reader.GetSpec(ctl.ctx)
+	if err != nil {
+		return err
+	}
+	sort.Strings(services)
+	if ctl.output == "json" {
+		return ctl.printJSON(services)
+	}
+	for _, service := range services {
+		fmt.Fprintln(ctl.out, service)
+	}
+	return nil
+}
+
+func runLeader(ctl *smctl, fs *flag.FlagSet, args []string) error {
+	if err := parse(fs, args, nil); err != nil {
+		return err
+	}
+	leader, err := ctl.reader.Leader(ctl.ctx)
+	if err != nil {
+		return err
+	}
+	if ctl.output == "json" {
+		return ctl.printJSON(leader)
+	}
+	fmt.Fprintf(ctl.out, "%s\t(since %s)\n", leader.ContainerId, time.Unix(leader.CreateTime, 0).Format(time.RFC3339))
+	return nil
+}
+
+func runDetail(ctl *smctl, fs *flag.FlagSet, args []string) error {
+	service := fs.String("service", "", "service name")
+	if err := parse(fs, args, map[string]*string{"service": service}); err != nil {
+		return err
+	}
+	detail, err := ctl.reader.Detail(ctl.ctx, *service)
+	if err != nil {
+		return err
+	}
+	if ctl.output == "json" {
+		return ctl.printJSON(detail)
+	}
+	return renderDetail(ctl.out, detail)
+}
+
+func runWatch(ctl *smctl, fs *flag.FlagSet, args []string) error {
+	service := fs.String("service", "", "service name")
+	interval := fs.Duration("interval", 2*time.Second, "poll interval")
+	if err := parse(fs, args, map[string]*string{"service": service}); err != nil {
+		return err
+	}
+
+	var prev map[string]string
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		detail, err := ctl.reader.Detail(ctl.ctx, *service)
+		if err != nil {
+			// sm切换leader等场景下短暂不可用，继续等待
+			fmt.Fprintf(ctl.out, "%s\terror: %v\n", time.Now().Format(time.RFC3339), err)
+		} else {
+			cur := shardContainers(detail)
+			if prev == nil {
+				fmt.Fprintf(ctl.out, "%s\twatching %s, %d shards on %d containers\n", time.Now().Format(time.RFC3339), *service, len(detail.ShardSpec), len(detail.AliveContainers))
+			} else {
+				for _, line := range diffShardContainers(prev, cur) {
+					fmt.Fprintf(ctl.out, "%s\t%s\n", time.Now().Format(time.RFC3339), line)
+				}
+			}
+			prev = cur
+		}
+
+		select {
+		case <-ctl.ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func runAddShard(ctl *smctl, fs *flag.FlagSet, args []string) error {
+	var req smapi.AddShardRequest
+	fs.StringVar(&req.Service, "service", "", "service name")
+	fs.StringVar(&req.ShardId, "shard", "", "shard id")
+	fs.StringVar(&req.Task, "task", "", "task content defined by service")
+	fs.StringVar(&req.Group, "group", "", "shard group")
+	fs.StringVar(&req.WorkerGroup, "worker-group", "", "worker group the shard can be assigned to")
+	fs.StringVar(&req.ManualContainerId, "manual-container", "", "pin the shard to a container")
+	if err := parse(fs, args, map[string]*string{"service": &req.Service, "shard": &req.ShardId}); err != nil {
+		return err
+	}
+	if err := ctl.api.AddShard(ctl.ctx, &req); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctl.out, "shard %s added to %s\n", req.ShardId, req.Service)
+	return nil
+}
+
+func runDelShard(ctl *smctl, fs *flag.FlagSet, args []string) error {
+	var req smapi.DelShardRequest
+	fs.StringVar(&req.Service, "service", "", "service name")
+	fs.StringVar(&req.ShardId, "shard", "", "shard id")
+	if err := parse(fs, args, map[string]*string{"service": &req.Service, "shard": &req.ShardId}); err != nil {
+		return err
+	}
+	if err := ctl.api.DelShard(ctl.ctx, &req); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctl.out, "shard %s deleted from %s\n", req.ShardId, req.Service)
+	return nil
+}
+
+func workerFlags(fs *flag.FlagSet, req *smapi.WorkerRequest) map[string]*string {
+	fs.StringVar(&req.Service, "service", "", "service name")
+	fs.StringVar(&req.WorkerGroup, "group", "", "worker group")
+	fs.StringVar(&req.Worker, "worker", "", "container id, host:port")
+	return map[string]*string{"service": &req.Service, "group": &req.WorkerGroup, "worker": &req.Worker}
+}
+
+func runAddWorker(ctl *smctl, fs *flag.FlagSet, args []string) error {
+	var req smapi.WorkerRequest
+	if err := parse(fs, args, workerFlags(fs, &req)); err != nil {
+		return err
+	}
+	if err := ctl.api.AddWorker(ctl.ctx, &req); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctl.out, "worker %s added to %s/%s\n", req.Worker, req.Service, req.WorkerGroup)
+	return nil
+}
+
+func runDelWorker(ctl *smctl, fs *flag.FlagSet, args []string) error {
+	var req smapi.WorkerRequest
+	if err := parse(fs, args, workerFlags(fs, &req)); err != nil {
+		return err
+	}
+	if err := ctl.api.DelWorker(ctl.ctx, &req); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctl.out, "worker %s removed from %s/%s\n", req.Worker, req.Service, req.WorkerGroup)
+	return nil
+}
+
+func runDrain(ctl *smctl, fs *flag.FlagSet, args []string) error {
+	var req smapi.DrainRequest
+	fs.StringVar(&req.Service, "service", "", "service name")
+	fs.StringVar(&req.Container, "container", "", "container id, host:port")
+	wait := fs.Bool("wait", false, "wait until no shard left on the container")
+	timeout := fs.Duration("timeout", 5*time.Minute, "max time to wait")
+	if err := parse(fs, args, map[string]*string{"service": &req.Service, "container": &req.Container}); err != nil {
+		return err
+	}
+	if err := ctl.api.DrainContainer(ctl.ctx, &req); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctl.out, "container %s drained from %s\n", req.Container, req.Service)
+	if !*wait {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctl.ctx, *timeout)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		detail, err := ctl.api.Detail(ctx, req.Service)
+		if err == nil {
+			left := len(detail.Allocate[req.Container])
+			if left == 0 {
+				fmt.Fprintf(ctl.out, "no shard left on %s\n", req.Container)
+				return nil
+			}
+			fmt.Fprintf(ctl.out, "%d shards left on %s\n", left, req.Container)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("shards still on %s after %s", req.Container, *timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+func runUndrain(ctl *smctl, fs *flag.FlagSet, args []string) error {
+	var req smapi.DrainRequest
+	fs.StringVar(&req.Service, "service", "", "service name")
+	fs.StringVar(&req.Container, "container", "", "container id, host:port")
+	if err := parse(fs, args, map[string]*string{"service": &req.Service, "container": &req.Container}); err != nil {
+		return err
+	}
+	if err := ctl.api.UndrainContainer(ctl.ctx, &req); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctl.out, "container %s undrained from %s\n", req.Container, req.Service)
+	return nil
+}
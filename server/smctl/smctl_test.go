@@ -0,0 +1,159 @@
+package smctl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/entertainment-venue/sm/server/smapi"
+	"github.com/entertainment-venue/sm/server/smserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSmctl(t *testing.T) {
+	suite.Run(t, new(SmctlTestSuite))
+}
+
+type SmctlTestSuite struct {
+	suite.Suite
+
+	server *smserver.Server
+	config string
+}
+
+// SetupSuite 启动进程内的smserver，依赖本地127.0.0.1:2379的etcd
+func (suite *SmctlTestSuite) SetupSuite() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	pfx := fmt.Sprintf("/smctl-test-%d", time.Now().UnixNano())
+	suite.server, err = smserver.NewServer(
+		smserver.WithId(addr),
+		smserver.WithService("smctl.test"),
+		smserver.WithAddr(addr),
+		smserver.WithEndpoints([]string{"127.0.0.1:2379"}),
+		smserver.WithEtcdPrefix(pfx),
+	)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.config = filepath.Join(suite.T().TempDir(), "smctl.yml")
+	content := fmt.Sprintf("endpoints: [%s]\netcd:\n  endpoints: [127.0.0.1:2379]\n  prefix: %s\n  service: smctl.test\n", addr, pfx)
+	if err := ioutil.WriteFile(suite.config, []byte(content), 0644); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if _, err = suite.run("leader"); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	cfg, _ := LoadConfig(suite.config)
+	client, _ := cfg.newAPIClient()
+	if err := client.AddSpec(context.TODO(), &smapi.AppSpec{Service: "foo.ctl"}); err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *SmctlTestSuite) TearDownSuite() {
+	if suite.server != nil {
+		suite.server.Close()
+	}
+}
+
+func (suite *SmctlTestSuite) run(args ...string) (string, error) {
+	var out bytes.Buffer
+	err := Run(context.TODO(), append([]string{"-config", suite.config}, args...), &out)
+	return out.String(), err
+}
+
+func (suite *SmctlTestSuite) TestServices() {
+	out, err := suite.run("services")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "foo.ctl\nsmctl.test\n", out)
+
+	out, err = suite.run("-offline", "services")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "foo.ctl\nsmctl.test\n", out)
+}
+
+func (suite *SmctlTestSuite) TestShardAndWorker() {
+	_, err := suite.run("add-shard", "-service", "foo.ctl", "-shard", "s1", "-worker-group", "g1")
+	assert.NoError(suite.T(), err)
+	_, err = suite.run("add-worker", "-service", "foo.ctl", "-group", "g1", "-worker", "127.0.0.1:8801")
+	assert.NoError(suite.T(), err)
+	_, err = suite.run("drain", "-service", "foo.ctl", "-container", "127.0.0.1:8801")
+	assert.NoError(suite.T(), err)
+
+	out, err := suite.run("detail", "-service", "foo.ctl")
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), out, "127.0.0.1:8801  dead,drained  g1")
+	assert.Contains(suite.T(), out, "s1     -          -      g1")
+
+	// offline和online的输出一致
+	offline, err := suite.run("-offline", "detail", "-service", "foo.ctl")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), out, offline)
+
+	_, err = suite.run("undrain", "-service", "foo.ctl", "-container", "127.0.0.1:8801")
+	assert.NoError(suite.T(), err)
+	_, err = suite.run("del-worker", "-service", "foo.ctl", "-group", "g1", "-worker", "127.0.0.1:8801")
+	assert.NoError(suite.T(), err)
+	_, err = suite.run("del-shard", "-service", "foo.ctl", "-shard", "s1")
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *SmctlTestSuite) TestUsageError() {
+	_, err := suite.run("add-shard", "-service", "foo.ctl")
+	assert.EqualError(suite.T(), err, "-shard required")
+
+	_, err = suite.run("-offline", "del-shard", "-service", "foo.ctl", "-shard", "s1")
+	assert.Error(suite.T(), err)
+
+	_, err = suite.run("unknown")
+	assert.Error(suite.T(), err)
+}
+
+func Test_diffShardContainers(t *testing.T) {
+	prev := map[string]string{"s1": "c1", "s2": "c1", "s3": "c2"}
+	cur := map[string]string{"s1": "c1", "s2": "c2", "s4": "c2"}
+	assert.Equal(
+		t,
+		[]string{"s2 moved c1 -> c2", "s3 dropped from c2", "s4 assigned to c2"},
+		diffShardContainers(prev, cur),
+	)
+}
+
+func Test_LoadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "smctl.yml")
+	_ = ioutil.WriteFile(file, []byte("endpoints: [127.0.0.1:8801]\ntoken: foo\netcd:\n  endpoints: [127.0.0.1:2379]\n"), 0644)
+
+	os.Setenv(envEtcdPassword, "bar")
+	defer os.Unsetenv(envEtcdPassword)
+	cfg, err := LoadConfig(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:8801"}, cfg.Endpoints)
+	assert.Equal(t, "foo", cfg.Token)
+	assert.Equal(t, "bar", cfg.Etcd.Password)
+
+	// 显式指定的配置文件必须存在
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "not-exist.yml"))
+	assert.Error(t, err)
+}
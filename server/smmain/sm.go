@@ -1,6 +1,9 @@
 package smmain
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -8,6 +11,7 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
 	"github.com/entertainment-venue/sm/pkg/logutil"
 	"github.com/entertainment-venue/sm/server/smserver"
 	"github.com/pkg/errors"
@@ -33,6 +37,66 @@ type serverConfig struct {
 	Port       string   `yaml:"port"`
 	Endpoints  []string `yaml:"endpoints"`
 	EtcdPrefix string   `yaml:"etcdPrefix"`
+
+	// EtcdUsername和EtcdPassword etcd开启RBAC时使用，密码也可以通过环境变量 SM_ETCD_PASSWORD 传入
+	EtcdUsername string `yaml:"etcdUsername"`
+	EtcdPassword string `yaml:"etcdPassword"`
+	// EtcdTLS 不配置时使用http访问etcd
+	EtcdTLS *etcdTLSConfig `yaml:"etcdTLS"`
+
+	// TLS 不配置时管理端口使用http
+	TLS *tlsConfig `yaml:"tls"`
+	// ACL 不配置时不做认证和鉴权，只记录审计日志
+	ACL *aclConfig `yaml:"acl"`
+
+	// GRPCPort 不配置时不提供gRPC接口
+	GRPCPort string `yaml:"grpcPort"`
+}
+
+type tlsConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+
+	// ClientCAFile 配置后校验客户端证书，证书的CommonName作为acl中的identity
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+func (cfg *tlsConfig) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	tc := tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.ClientCAFile != "" {
+		b, err := ioutil.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("no cert in %s", cfg.ClientCAFile)
+		}
+		tc.ClientCAs = pool
+		// token认证的调用方可以不提供证书
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return &tc, nil
+}
+
+type etcdTLSConfig struct {
+	CAFile   string `yaml:"caFile"`
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+type aclConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// AdminToken 拥有所有service的admin权限，用于初始化acl，也可以通过环境变量 SM_ACL_ADMIN_TOKEN 传入
+	AdminToken string `yaml:"adminToken"`
 }
 
 func (cfg *serverConfig) validate() {
@@ -70,6 +134,54 @@ func StartSM() error {
 
 	addr := defaultAppMixer{port: srvCfg.Port}.Addr()
 
+	opts := []smserver.ServerOption{
+		smserver.WithId(addr),
+		smserver.WithService(srvCfg.Service),
+		smserver.WithAddr(fmt.Sprintf(":%s", srvCfg.Port)),
+		smserver.WithEndpoints(srvCfg.Endpoints),
+		smserver.WithEtcdPrefix(srvCfg.EtcdPrefix),
+	}
+	etcdPassword := srvCfg.EtcdPassword
+	if v := os.Getenv("SM_ETCD_PASSWORD"); v != "" {
+		etcdPassword = v
+	}
+	if srvCfg.EtcdUsername != "" {
+		opts = append(
+			opts,
+			smserver.WithEtcdUsername(srvCfg.EtcdUsername),
+			smserver.WithEtcdPassword(etcdPassword),
+		)
+	}
+	if srvCfg.EtcdTLS != nil {
+		tc, err := etcdutil.NewTLSConfig(srvCfg.EtcdTLS.CAFile, srvCfg.EtcdTLS.CertFile, srvCfg.EtcdTLS.KeyFile)
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		opts = append(opts, smserver.WithEtcdTLSConfig(tc))
+	}
+	if srvCfg.TLS != nil {
+		tc, err := srvCfg.TLS.load()
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		opts = append(opts, smserver.WithTLSConfig(tc))
+	}
+	if srvCfg.ACL != nil {
+		adminToken := srvCfg.ACL.AdminToken
+		if v := os.Getenv("SM_ACL_ADMIN_TOKEN"); v != "" {
+			adminToken = v
+		}
+		opts = append(
+			opts,
+			smserver.WithACL(srvCfg.ACL.Enabled),
+			smserver.WithACLAdminToken(adminToken),
+		)
+	}
+
+	if srvCfg.GRPCPort != "" {
+		opts = append(opts, smserver.WithGRPCAddr(fmt.Sprintf(":%s", srvCfg.GRPCPort)))
+	}
+
 	lg, zapError := logutil.NewLogger(logutil.WithStdout(false))
 	if zapError != nil {
 		fmt.Printf("error creating zap logger %v", zapError)
@@ -77,12 +189,7 @@ func StartSM() error {
 	}
 	defer lg.Sync()
 
-	srv, err := smserver.NewServer(
-		smserver.WithId(addr),
-		smserver.WithService(srvCfg.Service),
-		smserver.WithAddr(fmt.Sprintf(":%s", srvCfg.Port)),
-		smserver.WithEndpoints(srvCfg.Endpoints),
-		smserver.WithEtcdPrefix(srvCfg.EtcdPrefix))
+	srv, err := smserver.NewServer(opts...)
 	if err != nil {
 		lg.Panic(
 			"NewServer error",
@@ -93,10 +200,11 @@ func StartSM() error {
 
 	// 监听信号
 	go func() {
-		sigChan := make(chan os.Signal)
+		sigChan := make(chan os.Signal, 1)
 		signals := []os.Signal{
 			syscall.SIGINT,
 			syscall.SIGTERM,
+			syscall.SIGUSR1,
 		}
 		signal.Notify(sigChan, signals...)
 		for {
@@ -106,6 +214,14 @@ func StartSM() error {
 				lg.Warn("Received exit signal", zap.String("sig", sig.String()))
 				srv.Close()
 				return
+			case syscall.SIGUSR1:
+				// 发布前主动放弃leader，减少新leader接手时的shard移动
+				lg.Warn("Received resign signal", zap.String("sig", sig.String()))
+				go func() {
+					if err := srv.Resign(context.Background(), ""); err != nil {
+						lg.Error("Resign error", zap.Error(err))
+					}
+				}()
 			default:
 				lg.Warn("Received unexpected signal", zap.String("sig", sig.String()))
 			}
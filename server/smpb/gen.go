@@ -0,0 +1,4 @@
+// Package smpb sm的gRPC接口定义，sm.pb.go和sm_grpc.pb.go由sm.proto生成，不要手动修改
+package smpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative sm.proto
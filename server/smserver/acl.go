@@ -0,0 +1,413 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+	"github.com/entertainment-venue/sm/pkg/logutil"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	errACLUnauthenticated = errors.New("unauthenticated")
+	errACLForbidden       = errors.New("permission denied")
+	errACLServiceConflict = errors.New("service in query and body not match")
+)
+
+const (
+	aclRoleRead  = "read"
+	aclRoleWrite = "write"
+	aclRoleAdmin = "admin"
+
+	// aclAnyService 对所有service生效的授权，sm自身的操作（resign、acl管理）只认这个授权
+	aclAnyService = "*"
+
+	// aclAnonymous 未开启acl时，审计日志中的调用方
+	aclAnonymous = "anonymous"
+	// aclBootstrapPrincipal 配置中的admin token对应的调用方，用于acl的初始化和兜底
+	aclBootstrapPrincipal = "bootstrap"
+
+	// aclPrincipalKey 认证通过后，调用方的名字存放在gin.Context中
+	aclPrincipalKey = "sm-acl-principal"
+)
+
+// aclRoleLevels 高级别的角色包含低级别角色的权限
+var aclRoleLevels = map[string]int{
+	aclRoleRead:  1,
+	aclRoleWrite: 2,
+	aclRoleAdmin: 3,
+}
+
+// aclRule 接口需要的角色
+type aclRule struct {
+	role string
+
+	// mutating 修改类接口，需要审计
+	mutating bool
+
+	// global 接口不针对某个service，需要 aclAnyService 的授权
+	global bool
+}
+
+// aclRules 没有出现在这里的接口不做校验，例如health、swagger
+var aclRules = map[string]aclRule{
+	"/sm/server/add-spec":           {role: aclRoleAdmin, mutating: true},
+	"/sm/server/del-spec":           {role: aclRoleAdmin, mutating: true},
+	"/sm/server/update-spec":        {role: aclRoleAdmin, mutating: true},
+	"/sm/server/get-spec":           {role: aclRoleRead},
+	"/sm/server/add-shard":          {role: aclRoleWrite, mutating: true},
+	"/sm/server/del-shard":          {role: aclRoleWrite, mutating: true},
+	"/sm/server/update-shard":       {role: aclRoleWrite, mutating: true},
+	"/sm/server/get-shard":          {role: aclRoleRead},
+	"/sm/server/batch-add-shard":    {role: aclRoleWrite, mutating: true},
+	"/sm/server/batch-update-shard": {role: aclRoleWrite, mutating: true},
+	"/sm/server/batch-del-shard":    {role: aclRoleWrite, mutating: true},
+	"/sm/server/apply":              {role: aclRoleAdmin, mutating: true},
+	"/sm/server/add-worker":         {role: aclRoleWrite, mutating: true},
+	"/sm/server/del-worker":         {role: aclRoleWrite, mutating: true},
+	"/sm/server/get-worker":         {role: aclRoleRead},
+	"/sm/server/drain-container":    {role: aclRoleWrite, mutating: true},
+	"/sm/server/undrain-container":  {role: aclRoleWrite, mutating: true},
+	"/sm/server/detail":             {role: aclRoleRead},
+	"/sm/server/resign":             {role: aclRoleAdmin, mutating: true, global: true},
+	"/sm/server/acl/set-principal":  {role: aclRoleAdmin, mutating: true, global: true},
+	"/sm/server/acl/del-principal":  {role: aclRoleAdmin, mutating: true, global: true},
+	"/sm/server/acl/get-principal":  {role: aclRoleAdmin, global: true},
+}
+
+// aclPrincipal 调用方，通过bearer token或者mTLS客户端证书的CommonName识别
+type aclPrincipal struct {
+	Name string `json:"name"`
+
+	// TokenHashes token的sha256，etcd中不保存明文
+	TokenHashes []string `json:"tokenHashes"`
+
+	// Identities 客户端证书的CommonName
+	Identities []string `json:"identities"`
+
+	// Roles service到角色的映射，key为 aclAnyService 时对所有service生效
+	Roles map[string]string `json:"roles"`
+}
+
+func (p *aclPrincipal) String() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// allow service为空代表sm自身的操作
+func (p *aclPrincipal) allow(service string, role string) bool {
+	need := aclRoleLevels[role]
+	if need == 0 {
+		return false
+	}
+	if service != "" && aclRoleLevels[p.Roles[service]] >= need {
+		return true
+	}
+	return aclRoleLevels[p.Roles[aclAnyService]] >= need
+}
+
+func aclTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// aclManager 维护etcd中acl的内存快照，提供认证、鉴权和审计
+type aclManager struct {
+	container *smContainer
+
+	// enabled 未开启时所有请求都放行，只做审计
+	enabled bool
+
+	// bootstrap 配置中的admin token，不存储在etcd中
+	bootstrap *aclPrincipal
+
+	mu         sync.RWMutex
+	principals map[string]*aclPrincipal
+	tokens     map[string]*aclPrincipal
+	identities map[string]*aclPrincipal
+}
+
+func newACLManager(container *smContainer, enabled bool, adminToken string) *aclManager {
+	m := aclManager{
+		container:  container,
+		enabled:    enabled,
+		principals: make(map[string]*aclPrincipal),
+	}
+	if adminToken != "" {
+		m.bootstrap = &aclPrincipal{
+			Name:        aclBootstrapPrincipal,
+			TokenHashes: []string{aclTokenHash(adminToken)},
+			Roles:       map[string]string{aclAnyService: aclRoleAdmin},
+		}
+	}
+	m.rebuild()
+	return &m
+}
+
+// load 加载etcd中的acl，返回的revision用于后续watch
+func (m *aclManager) load(ctx context.Context) (int64, error) {
+	resp, err := m.container.Client.Get(ctx, m.container.nodeManager.ACLPrincipalDir(), clientv3.WithPrefix())
+	if err != nil {
+		return 0, errors.Wrap(err, "")
+	}
+	m.reset(resp)
+	return resp.Header.Revision, nil
+}
+
+func (m *aclManager) watch(ctx context.Context, rev int64) {
+	etcdutil.WatchLoopWithResync(
+		ctx,
+		m.container.Client,
+		m.container.nodeManager.ACLPrincipalDir(),
+		rev+1,
+		func(ctx context.Context, ev *clientv3.Event) error {
+			name := strings.TrimPrefix(string(ev.Kv.Key), m.container.nodeManager.ACLPrincipalDir())
+			if ev.Type == clientv3.EventTypeDelete {
+				m.mu.Lock()
+				delete(m.principals, name)
+				m.mu.Unlock()
+				m.rebuild()
+				return nil
+			}
+			var p aclPrincipal
+			if err := json.Unmarshal(ev.Kv.Value, &p); err != nil {
+				logutil.Error(
+					"Unmarshal principal error",
+					zap.String("key", string(ev.Kv.Key)),
+					zap.Error(err),
+				)
+				return nil
+			}
+			p.Name = name
+			m.mu.Lock()
+			m.principals[name] = &p
+			m.mu.Unlock()
+			m.rebuild()
+			return nil
+		},
+		func(ctx context.Context, resp *clientv3.GetResponse) error {
+			m.reset(resp)
+			return nil
+		},
+	)
+}
+
+func (m *aclManager) reset(resp *clientv3.GetResponse) {
+	principals := make(map[string]*aclPrincipal)
+	for _, kv := range resp.Kvs {
+		var p aclPrincipal
+		if err := json.Unmarshal(kv.Value, &p); err != nil {
+			logutil.Error(
+				"Unmarshal principal error",
+				zap.String("key", string(kv.Key)),
+				zap.Error(err),
+			)
+			continue
+		}
+		p.Name = strings.TrimPrefix(string(kv.Key), m.container.nodeManager.ACLPrincipalDir())
+		principals[p.Name] = &p
+	}
+
+	m.mu.Lock()
+	m.principals = principals
+	m.mu.Unlock()
+	m.rebuild()
+
+	logutil.Info(
+		"acl reset",
+		zap.Int("principal-cnt", len(principals)),
+	)
+}
+
+// rebuild 根据principals重建token和identity的索引
+func (m *aclManager) rebuild() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens = make(map[string]*aclPrincipal)
+	m.identities = make(map[string]*aclPrincipal)
+	for _, p := range m.principals {
+		for _, hash := range p.TokenHashes {
+			m.tokens[hash] = p
+		}
+		for _, identity := range p.Identities {
+			m.identities[identity] = p
+		}
+	}
+	if m.bootstrap != nil {
+		m.tokens[m.bootstrap.TokenHashes[0]] = m.bootstrap
+	}
+}
+
+// authenticate token优先，其次是经过校验的客户端证书
+func (m *aclManager) authenticate(r *http.Request) (*aclPrincipal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		if p, ok := m.tokens[aclTokenHash(token)]; ok {
+			return p, nil
+		}
+		return nil, errACLUnauthenticated
+	}
+
+	// 只认经过CA校验的证书，RequestClientCert模式下的证书不可信
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if p, ok := m.identities[cn]; ok {
+			return p, nil
+		}
+	}
+	return nil, errACLUnauthenticated
+}
+
+// handler 作为route的middleware，router在注册时确定，不依赖gin的FullPath
+func (m *aclManager) handler(router string) gin.HandlerFunc {
+	rule, ok := aclRules[router]
+	return func(c *gin.Context) {
+		if !ok {
+			c.Next()
+			return
+		}
+
+		var (
+			start     = time.Now()
+			principal = aclAnonymous
+			service   string
+		)
+		if rule.mutating {
+			defer func() {
+				m.audit(c, principal, service, start)
+			}()
+		}
+
+		if !rule.global {
+			var err error
+			service, err = aclRequestService(c)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if m.enabled {
+			p, err := m.authenticate(c.Request)
+			if err != nil {
+				logutil.Warn(
+					"authenticate error",
+					zap.String("path", c.Request.URL.Path),
+					zap.String("remote", c.ClientIP()),
+					zap.Error(err),
+				)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			principal = p.Name
+			if !p.allow(service, rule.role) {
+				logutil.Warn(
+					"permission denied",
+					zap.String("principal", p.Name),
+					zap.String("path", c.Request.URL.Path),
+					zap.String("service", service),
+					zap.String("role", rule.role),
+				)
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": errACLForbidden.Error()})
+				return
+			}
+		}
+		c.Set(aclPrincipalKey, principal)
+		c.Next()
+	}
+}
+
+// audit 修改类请求的审计日志，包含被拒绝的请求，请求body中可能包含token，不记录
+func (m *aclManager) audit(c *gin.Context, principal string, service string, start time.Time) {
+	logutil.Info(
+		"audit",
+		zap.String("principal", principal),
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+		zap.String("query", c.Request.URL.RawQuery),
+		zap.String("service", service),
+		zap.String("remote", c.ClientIP()),
+		zap.Int("status", c.Writer.Status()),
+		zap.Duration("latency", time.Since(start)),
+	)
+}
+
+// aclServiceHint 从请求body中识别service，manifest的service在spec中
+type aclServiceHint struct {
+	Service string `json:"service" yaml:"service"`
+	Spec    *struct {
+		Service string `json:"service" yaml:"service"`
+	} `json:"spec" yaml:"spec"`
+}
+
+// aclRequestService query和body中都可能携带service，两者不一致时拒绝，防止用自己的service通过鉴权，操作其他service
+func aclRequestService(c *gin.Context) (string, error) {
+	service := c.Query("service")
+	if c.Request.Body == nil {
+		return service, nil
+	}
+
+	b, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "")
+	}
+	// body交给后续的handler继续使用
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if len(b) == 0 {
+		return service, nil
+	}
+
+	var hint aclServiceHint
+	if c.ContentType() == binding.MIMEYAML {
+		err = yaml.Unmarshal(b, &hint)
+	} else {
+		err = json.Unmarshal(b, &hint)
+	}
+	if err != nil {
+		// 识别不了body时，handler可能以其他方式（例如form）读取到其他service，只能要求 aclAnyService 的授权
+		return "", nil
+	}
+	bodyService := hint.Service
+	if bodyService == "" && hint.Spec != nil {
+		bodyService = hint.Spec.Service
+	}
+	if bodyService == "" {
+		return service, nil
+	}
+	if service != "" && service != bodyService {
+		return "", errACLServiceConflict
+	}
+	return bodyService, nil
+}
@@ -0,0 +1,135 @@
+package smserver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestACL(t *testing.T) {
+	suite.Run(t, new(ACLTestSuite))
+}
+
+type ACLTestSuite struct {
+	suite.Suite
+
+	container *smContainer
+	acl       *aclManager
+	router    *gin.Engine
+}
+
+func (suite *ACLTestSuite) SetupTest() {
+	suite.container = &smContainer{nodeManager: newNodeManager("foo", etcdutil.NewPathBuilder(""))}
+	suite.acl = newACLManager(suite.container, true, "root")
+
+	p := aclPrincipal{
+		TokenHashes: []string{aclTokenHash("bar-reader")},
+		Identities:  []string{"bar-client"},
+		Roles:       map[string]string{"bar": aclRoleWrite, "baz": aclRoleRead},
+	}
+	suite.acl.reset(&clientv3.GetResponse{
+		Kvs: []*mvccpb.KeyValue{
+			{Key: []byte(suite.container.nodeManager.ACLPrincipalPath("ops")), Value: []byte(p.String())},
+		},
+	})
+
+	// handler替换为桩，只验证middleware
+	suite.router = gin.New()
+	for _, router := range []string{"/sm/server/del-shard", "/sm/server/get-shard", "/sm/server/resign", "/sm/server/apply", "/sm/server/health"} {
+		suite.router.Any(router, suite.acl.handler(router), func(c *gin.Context) {
+			b, _ := ioutil.ReadAll(c.Request.Body)
+			c.String(http.StatusOK, "%s", b)
+		})
+	}
+}
+
+func (suite *ACLTestSuite) serve(method, target, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	req.Header.Add("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *ACLTestSuite) TestAllow() {
+	p := aclPrincipal{Roles: map[string]string{"bar": aclRoleWrite, aclAnyService: aclRoleRead}}
+	assert.True(suite.T(), p.allow("bar", aclRoleRead))
+	assert.True(suite.T(), p.allow("bar", aclRoleWrite))
+	assert.False(suite.T(), p.allow("bar", aclRoleAdmin))
+	assert.True(suite.T(), p.allow("other", aclRoleRead))
+	assert.False(suite.T(), p.allow("other", aclRoleWrite))
+	assert.False(suite.T(), p.allow("", aclRoleWrite))
+	assert.False(suite.T(), p.allow("bar", "unknown"))
+}
+
+func (suite *ACLTestSuite) TestHandler_public() {
+	w := suite.serve(http.MethodGet, "/sm/server/health", "", "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
+
+func (suite *ACLTestSuite) TestHandler_unauthenticated() {
+	w := suite.serve(http.MethodPost, "/sm/server/del-shard", "", `{"service":"bar","shardId":"s1"}`)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+
+	w = suite.serve(http.MethodPost, "/sm/server/del-shard", "unknown", `{"service":"bar","shardId":"s1"}`)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+}
+
+func (suite *ACLTestSuite) TestHandler_forbidden() {
+	w := suite.serve(http.MethodPost, "/sm/server/del-shard", "bar-reader", `{"service":"baz","shardId":"s1"}`)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+
+	w = suite.serve(http.MethodPost, "/sm/server/resign", "bar-reader", "")
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+
+	// manifest的service在spec中
+	w = suite.serve(http.MethodPost, "/sm/server/apply", "bar-reader", `{"spec":{"service":"bar"}}`)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+}
+
+func (suite *ACLTestSuite) TestHandler_allowed() {
+	body := `{"service":"bar","shardId":"s1"}`
+	w := suite.serve(http.MethodPost, "/sm/server/del-shard", "bar-reader", body)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	// body需要完整的交给handler
+	assert.Equal(suite.T(), body, w.Body.String())
+
+	w = suite.serve(http.MethodGet, "/sm/server/get-shard?service=baz", "bar-reader", "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = suite.serve(http.MethodPost, "/sm/server/resign", "root", "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
+
+func (suite *ACLTestSuite) TestHandler_serviceConflict() {
+	w := suite.serve(http.MethodPost, "/sm/server/del-shard?service=bar", "bar-reader", `{"service":"baz","shardId":"s1"}`)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+func (suite *ACLTestSuite) TestHandler_disabled() {
+	suite.acl.enabled = false
+	w := suite.serve(http.MethodPost, "/sm/server/del-shard", "", `{"service":"baz","shardId":"s1"}`)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
+
+func (suite *ACLTestSuite) TestRebuild_delete() {
+	suite.acl.mu.Lock()
+	delete(suite.acl.principals, "ops")
+	suite.acl.mu.Unlock()
+	suite.acl.rebuild()
+
+	w := suite.serve(http.MethodGet, "/sm/server/get-shard?service=baz", "bar-reader", "")
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+}
@@ -18,7 +18,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/entertainment-venue/sm/pkg/apputil"
@@ -33,15 +36,18 @@ import (
 
 type smAppSpec struct {
 	// Service 目前app的spec更多承担的是管理职能，shard配置的一个起点，先只配置上service，可以唯一标记一个app
-	Service string `json:"service"`
+	Service string `json:"service" yaml:"service"`
 
-	CreateTime int64 `json:"createTime"`
+	CreateTime int64 `json:"createTime" yaml:"createTime"`
 
-	// MaxShardCount 单container承载的最大分片数量，防止雪崩
-	MaxShardCount int `json:"maxShardCount"`
+	// MaxShardCount 单container承载的最大分片数量，防止雪崩，workerGroup平均分配后超出时不做rb
+	MaxShardCount int `json:"maxShardCount" yaml:"maxShardCount"`
 
-	// MaxRecoveryTime 遇到container删除的场景，等待的时间，超时认为该container被清理
-	MaxRecoveryTime int `json:"maxRecoveryTime"`
+	// MaxRecoveryTime 遇到container删除的场景，等待的时间（秒），超时认为该container被清理，不能超过 maxRecoveryWaitTime
+	MaxRecoveryTime int `json:"maxRecoveryTime" yaml:"maxRecoveryTime"`
+
+	// Receiver leader向container下发指令使用的协议，http（默认）或者grpc，拉模式的container不受影响
+	Receiver string `json:"receiver" yaml:"receiver"`
 }
 
 func (s *smAppSpec) String() string {
@@ -49,6 +55,22 @@ func (s *smAppSpec) String() string {
 	return string(b)
 }
 
+func (s *smAppSpec) Validate() error {
+	if s.MaxShardCount < 0 {
+		return errors.Errorf("invalid maxShardCount %d", s.MaxShardCount)
+	}
+	// 0表示使用默认值 defaultMaxRecoveryTime
+	if s.MaxRecoveryTime < 0 || time.Duration(s.MaxRecoveryTime)*time.Second > maxRecoveryWaitTime {
+		return errors.Errorf("invalid maxRecoveryTime %d, should be in [0, %d]", s.MaxRecoveryTime, int(maxRecoveryWaitTime/time.Second))
+	}
+	switch s.Receiver {
+	case "", receiverHTTP, receiverGRPC:
+		return nil
+	default:
+		return errors.Errorf("unknown receiver %s", s.Receiver)
+	}
+}
+
 type smShardApi struct {
 	container *smContainer
 }
@@ -75,6 +97,12 @@ func (ss *smShardApi) GinAddSpec(c *gin.Context) {
 	req.CreateTime = time.Now().Unix()
 	logutil.Info("receive add spec request", zap.Reflect("request", req))
 
+	if err := req.Validate(); err != nil {
+		logutil.Error("Validate err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// sm的service是保留service，在程序启动的时候初始化
 	if req.Service == ss.container.Service() {
 		err := errors.Errorf("Same as shard manager's service")
@@ -83,52 +111,138 @@ func (ss *smShardApi) GinAddSpec(c *gin.Context) {
 		return
 	}
 
-	//  写入app spec和app task节点在一个tx
+	nodes, values := ss.specNodes(&req)
+	if err := ss.container.Client.CreateAndGet(context.Background(), nodes, values, clientv3.NoLease); err != nil {
+		if err != etcdutil.ErrEtcdNodeExist {
+			logutil.Error("CreateAndGet err",
+				zap.Strings("nodes", nodes),
+				zap.Strings("values", values),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		logutil.Warn("CreateAndGet node exist",
+			zap.Strings("nodes", nodes),
+			zap.Strings("values", values),
+			zap.Error(err),
+		)
+	}
+	logutil.Info("add spec success", zap.String("service", req.Service))
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// GinUpdateSpec
+// @Description update spec, running leader shard applies the change without restart
+// @Tags  spec
+// @Accept  json
+// @Produce  json
+// @Param param body smAppSpec true "param"
+// @success 200
+// @Router /sm/server/update-spec [post]
+func (ss *smShardApi) GinUpdateSpec(c *gin.Context) {
+	var req smAppSpec
+	if err := c.ShouldBind(&req); err != nil {
+		logutil.Error("ShouldBind err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logutil.Info("receive update spec request", zap.Reflect("request", req))
+
+	if err := req.Validate(); err != nil {
+		logutil.Error("Validate err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Service == "" || req.Service == ss.container.Service() {
+		err := errors.Errorf("param error")
+		logutil.Error("service error", zap.String("service", req.Service), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := ss.container.nodeManager.ServiceSpecPath(req.Service)
+	resp, err := ss.container.Client.Get(context.TODO(), key)
+	if err != nil {
+		logutil.Error(
+			"Get error",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(resp.Kvs) == 0 {
+		err := errors.Errorf("service[%s] not exist", req.Service)
+		logutil.Error("service error", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var cur smAppSpec
+	if err := json.Unmarshal(resp.Kvs[0].Value, &cur); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// CreateTime 保持创建时的值
+	req.CreateTime = cur.CreateTime
+	if req == cur {
+		c.JSON(http.StatusOK, gin.H{})
+		return
+	}
+
+	tresp, err := ss.container.Client.CommitTxn(
+		context.TODO(),
+		[]clientv3.Cmp{clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)},
+		[]clientv3.Op{clientv3.OpPut(key, req.String())},
+	)
+	if err != nil {
+		logutil.Error(
+			"CommitTxn error",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !tresp.Succeeded {
+		c.JSON(http.StatusConflict, gin.H{"error": etcdutil.ErrEtcdValueNotMatch.Error()})
+		return
+	}
+	logutil.Info("update spec success", zap.Reflect("spec", req))
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// specNodes 添加service需要写入的节点，写入app spec和app task节点在一个tx
+func (ss *smShardApi) specNodes(spec *smAppSpec) ([]string, []string) {
 	var (
 		nodes  []string
 		values []string
 	)
 
 	// 业务节点的service放在sm的pfx下面
-	nodes = append(nodes, ss.container.nodeManager.ServiceSpecPath(req.Service))
-	values = append(values, req.String())
+	nodes = append(nodes, ss.container.nodeManager.ServiceSpecPath(spec.Service))
+	values = append(values, spec.String())
 
 	// 创建guard lease节点
-	nodes = append(nodes, ss.container.nodeManager.ExternalLeaseGuardPath(req.Service))
+	nodes = append(nodes, ss.container.nodeManager.ExternalLeaseGuardPath(spec.Service))
 	lease := storage.Lease{}
 	values = append(values, lease.String())
 
 	// 创建containerhb节点
-	nodes = append(nodes, ss.container.nodeManager.ExternalContainerHbDir(req.Service))
+	nodes = append(nodes, ss.container.nodeManager.ExternalContainerHbDir(spec.Service))
 	values = append(values, "")
 
 	// 需要将service注册到sm的spec中
-	t := shardTask{GovernedService: req.Service}
+	t := shardTask{GovernedService: spec.Service}
 	v := storage.ShardSpec{
 		Service:    ss.container.Service(),
 		Task:       t.String(),
 		UpdateTime: time.Now().Unix(),
 	}
-	nodes = append(nodes, ss.container.nodeManager.ShardPath(ss.container.Service(), req.Service))
+	nodes = append(nodes, ss.container.nodeManager.ShardPath(ss.container.Service(), spec.Service))
 	values = append(values, v.String())
-	if err := ss.container.Client.CreateAndGet(context.Background(), nodes, values, clientv3.NoLease); err != nil {
-		if err != etcdutil.ErrEtcdNodeExist {
-			logutil.Error("CreateAndGet err",
-				zap.Strings("nodes", nodes),
-				zap.Strings("values", values),
-				zap.Error(err),
-			)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		logutil.Warn("CreateAndGet node exist",
-			zap.Strings("nodes", nodes),
-			zap.Strings("values", values),
-			zap.Error(err),
-		)
-	}
-	logutil.Info("add spec success", zap.String("service", req.Service))
-	c.JSON(http.StatusOK, gin.H{})
+	return nodes, values
 }
 
 // GinDelSpec
@@ -189,19 +303,28 @@ func (ss *smShardApi) GinDelSpec(c *gin.Context) {
 // @success 200
 // @Router /sm/server/get-spec [get]
 func (ss *smShardApi) GinGetSpec(c *gin.Context) {
-	pfx := ss.container.nodeManager.ShardDir(ss.container.Service())
-	kvs, err := ss.container.Client.GetKVs(context.Background(), pfx)
+	services, err := loadServices(context.Background(), ss.container.Client, ss.container.nodeManager)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	logutil.Info("get all service success")
+	c.JSON(http.StatusOK, gin.H{"services": services})
+}
+
+// loadServices 接入sm的service，sm自身的service也包含在内
+func loadServices(ctx context.Context, client etcdutil.EtcdWrapper, nodeManager *nodeManager) ([]string, error) {
+	pfx := nodeManager.ShardDir(nodeManager.smService)
+	kvs, err := client.GetKVs(ctx, pfx)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
 	var services []string
 	for k := range kvs {
 		services = append(services, k)
 	}
-	services = append(services, ss.container.Service())
-	logutil.Info("get all service success")
-	c.JSON(http.StatusOK, gin.H{"services": services})
+	services = append(services, nodeManager.smService)
+	return services, nil
 }
 
 type addShardRequest struct {
@@ -301,6 +424,122 @@ func (ss *smShardApi) GinAddShard(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{})
 }
 
+type updateShardRequest struct {
+	ShardId string `json:"shardId" binding:"required"`
+
+	Service string `json:"service" binding:"required"`
+
+	Task string `json:"task"`
+
+	ManualContainerId string `json:"manualContainerId"`
+
+	Group string `json:"group"`
+
+	WorkerGroup string `json:"workerGroup"`
+
+	// Revision 读取shard时的ModRevision，不为0时要求shard在此期间没有被修改
+	Revision int64 `json:"revision"`
+}
+
+func (r *updateShardRequest) String() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+// GinUpdateShard
+// @Description update shard, shard which only task changed will be updated in place
+// @Tags  shard
+// @Accept  json
+// @Produce  json
+// @Param param body updateShardRequest true "param"
+// @success 200
+// @Router /sm/server/update-shard [post]
+func (ss *smShardApi) GinUpdateShard(c *gin.Context) {
+	var req updateShardRequest
+	if err := c.ShouldBind(&req); err != nil {
+		logutil.Error("ShouldBind err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logutil.Info("update shard request", zap.Reflect("req", req))
+
+	if req.Service == ss.container.Service() {
+		err := errors.Errorf("same as shard manager's service")
+		logutil.Error("service error", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pfx := ss.container.nodeManager.ShardPath(req.Service, req.ShardId)
+	resp, err := ss.container.Client.Get(context.TODO(), pfx)
+	if err != nil {
+		logutil.Error(
+			"Get error",
+			zap.String("pfx", pfx),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(resp.Kvs) == 0 {
+		logutil.Error(
+			"shard not exist",
+			zap.Reflect("req", req),
+			zap.String("pfx", pfx),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errShardNotExist.Error()})
+		return
+	}
+	revision := resp.Kvs[0].ModRevision
+	if req.Revision != 0 && req.Revision != revision {
+		logutil.Warn(
+			"revision not match",
+			zap.Reflect("req", req),
+			zap.Int64("revision", revision),
+		)
+		c.JSON(http.StatusConflict, gin.H{"error": etcdutil.ErrEtcdValueNotMatch.Error(), "revision": revision})
+		return
+	}
+
+	spec := storage.ShardSpec{
+		Service:           req.Service,
+		Task:              req.Task,
+		UpdateTime:        time.Now().Unix(),
+		ManualContainerId: req.ManualContainerId,
+		Group:             req.Group,
+		WorkerGroup:       req.WorkerGroup,
+	}
+	// 通过revision做乐观锁，owner的变化交给balanceChecker，只有Task变化时原地更新
+	cmps := []clientv3.Cmp{clientv3.Compare(clientv3.ModRevision(pfx), "=", revision)}
+	ops := []clientv3.Op{clientv3.OpPut(pfx, spec.String())}
+	tresp, err := ss.container.Client.CommitTxn(context.TODO(), cmps, ops)
+	if err != nil {
+		logutil.Error(
+			"CommitTxn error",
+			zap.String("pfx", pfx),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !tresp.Succeeded {
+		logutil.Warn(
+			"shard changed concurrently",
+			zap.Reflect("req", req),
+			zap.Int64("revision", revision),
+		)
+		c.JSON(http.StatusConflict, gin.H{"error": etcdutil.ErrEtcdValueNotMatch.Error()})
+		return
+	}
+
+	logutil.Info(
+		"update shard success",
+		zap.Reflect("req", req),
+		zap.Int64("revision", tresp.Header.Revision),
+	)
+	c.JSON(http.StatusOK, gin.H{"revision": tresp.Header.Revision})
+}
+
 type delShardRequest struct {
 	ShardId string `json:"shardId" binding:"required"`
 	Service string `json:"service" binding:"required"`
@@ -319,7 +558,6 @@ func (r *delShardRequest) String() string {
 // @Param param body delShardRequest true "param"
 // @success 200
 // @Router /sm/server/del-shard [post]
-// GinDelShard TODO ACL 需要带着key过来做分片的移动，防止跨租户之间有影响
 func (ss *smShardApi) GinDelShard(c *gin.Context) {
 	var req delShardRequest
 	if err := c.ShouldBind(&req); err != nil {
@@ -400,50 +638,285 @@ func (ss *smShardApi) GinGetShard(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"shards": shards})
 }
 
-type workerRequest struct {
-	// 在哪个资源组下面添加worker
-	WorkerGroup string `json:"workerGroup" binding:"required"`
+type batchShardItem struct {
+	ShardId string `json:"shardId" yaml:"shardId"`
 
-	// 为哪个业务app增加worker
+	// 业务app自己定义task内容
+	Task string `json:"task" yaml:"task"`
+
+	ManualContainerId string `json:"manualContainerId" yaml:"manualContainerId"`
+
+	Group string `json:"group" yaml:"group"`
+
+	WorkerGroup string `json:"workerGroup" yaml:"workerGroup"`
+}
+
+type batchShardRequest struct {
 	Service string `json:"service" binding:"required"`
 
-	// 需要添加的资源，添加后，shard中如果存在WorkerGroup，只会将shard分配到该WorkerGroup下的worker中。
-	Worker string `json:"worker" binding:"required"`
+	Shards []*batchShardItem `json:"shards" binding:"required"`
 }
 
-// GinAddWorker
-// @Description add service worker
-// @Tags  worker
+func (r *batchShardRequest) String() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+type batchDelShardRequest struct {
+	Service string `json:"service" binding:"required"`
+
+	ShardIds []string `json:"shardIds" binding:"required"`
+}
+
+func (r *batchDelShardRequest) String() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+// GinBatchAddShard
+// @Description batch add shard
+// @Tags  shard
 // @Accept  json
 // @Produce  json
-// @Param param body workerRequest true "param"
-// @success 200
-// @Router /sm/server/add-worker [post]
-func (ss *smShardApi) GinAddWorker(c *gin.Context) {
-	var req workerRequest
+// @Param param body batchShardRequest true "param"
+// @success 200 {object} batchShardResponse
+// @Router /sm/server/batch-add-shard [post]
+func (ss *smShardApi) GinBatchAddShard(c *gin.Context) {
+	ss.ginBatchPutShard(c, batchActionAdd)
+}
+
+// GinBatchUpdateShard
+// @Description batch update shard
+// @Tags  shard
+// @Accept  json
+// @Produce  json
+// @Param param body batchShardRequest true "param"
+// @success 200 {object} batchShardResponse
+// @Router /sm/server/batch-update-shard [post]
+func (ss *smShardApi) GinBatchUpdateShard(c *gin.Context) {
+	ss.ginBatchPutShard(c, batchActionUpdate)
+}
+
+func (ss *smShardApi) ginBatchPutShard(c *gin.Context, action string) {
+	var req batchShardRequest
 	if err := c.ShouldBind(&req); err != nil {
 		logutil.Error("ShouldBind err", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	logutil.Info(
-		"add worker request",
-		zap.Reflect("req", req),
+		"batch shard request",
+		zap.String("action", action),
+		zap.String("service", req.Service),
+		zap.Int("size", len(req.Shards)),
 	)
 
-	// 检查是否存在该service
-	resp, err := ss.container.Client.GetKV(context.Background(), ss.container.nodeManager.ServiceSpecPath(req.Service), nil)
-	if err != nil {
-		logutil.Error("GetKV error",
-			zap.String("service node", ss.container.nodeManager.ServiceSpecPath(req.Service)),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	now := time.Now().Unix()
+	ops := make([]*batchShardOp, 0, len(req.Shards))
+	for _, item := range req.Shards {
+		if item == nil {
+			continue
+		}
+		spec := storage.ShardSpec{
+			Service:           req.Service,
+			Task:              item.Task,
+			UpdateTime:        now,
+			ManualContainerId: item.ManualContainerId,
+			Group:             item.Group,
+			WorkerGroup:       item.WorkerGroup,
+		}
+		ops = append(ops, &batchShardOp{ShardId: item.ShardId, Value: spec.String()})
 	}
-	if resp.Count == 0 {
-		logutil.Warn("service not exist", zap.String("service", req.Service))
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("service[%s] not exist", req.Service)})
+	ss.applyShardBatch(c, req.Service, action, ops)
+}
+
+// GinBatchDelShard
+// @Description batch del shard
+// @Tags  shard
+// @Accept  json
+// @Produce  json
+// @Param param body batchDelShardRequest true "param"
+// @success 200 {object} batchShardResponse
+// @Router /sm/server/batch-del-shard [post]
+func (ss *smShardApi) GinBatchDelShard(c *gin.Context) {
+	var req batchDelShardRequest
+	if err := c.ShouldBind(&req); err != nil {
+		logutil.Error("ShouldBind err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logutil.Info(
+		"batch del shard request",
+		zap.String("service", req.Service),
+		zap.Int("size", len(req.ShardIds)),
+	)
+
+	ops := make([]*batchShardOp, 0, len(req.ShardIds))
+	for _, shardId := range req.ShardIds {
+		ops = append(ops, &batchShardOp{ShardId: shardId})
+	}
+	ss.applyShardBatch(c, req.Service, batchActionDel, ops)
+}
+
+func (ss *smShardApi) applyShardBatch(c *gin.Context, service string, action string, ops []*batchShardOp) {
+	// sm本身的shard是和service添加绑定的，不需要走这个接口
+	if service == ss.container.Service() {
+		err := errors.Errorf("same as shard manager's service")
+		logutil.Error("service error", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	batch := shardBatch{container: ss.container, service: service, action: action}
+	if err := batch.validate(ops); err != nil {
+		logutil.Error(
+			"batch invalid",
+			zap.String("service", service),
+			zap.String("action", action),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 检查是否存在该service
+	resp, err := ss.container.Client.GetKV(context.TODO(), ss.container.nodeManager.ServiceSpecPath(service), nil)
+	if err != nil {
+		logutil.Error("GetKV error",
+			zap.Error(err),
+			zap.String("service node", ss.container.nodeManager.ServiceSpecPath(service)),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if resp.Count == 0 {
+		err := errors.Errorf("service[%s] not exist", service)
+		logutil.Error("service error", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := batch.apply(context.TODO(), ops)
+	if err != nil {
+		logutil.Error(
+			"apply batch error",
+			zap.String("service", service),
+			zap.String("action", action),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GinApply
+// @Description apply service manifest, only changes are committed, shards and workers not in manifest are deleted when prune is set
+// @Tags  spec
+// @Accept  json,application/x-yaml
+// @Produce  json
+// @Param param body serviceManifest true "param"
+// @Param dryRun query bool false "only output diff"
+// @Param prune query bool false "delete shards and workers not in manifest"
+// @success 200 {object} manifestDiff
+// @Router /sm/server/apply [post]
+func (ss *smShardApi) GinApply(c *gin.Context) {
+	var manifest serviceManifest
+	if err := c.ShouldBind(&manifest); err != nil {
+		logutil.Error("ShouldBind err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	prune, _ := strconv.ParseBool(c.Query("prune"))
+	logutil.Info(
+		"apply request",
+		zap.String("service", manifest.Spec.Service),
+		zap.Int("shards", len(manifest.Shards)),
+		zap.Bool("dryRun", dryRun),
+		zap.Bool("prune", prune),
+	)
+
+	applier := newManifestApplier(ss, &manifest, prune)
+	if err := applier.validate(); err != nil {
+		logutil.Error(
+			"manifest invalid",
+			zap.String("service", manifest.Spec.Service),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := applier.plan(context.TODO()); err != nil {
+		logutil.Error(
+			"plan error",
+			zap.String("service", manifest.Spec.Service),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if dryRun {
+		applier.diff.DryRun = true
+	} else {
+		applier.apply(context.TODO())
+	}
+
+	diff := applier.summary()
+	logutil.Info(
+		"apply complete",
+		zap.String("service", manifest.Spec.Service),
+		zap.Bool("dryRun", dryRun),
+		zap.Int("changes", len(diff.Changes)),
+		zap.Int("failed", diff.Failed),
+	)
+	c.JSON(http.StatusOK, diff)
+}
+
+type workerRequest struct {
+	// 在哪个资源组下面添加worker
+	WorkerGroup string `json:"workerGroup" binding:"required"`
+
+	// 为哪个业务app增加worker
+	Service string `json:"service" binding:"required"`
+
+	// 需要添加的资源，添加后，shard中如果存在WorkerGroup，只会将shard分配到该WorkerGroup下的worker中。
+	Worker string `json:"worker" binding:"required"`
+}
+
+// GinAddWorker
+// @Description add service worker
+// @Tags  worker
+// @Accept  json
+// @Produce  json
+// @Param param body workerRequest true "param"
+// @success 200
+// @Router /sm/server/add-worker [post]
+func (ss *smShardApi) GinAddWorker(c *gin.Context) {
+	var req workerRequest
+	if err := c.ShouldBind(&req); err != nil {
+		logutil.Error("ShouldBind err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logutil.Info(
+		"add worker request",
+		zap.Reflect("req", req),
+	)
+
+	// 检查是否存在该service
+	resp, err := ss.container.Client.GetKV(context.Background(), ss.container.nodeManager.ServiceSpecPath(req.Service), nil)
+	if err != nil {
+		logutil.Error("GetKV error",
+			zap.String("service node", ss.container.nodeManager.ServiceSpecPath(req.Service)),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if resp.Count == 0 {
+		logutil.Warn("service not exist", zap.String("service", req.Service))
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("service[%s] not exist", req.Service)})
 		return
 	}
 
@@ -550,6 +1023,104 @@ func (ss *smShardApi) GinGetWorker(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"workers": result})
 }
 
+type drainRequest struct {
+	Service string `json:"service" binding:"required"`
+
+	// Container 需要摘除的container，摘除后不再分配shard，已有的shard会被迁移到其他container
+	Container string `json:"container" binding:"required"`
+}
+
+// GinDrainContainer
+// @Description drain container, shards on the container will be moved to others
+// @Tags  worker
+// @Accept  json
+// @Produce  json
+// @Param param body drainRequest true "param"
+// @success 200
+// @Router /sm/server/drain-container [post]
+func (ss *smShardApi) GinDrainContainer(c *gin.Context) {
+	var req drainRequest
+	if err := c.ShouldBind(&req); err != nil {
+		logutil.Error("ShouldBind err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logutil.Info(
+		"drain container request",
+		zap.Reflect("req", req),
+	)
+
+	// 检查是否存在该service
+	resp, err := ss.container.Client.GetKV(context.Background(), ss.container.nodeManager.ServiceSpecPath(req.Service), nil)
+	if err != nil {
+		logutil.Error("GetKV error",
+			zap.String("service node", ss.container.nodeManager.ServiceSpecPath(req.Service)),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if resp.Count == 0 {
+		logutil.Warn("service not exist", zap.String("service", req.Service))
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("service[%s] not exist", req.Service)})
+		return
+	}
+
+	// 重复摘除是幂等的
+	pfx := ss.container.nodeManager.DrainPath(req.Service, req.Container)
+	if _, err := ss.container.Client.Put(context.TODO(), pfx, ""); err != nil {
+		logutil.Error("Put error",
+			zap.String("pfx", pfx),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logutil.Info(
+		"drain container success",
+		zap.Reflect("req", req),
+		zap.String("pfx", pfx),
+	)
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// GinUndrainContainer
+// @Description undrain container, the container can be assigned shards again
+// @Tags  worker
+// @Accept  json
+// @Produce  json
+// @Param param body drainRequest true "param"
+// @success 200
+// @Router /sm/server/undrain-container [post]
+func (ss *smShardApi) GinUndrainContainer(c *gin.Context) {
+	var req drainRequest
+	if err := c.ShouldBind(&req); err != nil {
+		logutil.Error("ShouldBind err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logutil.Info(
+		"undrain container request",
+		zap.Reflect("req", req),
+	)
+
+	pfx := ss.container.nodeManager.DrainPath(req.Service, req.Container)
+	if _, err := ss.container.Client.Delete(context.TODO(), pfx); err != nil {
+		logutil.Error("Delete err",
+			zap.String("pfx", pfx),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logutil.Info(
+		"undrain container success",
+		zap.Reflect("req", req),
+		zap.String("pfx", pfx),
+	)
+	c.JSON(http.StatusOK, gin.H{})
+}
+
 type serviceDetail struct {
 	Spec              *smAppSpec                    `json:"spec"`
 	ShardSpec         map[string]*storage.ShardSpec `json:"shardSpec"`
@@ -557,41 +1128,35 @@ type serviceDetail struct {
 	Allocate          map[string][]string           `json:"allocate"`
 	AliveContainers   []string                      `json:"aliveContainers"`
 	NotAllocateShards []string                      `json:"notAllocateShards"`
+	DrainedContainers []string                      `json:"drainedContainers"`
+	// ShardState container心跳上报的shard生命周期状态，旧版本的container不上报
+	ShardState map[string]storage.ShardState `json:"shardState"`
+	// ShardLoad container心跳上报的shard负载，app没有实现时不存在
+	ShardLoad map[string]*storage.ShardLoad `json:"shardLoad"`
 }
 
-// GinServiceDetail
-// @Description get service detail
-// @Tags  service
-// @Accept  json
-// @Produce  json
-// @Param service query string true "param"
-// @success 200
-// @Router /sm/server/detail [get]
-func (ss *smShardApi) GinServiceDetail(c *gin.Context) {
-	service := c.Query("service")
-	if service == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "service must not empty"})
-		return
-	}
-	result := serviceDetail{Spec: &smAppSpec{}, ShardSpec: make(map[string]*storage.ShardSpec), WorkerGroup: make(map[string][]string), Allocate: make(map[string][]string)}
+// errServiceNotExist 查询的service没有spec
+var errServiceNotExist = errors.New("service not exist")
+
+// loadServiceDetail 从etcd汇总service的配置、分片以及分配情况，sm不可用时 Inspector 也使用这里的逻辑
+func loadServiceDetail(ctx context.Context, client etcdutil.EtcdWrapper, nodeManager *nodeManager, service string) (*serviceDetail, error) {
+	result := serviceDetail{Spec: &smAppSpec{}, ShardSpec: make(map[string]*storage.ShardSpec), WorkerGroup: make(map[string][]string), Allocate: make(map[string][]string), ShardState: make(map[string]storage.ShardState), ShardLoad: make(map[string]*storage.ShardLoad)}
 
 	// 1.获取service的配置信息
 	// /sm/app/foo.bar/service/worker-test.dev/spec
 	// {"service":"worker-test.dev","createTime":1655707418,"maxShardCount":0,"maxRecoveryTime":0}
-	pfx := ss.container.nodeManager.ServiceSpecPath(service)
-	resp, err := ss.container.Client.GetKV(context.Background(), pfx, nil)
+	pfx := nodeManager.ServiceSpecPath(service)
+	resp, err := client.GetKV(ctx, pfx, nil)
 	if err != nil {
 		logutil.Error("GetKV error",
 			zap.String("service node", pfx),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, errors.Wrap(err, "")
 	}
 	if resp.Count == 0 {
 		logutil.Warn("service not exist", zap.String("service", service))
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("service[%s] not exist", service)})
-		return
+		return nil, errServiceNotExist
 	}
 	if string(resp.Kvs[0].Value) == "" {
 		logutil.Error(
@@ -599,8 +1164,7 @@ func (ss *smShardApi) GinServiceDetail(c *gin.Context) {
 			zap.String("service", service),
 			zap.String("content", string(resp.Kvs[0].Value)),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "service spec empty"})
-		return
+		return nil, errors.New("service spec empty")
 	}
 	if err := json.Unmarshal(resp.Kvs[0].Value, result.Spec); err != nil {
 		logutil.Error(
@@ -609,22 +1173,20 @@ func (ss *smShardApi) GinServiceDetail(c *gin.Context) {
 			zap.String("content", string(resp.Kvs[0].Value)),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, errors.Wrap(err, "")
 	}
 
 	// 2.获取分片信息
 	// /sm/app/foo.bar/service/worker-test.dev/shard/task-A
 	// {"id":"","service":"worker-test.dev","task":"":"","group":"","WorkerGroup":"g2","lease":null}
-	pfx = ss.container.nodeManager.ShardDir(service)
-	resp1, err := ss.container.Client.GetKVs(context.Background(), pfx)
+	pfx = nodeManager.ShardDir(service)
+	resp1, err := client.GetKVs(ctx, pfx)
 	if err != nil {
 		logutil.Error("GetKVs error",
 			zap.String("service node", pfx),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, errors.Wrap(err, "")
 	}
 	for shardId, shardSpec := range resp1 {
 		sp := &storage.ShardSpec{}
@@ -638,68 +1200,87 @@ func (ss *smShardApi) GinServiceDetail(c *gin.Context) {
 				zap.String("content", shardSpec),
 				zap.Error(err),
 			)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return nil, errors.Wrap(err, "")
 		}
 		result.ShardSpec[shardId] = sp
 	}
 
 	// 3.获取workerGroup信息
 	// /sm/app/foo.bar/service/worker-test.dev/workerpool/g1/127.0.0.1:9100
-	pfx = ss.container.nodeManager.WorkerGroupPath(service)
-	resp2, err := ss.container.Client.Get(context.TODO(), pfx, clientv3.WithPrefix())
+	pfx = nodeManager.WorkerGroupPath(service)
+	resp2, err := client.Get(ctx, pfx, clientv3.WithPrefix())
 	if err != nil {
 		logutil.Error(
 			"Get error",
 			zap.String("service node", pfx),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, errors.Wrap(err, "")
 	}
 	for _, kv := range resp2.Kvs {
-		wGroup, container := ss.container.nodeManager.parseWorkerGroupAndContainer(string(kv.Key))
+		wGroup, container := nodeManager.parseWorkerGroupAndContainer(string(kv.Key))
 		result.WorkerGroup[wGroup] = append(result.WorkerGroup[wGroup], container)
 	}
 
 	// 4.获取container上的shard分配信息
 	// /sm/app/foo.bar/containerhb/127.0.0.1:8801/694d818416078d06
 	// {"shards":[{"spec":{"id":"worker-test.dev","service":"foo.bar","task":"{\"governedService\":\"worker-test.dev\"}","updateTime":1655707418,"manualContainerId":"","group":"","workerGroup":"","lease":{"id":7587863351494413604,"expire":1655794762}},"disp":true,"drop":false}]}
-	pfx = ss.container.nodeManager.ExternalContainerHbDir(service)
-	resp3, err := ss.container.Client.Get(context.TODO(), pfx, clientv3.WithPrefix())
+	pfx = nodeManager.ExternalContainerHbDir(service)
+	resp3, err := client.Get(ctx, pfx, clientv3.WithPrefix())
 	if err != nil {
 		logutil.Error(
 			"Get error",
 			zap.String("service node", pfx),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, errors.Wrap(err, "")
 	}
 	allocateShard := map[string]string{}
 	for _, kv := range resp3.Kvs {
-		container := ss.container.nodeManager.parseContainer(string(kv.Key))
+		container := nodeManager.parseContainer(string(kv.Key))
 		if string(kv.Value) == "" {
 			continue
 		}
 		result.AliveContainers = append(result.AliveContainers, container)
-		info := &apputil.ContainerHeartbeat{}
-		if err := json.Unmarshal(kv.Value, &info); err != nil {
+		info, err := apputil.DecodeContainerHeartbeat(kv.Value)
+		if err != nil {
 			logutil.Error(
-				"json unmarshal error",
+				"heartbeat decode error",
 				zap.String("service", service),
 				zap.String("content", string(kv.Value)),
 				zap.Error(err),
 			)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return nil, errors.Wrap(err, "")
 		}
-		if info.Shards != nil {
-			for _, shard := range info.Shards {
-				if shard.Disp {
-					result.Allocate[container] = append(result.Allocate[container], shard.Spec.Id)
-					allocateShard[shard.Spec.Id] = ""
-				}
+		if info.IsDelta() {
+			snapshot, err := getHeartbeatSnapshot(ctx, client, string(kv.Key), info.BaseRevision)
+			if err == nil {
+				err = info.Merge(snapshot)
+			}
+			if err != nil {
+				logutil.Error(
+					"heartbeat merge error",
+					zap.String("service", service),
+					zap.ByteString("key", kv.Key),
+					zap.Error(err),
+				)
+				return nil, errors.Wrap(err, "")
+			}
+		}
+		for _, shard := range info.ShardSummaries() {
+			if shard.Disp {
+				result.Allocate[container] = append(result.Allocate[container], shard.Id)
+				allocateShard[shard.Id] = ""
+			}
+			// rb过程中shard可能同时出现在两个container，以没有被drop的为准
+			if shard.Drop {
+				continue
+			}
+			if shard.State != "" {
+				result.ShardState[shard.Id] = shard.State
+			}
+			if shard.Load != nil {
+				result.ShardLoad[shard.Id] = shard.Load
 			}
 		}
 	}
@@ -709,9 +1290,291 @@ func (ss *smShardApi) GinServiceDetail(c *gin.Context) {
 			result.NotAllocateShards = append(result.NotAllocateShards, shard)
 		}
 	}
+
+	// 5.获取被摘除的container
+	// /sm/app/foo.bar/service/worker-test.dev/drain/127.0.0.1:8801
+	pfx = nodeManager.DrainDir(service)
+	resp4, err := client.GetKVs(ctx, pfx)
+	if err != nil {
+		logutil.Error("GetKVs error",
+			zap.String("service node", pfx),
+			zap.Error(err),
+		)
+		return nil, errors.Wrap(err, "")
+	}
+	for container := range resp4 {
+		result.DrainedContainers = append(result.DrainedContainers, container)
+	}
+	return &result, nil
+}
+
+// GinServiceDetail
+// @Description get service detail
+// @Tags  service
+// @Accept  json
+// @Produce  json
+// @Param service query string true "param"
+// @success 200
+// @Router /sm/server/detail [get]
+func (ss *smShardApi) GinServiceDetail(c *gin.Context) {
+	service := c.Query("service")
+	if service == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service must not empty"})
+		return
+	}
+	result, err := loadServiceDetail(context.TODO(), ss.container.Client, ss.container.nodeManager, service)
+	if err != nil {
+		if err == errServiceNotExist {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("service[%s] not exist", service)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, result)
 }
 
+type resignRequest struct {
+	// Successor 提名的继任container，需要在竞选队列中，为空时由election的顺序决定
+	Successor string `json:"successor"`
+
+	// Timeout 等待进行中rb结束的时间，单位s，超时后中断rb
+	Timeout int `json:"timeout"`
+}
+
+// GinResign
+// @Description leader resign
+// @Tags  server
+// @Accept  json
+// @Produce  json
+// @Param param body resignRequest false "param"
+// @success 200
+// @Router /sm/server/resign [post]
+func (ss *smShardApi) GinResign(c *gin.Context) {
+	var req resignRequest
+	if err := c.ShouldBind(&req); err != nil && err != io.EOF {
+		logutil.Error("ShouldBind err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logutil.Info("resign request", zap.Reflect("req", req))
+
+	timeout := defaultResignTimeout
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := ss.container.Resign(ctx, req.Successor); err != nil {
+		logutil.Error(
+			"Resign error",
+			zap.Reflect("req", req),
+			zap.Error(err),
+		)
+		if err == errNotLeader || err == errSuccessorNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+type aclPrincipalRequest struct {
+	Name string `json:"name" binding:"required"`
+
+	// Tokens 明文token，只用于计算hash，不会写入etcd
+	Tokens []string `json:"tokens"`
+
+	// Identities mTLS客户端证书的CommonName
+	Identities []string `json:"identities"`
+
+	// Roles service到角色（read、write、admin）的映射，"*"对所有service生效
+	Roles map[string]string `json:"roles" binding:"required"`
+}
+
+// GinSetPrincipal
+// @Description add or replace acl principal
+// @Tags  acl
+// @Accept  json
+// @Produce  json
+// @Param param body aclPrincipalRequest true "param"
+// @success 200
+// @Router /sm/server/acl/set-principal [post]
+func (ss *smShardApi) GinSetPrincipal(c *gin.Context) {
+	var req aclPrincipalRequest
+	if err := c.ShouldBind(&req); err != nil {
+		logutil.Error("ShouldBind err", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 请求中包含token明文，不能直接打印请求
+	logutil.Info(
+		"set principal request",
+		zap.String("name", req.Name),
+		zap.Strings("identities", req.Identities),
+		zap.Reflect("roles", req.Roles),
+	)
+
+	if strings.Contains(req.Name, "/") || req.Name == aclBootstrapPrincipal {
+		err := errors.Errorf("param error")
+		logutil.Error("name error", zap.String("name", req.Name), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Tokens) == 0 && len(req.Identities) == 0 {
+		err := errors.Errorf("tokens or identities required")
+		logutil.Error("param error", zap.String("name", req.Name), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for service, role := range req.Roles {
+		if _, ok := aclRoleLevels[role]; !ok || service == "" {
+			err := errors.Errorf("role error")
+			logutil.Error(
+				"role error",
+				zap.String("service", service),
+				zap.String("role", role),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	principal := aclPrincipal{
+		Name:       req.Name,
+		Identities: req.Identities,
+		Roles:      req.Roles,
+	}
+	for _, token := range req.Tokens {
+		if token == "" {
+			continue
+		}
+		principal.TokenHashes = append(principal.TokenHashes, aclTokenHash(token))
+	}
+	if _, err := ss.container.Client.Put(context.TODO(), ss.container.nodeManager.ACLPrincipalPath(req.Name), principal.String()); err != nil {
+		logutil.Error(
+			"Put error",
+			zap.String("name", req.Name),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// GinDelPrincipal
+// @Description del acl principal
+// @Tags  acl
+// @Produce  json
+// @Param name query string true "param"
+// @success 200
+// @Router /sm/server/acl/del-principal [get]
+func (ss *smShardApi) GinDelPrincipal(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" || strings.Contains(name, "/") {
+		err := errors.Errorf("param error")
+		logutil.Error("name error", zap.String("name", name), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := ss.container.Client.Delete(context.TODO(), ss.container.nodeManager.ACLPrincipalPath(name))
+	if err != nil {
+		logutil.Error(
+			"Delete error",
+			zap.String("name", name),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if resp.Deleted == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "principal not exist"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// GinGetPrincipal
+// @Description get all acl principals
+// @Tags  acl
+// @Produce  json
+// @success 200 {array} aclPrincipal
+// @Router /sm/server/acl/get-principal [get]
+func (ss *smShardApi) GinGetPrincipal(c *gin.Context) {
+	pfx := ss.container.nodeManager.ACLPrincipalDir()
+	resp, err := ss.container.Client.Get(context.TODO(), pfx, clientv3.WithPrefix())
+	if err != nil {
+		logutil.Error(
+			"Get error",
+			zap.String("pfx", pfx),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	principals := make([]*aclPrincipal, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var p aclPrincipal
+		if err := json.Unmarshal(kv.Value, &p); err != nil {
+			logutil.Error(
+				"Unmarshal error",
+				zap.String("key", string(kv.Key)),
+				zap.Error(err),
+			)
+			continue
+		}
+		principals = append(principals, &p)
+	}
+	c.JSON(http.StatusOK, principals)
+}
+
+// GinLeader
+// @Description get sm leader, clients use it to send leader only requests
+// @Tags  server
+// @Produce  json
+// @success 200 {object} leaderEtcdValue
+// @Router /sm/server/leader [get]
+func (ss *smShardApi) GinLeader(c *gin.Context) {
+	leader, err := loadLeader(context.TODO(), ss.container.Client, ss.container.nodeManager)
+	if err != nil {
+		if err == errNotLeader {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errNotLeader.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, leader)
+}
+
+// loadLeader 没有leader时返回 errNotLeader
+func loadLeader(ctx context.Context, client etcdutil.EtcdWrapper, nodeManager *nodeManager) (*leaderEtcdValue, error) {
+	// 和concurrency.Election的Leader方法一致，最早创建的key是leader
+	pfx := nodeManager.LeaderPath() + "/"
+	resp, err := client.Get(ctx, pfx, clientv3.WithFirstCreate()...)
+	if err != nil {
+		logutil.Error(
+			"Get error",
+			zap.String("pfx", pfx),
+			zap.Error(err),
+		)
+		return nil, errors.Wrap(err, "")
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, errNotLeader
+	}
+	var leader leaderEtcdValue
+	if err := json.Unmarshal(resp.Kvs[0].Value, &leader); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return &leader, nil
+}
+
 func (ss *smShardApi) GinHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"msg": "success"})
 }
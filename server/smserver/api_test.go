@@ -2,6 +2,7 @@ package smserver
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,12 +10,15 @@ import (
 	"time"
 
 	"github.com/entertainment-venue/sm/pkg/apputil"
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
 	"github.com/entertainment-venue/sm/pkg/commonutil"
 	"github.com/entertainment-venue/sm/pkg/etcdutil"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
@@ -39,7 +43,7 @@ func (suite *ApiTestSuite) SetupTest() {
 		stopper:   &commonutil.GoroutineStopper{},
 		shards:    make(map[string]Shard),
 
-		nodeManager: &nodeManager{"foo"},
+		nodeManager: newNodeManager("foo", etcdutil.NewPathBuilder("")),
 	}
 	suite.container.SetService("foo")
 
@@ -73,6 +77,20 @@ func (suite *ApiTestSuite) TestGinAddSpec_sameService() {
 	assert.Equal(suite.T(), w.Code, http.StatusBadRequest)
 }
 
+func (suite *ApiTestSuite) TestGinAddSpec_unknownReceiver() {
+	spec := smAppSpec{
+		Service:  "serviceA",
+		Receiver: "thrift",
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/add-spec", bytes.NewBuffer([]byte(spec.String())))
+	req.Header.Add("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+	assert.Equal(suite.T(), w.Code, http.StatusBadRequest)
+}
+
 func (suite *ApiTestSuite) TestGinAddSpec_success() {
 	// mock
 	var nodes []string
@@ -287,3 +305,323 @@ func (suite *ApiTestSuite) TestGinGetShard_success() {
 	suite.testRouter.ServeHTTP(w, req)
 	assert.Equal(suite.T(), w.Code, http.StatusOK)
 }
+
+func (suite *ApiTestSuite) TestGinResign_notLeader() {
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/resign", nil)
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+	assert.Equal(suite.T(), w.Code, http.StatusBadRequest)
+}
+
+func (suite *ApiTestSuite) TestGinBatchAddShard_duplicated() {
+	batchReq := batchShardRequest{
+		Service: "serviceA",
+		Shards:  []*batchShardItem{{ShardId: "s1"}, {ShardId: "s1"}},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/batch-add-shard", bytes.NewBuffer([]byte(batchReq.String())))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+	assert.Equal(suite.T(), w.Code, http.StatusBadRequest)
+}
+
+func (suite *ApiTestSuite) TestGinBatchAddShard_chunked() {
+	batchReq := batchShardRequest{Service: "serviceA"}
+	for i := 0; i < etcdutil.DefaultMaxTxnOps*2+1; i++ {
+		batchReq.Shards = append(batchReq.Shards, &batchShardItem{ShardId: fmt.Sprintf("s%d", i)})
+	}
+
+	// s0已经存在，剩余的shard分2个txn提交
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("GetKV", mock.Anything, "/sm/app/foo/service/serviceA/spec", mock.Anything).Return(&clientv3.GetResponse{Count: 1}, nil)
+	mockedEtcdWrapper.On("Get", mock.Anything, "/sm/app/foo/service/serviceA/shard/", mock.Anything).Return(
+		&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Key: []byte("/sm/app/foo/service/serviceA/shard/s0"), ModRevision: 1}}},
+		nil,
+	)
+	mockedEtcdWrapper.On("CommitTxn", mock.Anything, mock.Anything, mock.Anything).Return(&clientv3.TxnResponse{Succeeded: true}, nil).Times(2)
+	suite.container.Client = mockedEtcdWrapper
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/batch-add-shard", bytes.NewBuffer([]byte(batchReq.String())))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+
+	mockedEtcdWrapper.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), w.Code, http.StatusOK)
+	var resp batchShardResponse
+	assert.Nil(suite.T(), json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(suite.T(), etcdutil.DefaultMaxTxnOps*2, resp.Succeeded)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), errShardExist.Error(), resp.Results[0].Error)
+}
+
+func (suite *ApiTestSuite) TestGinBatchDelShard_conflict() {
+	batchReq := batchDelShardRequest{Service: "serviceA", ShardIds: []string{"s1", "s2"}}
+
+	// 整段提交失败后逐个重试，只有s2被并发修改
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("GetKV", mock.Anything, "/sm/app/foo/service/serviceA/spec", mock.Anything).Return(&clientv3.GetResponse{Count: 1}, nil)
+	mockedEtcdWrapper.On("Get", mock.Anything, "/sm/app/foo/service/serviceA/shard/", mock.Anything).Return(
+		&clientv3.GetResponse{
+			Kvs: []*mvccpb.KeyValue{
+				{Key: []byte("/sm/app/foo/service/serviceA/shard/s1"), ModRevision: 1},
+				{Key: []byte("/sm/app/foo/service/serviceA/shard/s2"), ModRevision: 2},
+			},
+		},
+		nil,
+	)
+	mockedEtcdWrapper.On("CommitTxn", mock.Anything, mock.Anything, mock.Anything).Return(&clientv3.TxnResponse{Succeeded: false}, nil).Once()
+	mockedEtcdWrapper.On("CommitTxn", mock.Anything, mock.Anything, mock.Anything).Return(&clientv3.TxnResponse{Succeeded: true}, nil).Once()
+	mockedEtcdWrapper.On("CommitTxn", mock.Anything, mock.Anything, mock.Anything).Return(&clientv3.TxnResponse{Succeeded: false}, nil).Once()
+	suite.container.Client = mockedEtcdWrapper
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/batch-del-shard", bytes.NewBuffer([]byte(batchReq.String())))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+
+	mockedEtcdWrapper.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), w.Code, http.StatusOK)
+	var resp batchShardResponse
+	assert.Nil(suite.T(), json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(suite.T(), 1, resp.Succeeded)
+	assert.Equal(suite.T(), "", resp.Results[0].Error)
+	assert.NotEmpty(suite.T(), resp.Results[1].Error)
+}
+
+func (suite *ApiTestSuite) TestGinUpdateShard_notFound() {
+	shardReq := updateShardRequest{Service: "serviceA", ShardId: "shardA"}
+
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("Get", mock.Anything, "/sm/app/foo/service/serviceA/shard/shardA", mock.Anything).Return(&clientv3.GetResponse{}, nil)
+	suite.container.Client = mockedEtcdWrapper
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/update-shard", bytes.NewBuffer([]byte(shardReq.String())))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+
+	mockedEtcdWrapper.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), w.Code, http.StatusBadRequest)
+}
+
+func (suite *ApiTestSuite) TestGinUpdateShard_revisionConflict() {
+	shardReq := updateShardRequest{Service: "serviceA", ShardId: "shardA", Task: "new", Revision: 1}
+
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("Get", mock.Anything, "/sm/app/foo/service/serviceA/shard/shardA", mock.Anything).Return(
+		&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{ModRevision: 2}}},
+		nil,
+	)
+	suite.container.Client = mockedEtcdWrapper
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/update-shard", bytes.NewBuffer([]byte(shardReq.String())))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+
+	mockedEtcdWrapper.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), w.Code, http.StatusConflict)
+}
+
+func (suite *ApiTestSuite) TestGinUpdateShard_success() {
+	shardReq := updateShardRequest{Service: "serviceA", ShardId: "shardA", Task: "new", Revision: 2}
+
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("Get", mock.Anything, "/sm/app/foo/service/serviceA/shard/shardA", mock.Anything).Return(
+		&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{ModRevision: 2}}},
+		nil,
+	)
+	mockedEtcdWrapper.On("CommitTxn", mock.Anything, mock.Anything, mock.Anything).Return(
+		&clientv3.TxnResponse{Header: &etcdserverpb.ResponseHeader{Revision: 3}, Succeeded: true},
+		nil,
+	)
+	suite.container.Client = mockedEtcdWrapper
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/update-shard", bytes.NewBuffer([]byte(shardReq.String())))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+
+	mockedEtcdWrapper.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), w.Code, http.StatusOK)
+	assert.Equal(suite.T(), `{"revision":3}`, w.Body.String())
+}
+
+func (suite *ApiTestSuite) TestGinApply_dryRun() {
+	manifest := serviceManifest{
+		Spec: &smAppSpec{Service: "serviceA", MaxShardCount: 2},
+		Shards: []*batchShardItem{
+			{ShardId: "s1"},
+			{ShardId: "s2", Task: "new"},
+			{ShardId: "s4"},
+		},
+		Workers: map[string][]string{"g1": {"w1", "w2"}},
+	}
+	b, _ := json.Marshal(manifest)
+	shard := storage.ShardSpec{Service: "serviceA"}
+
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("Get", mock.Anything, "/sm/app/foo/service/serviceA/spec", mock.Anything).Return(
+		&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Value: []byte((&smAppSpec{Service: "serviceA", MaxShardCount: 1}).String())}}},
+		nil,
+	)
+	mockedEtcdWrapper.On("Get", mock.Anything, "/sm/app/foo/service/serviceA/shard/", mock.Anything).Return(
+		&clientv3.GetResponse{
+			Kvs: []*mvccpb.KeyValue{
+				{Key: []byte("/sm/app/foo/service/serviceA/shard/s1"), Value: []byte(shard.String())},
+				{Key: []byte("/sm/app/foo/service/serviceA/shard/s2"), Value: []byte(shard.String())},
+				{Key: []byte("/sm/app/foo/service/serviceA/shard/s3"), Value: []byte(shard.String())},
+			},
+		},
+		nil,
+	)
+	mockedEtcdWrapper.On("Get", mock.Anything, "/sm/app/foo/service/serviceA/workerpool/", mock.Anything).Return(
+		&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Key: []byte("/sm/app/foo/service/serviceA/workerpool/g1/w1")}}},
+		nil,
+	)
+	suite.container.Client = mockedEtcdWrapper
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/apply?dryRun=true&prune=true", bytes.NewBuffer(b))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+
+	mockedEtcdWrapper.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), w.Code, http.StatusOK)
+	var diff manifestDiff
+	assert.Nil(suite.T(), json.Unmarshal(w.Body.Bytes(), &diff))
+	assert.True(suite.T(), diff.DryRun)
+	assert.Equal(suite.T(), 2, diff.Unchanged)
+	assert.Equal(
+		suite.T(),
+		[]*manifestChange{
+			{Kind: manifestKindSpec, Action: batchActionUpdate, Name: "serviceA"},
+			{Kind: manifestKindShard, Action: batchActionUpdate, Name: "s2"},
+			{Kind: manifestKindShard, Action: batchActionAdd, Name: "s4"},
+			{Kind: manifestKindShard, Action: batchActionDel, Name: "s3"},
+			{Kind: manifestKindWorker, Action: batchActionAdd, Name: "g1/w2"},
+		},
+		diff.Changes,
+	)
+}
+
+func (suite *ApiTestSuite) TestGinApply_yaml() {
+	manifest := `
+spec:
+  service: serviceA
+  maxShardCount: 10
+shards:
+  - shardId: s1
+    task: t1
+workers:
+  g1: [w1]
+`
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(&clientv3.GetResponse{}, nil)
+	mockedEtcdWrapper.On("CreateAndGet", mock.Anything, mock.Anything, mock.Anything, clientv3.NoLease).Return(nil)
+	mockedEtcdWrapper.On("CommitTxn", mock.Anything, mock.Anything, mock.Anything).Return(&clientv3.TxnResponse{Succeeded: true}, nil).Twice()
+	suite.container.Client = mockedEtcdWrapper
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/apply", bytes.NewBuffer([]byte(manifest)))
+	req.Header.Add("Content-Type", "application/x-yaml")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+
+	mockedEtcdWrapper.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), w.Code, http.StatusOK)
+	var diff manifestDiff
+	assert.Nil(suite.T(), json.Unmarshal(w.Body.Bytes(), &diff))
+	assert.Equal(suite.T(), 3, diff.Succeeded)
+	assert.Equal(suite.T(), 0, diff.Failed)
+}
+
+func (suite *ApiTestSuite) TestGinUpdateSpec_notFound() {
+	spec := smAppSpec{Service: "serviceA", MaxShardCount: 1}
+
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("Get", mock.Anything, "/sm/app/foo/service/serviceA/spec", mock.Anything).Return(&clientv3.GetResponse{}, nil)
+	suite.container.Client = mockedEtcdWrapper
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/update-spec", bytes.NewBuffer([]byte(spec.String())))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+
+	mockedEtcdWrapper.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), w.Code, http.StatusBadRequest)
+}
+
+func (suite *ApiTestSuite) TestGinUpdateSpec_success() {
+	cur := smAppSpec{Service: "serviceA", CreateTime: 1, MaxShardCount: 1}
+	spec := smAppSpec{Service: "serviceA", MaxShardCount: 2}
+
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("Get", mock.Anything, "/sm/app/foo/service/serviceA/spec", mock.Anything).Return(
+		&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Value: []byte(cur.String()), ModRevision: 1}}},
+		nil,
+	)
+	expect := smAppSpec{Service: "serviceA", CreateTime: 1, MaxShardCount: 2}
+	mockedEtcdWrapper.On(
+		"CommitTxn",
+		mock.Anything,
+		mock.Anything,
+		[]clientv3.Op{clientv3.OpPut("/sm/app/foo/service/serviceA/spec", expect.String())},
+	).Return(&clientv3.TxnResponse{Succeeded: true}, nil)
+	suite.container.Client = mockedEtcdWrapper
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/update-spec", bytes.NewBuffer([]byte(spec.String())))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+
+	mockedEtcdWrapper.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), w.Code, http.StatusOK)
+}
+
+func (suite *ApiTestSuite) TestGinUpdateSpec_maxRecoveryTime() {
+	for _, maxRecoveryTime := range []int{-1, 31} {
+		spec := smAppSpec{Service: "serviceA", MaxRecoveryTime: maxRecoveryTime}
+
+		mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+		suite.container.Client = mockedEtcdWrapper
+
+		req := httptest.NewRequest(http.MethodPost, "/sm/server/update-spec", bytes.NewBuffer([]byte(spec.String())))
+		req.Header.Add("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		suite.testRouter.ServeHTTP(w, req)
+
+		mockedEtcdWrapper.AssertNotCalled(suite.T(), "Get", mock.Anything, mock.Anything, mock.Anything)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	}
+}
+
+func (suite *ApiTestSuite) TestGinDrainContainer_notFound() {
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("GetKV", mock.Anything, "/sm/app/foo/service/bar/spec", mock.Anything).Return(&clientv3.GetResponse{}, nil)
+	suite.container.Client = mockedEtcdWrapper
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/drain-container", bytes.NewBufferString(`{"service":"bar","container":"c1"}`))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+
+	mockedEtcdWrapper.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+func (suite *ApiTestSuite) TestGinDrainContainer_success() {
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("GetKV", mock.Anything, "/sm/app/foo/service/bar/spec", mock.Anything).Return(&clientv3.GetResponse{Count: 1}, nil)
+	mockedEtcdWrapper.On("Put", mock.Anything, "/sm/app/foo/service/bar/drain/c1", "", mock.Anything).Return(&clientv3.PutResponse{}, nil)
+	suite.container.Client = mockedEtcdWrapper
+
+	req := httptest.NewRequest(http.MethodPost, "/sm/server/drain-container", bytes.NewBufferString(`{"service":"bar","container":"c1"}`))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.testRouter.ServeHTTP(w, req)
+
+	mockedEtcdWrapper.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
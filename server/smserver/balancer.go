@@ -2,7 +2,10 @@ package smserver
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
 )
 
 type balancer struct {
@@ -23,14 +26,57 @@ type balancerShard struct {
 
 	// isManual 是否是制定container的
 	isManual bool
+
+	// state 心跳上报的生命周期状态，决定移动的优先级
+	state storage.ShardState
+
+	// cost 心跳上报的shard负载，见 storage.ShardLoad.Cost ，app没有上报时为0
+	cost float64
 }
 
-func (b *balancer) put(containerId, shardId string, isManual bool) {
+func (b *balancer) put(containerId, shardId string, isManual bool) *balancerShard {
 	b.addContainer(containerId)
-	b.bcs[containerId].shards[shardId] = &balancerShard{
+	bs := &balancerShard{
 		id:       shardId,
 		isManual: isManual,
 	}
+	b.bcs[containerId].shards[shardId] = bs
+	return bs
+}
+
+// movable container超出maxHold时可以移走的shard，按照移动的代价从小到大排序：
+// failed和还在加载的shard不是健康的分配，优先移动，serving的shard最后移动，同一状态下先移动负载低的shard
+func (bc *balancerContainer) movable() []*balancerShard {
+	var r []*balancerShard
+	for _, bs := range bc.shards {
+		// 不能变动的shard
+		if bs.isManual {
+			continue
+		}
+		r = append(r, bs)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		if ri, rj := r[i].moveRank(), r[j].moveRank(); ri != rj {
+			return ri < rj
+		}
+		if r[i].cost != r[j].cost {
+			return r[i].cost < r[j].cost
+		}
+		return r[i].id < r[j].id
+	})
+	return r
+}
+
+func (bs *balancerShard) moveRank() int {
+	switch bs.state {
+	case storage.ShardStateFailed:
+		return 0
+	case storage.ShardStateAssigned, storage.ShardStateStarting:
+		return 1
+	default:
+		// serving以及旧版本container没有上报状态的shard
+		return 2
+	}
 }
 
 func (b *balancer) forEach(visitor func(bc *balancerContainer)) {
@@ -39,6 +85,31 @@ func (b *balancer) forEach(visitor func(bc *balancerContainer)) {
 	}
 }
 
+// forEachByCost 按照container上shard的总负载从低到高遍历，新分配的shard优先放到负载低的container
+func (b *balancer) forEachByCost(visitor func(bc *balancerContainer)) {
+	bcs := make([]*balancerContainer, 0, len(b.bcs))
+	for _, bc := range b.bcs {
+		bcs = append(bcs, bc)
+	}
+	sort.Slice(bcs, func(i, j int) bool {
+		if ci, cj := bcs[i].cost(), bcs[j].cost(); ci != cj {
+			return ci < cj
+		}
+		return bcs[i].id < bcs[j].id
+	})
+	for _, bc := range bcs {
+		visitor(bc)
+	}
+}
+
+func (bc *balancerContainer) cost() float64 {
+	var r float64
+	for _, bs := range bc.shards {
+		r += bs.cost
+	}
+	return r
+}
+
 func (b *balancer) addContainer(containerId string) {
 	cs := b.bcs[containerId]
 	if cs == nil {
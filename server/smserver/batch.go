@@ -0,0 +1,209 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smserver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+	"github.com/entertainment-venue/sm/pkg/logutil"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+var (
+	errBatchEmpty      = errors.New("empty batch")
+	errBatchTooLarge   = errors.New("batch too large")
+	errShardIdInvalid  = errors.New("invalid shard id")
+	errShardDuplicated = errors.New("duplicated shard id")
+	errShardExist      = errors.New("shard exist")
+	errShardNotExist   = errors.New("shard not exist")
+)
+
+const (
+	batchActionAdd    = "add"
+	batchActionUpdate = "update"
+	batchActionDel    = "del"
+)
+
+// batchShardOp 批量请求中单个shard的操作，value为空表示删除
+type batchShardOp struct {
+	ShardId string
+	Value   string
+}
+
+type batchShardResult struct {
+	ShardId string `json:"shardId"`
+	// Error 为空表示成功
+	Error string `json:"error,omitempty"`
+}
+
+type batchShardResponse struct {
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Results   []*batchShardResult `json:"results"`
+}
+
+// shardBatch 批量修改service下的shard，整个batch先做校验，
+// 再按照 etcdutil.DefaultMaxTxnOps 分段提交，每个shard的结果单独返回
+type shardBatch struct {
+	container *smContainer
+	service   string
+	action    string
+}
+
+// validate 校验整个batch，有任何问题都不提交
+func (b *shardBatch) validate(ops []*batchShardOp) error {
+	if len(ops) == 0 {
+		return errBatchEmpty
+	}
+	if len(ops) > defaultMaxBatchShards {
+		return errors.Wrapf(errBatchTooLarge, "max %d", defaultMaxBatchShards)
+	}
+	dup := make(map[string]struct{}, len(ops))
+	for _, op := range ops {
+		if op.ShardId == "" || strings.Contains(op.ShardId, "/") {
+			return errors.Wrapf(errShardIdInvalid, "shardId [%s]", op.ShardId)
+		}
+		if _, ok := dup[op.ShardId]; ok {
+			return errors.Wrapf(errShardDuplicated, "shardId [%s]", op.ShardId)
+		}
+		dup[op.ShardId] = struct{}{}
+	}
+	return nil
+}
+
+// apply 按照当前shard的状态生成txn，add要求shard不存在，update和del要求shard存在，
+// txn通过revision保证提交前shard没有被其他请求修改
+func (b *shardBatch) apply(ctx context.Context, ops []*batchShardOp) (*batchShardResponse, error) {
+	pfx := b.container.nodeManager.ShardDir(b.service)
+	resp, err := b.container.Client.Get(ctx, pfx, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	revisions := make(map[string]int64, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		revisions[strings.TrimPrefix(string(kv.Key), pfx)] = kv.ModRevision
+	}
+
+	var (
+		result  = batchShardResponse{Results: make([]*batchShardResult, 0, len(ops))}
+		pending []*batchShardOp
+	)
+	for _, op := range ops {
+		_, exist := revisions[op.ShardId]
+		switch {
+		case b.action == batchActionAdd && exist:
+			result.Results = append(result.Results, &batchShardResult{ShardId: op.ShardId, Error: errShardExist.Error()})
+		case b.action != batchActionAdd && !exist:
+			result.Results = append(result.Results, &batchShardResult{ShardId: op.ShardId, Error: errShardNotExist.Error()})
+		default:
+			pending = append(pending, op)
+		}
+	}
+
+	result.Results = append(result.Results, b.commitAll(ctx, pending, revisions)...)
+
+	for _, r := range result.Results {
+		if r.Error == "" {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+	logutil.Info(
+		"shard batch applied",
+		zap.String("service", b.service),
+		zap.String("action", b.action),
+		zap.Int("succeeded", result.Succeeded),
+		zap.Int("failed", result.Failed),
+	)
+	return &result, nil
+}
+
+// commitAll 按照 etcdutil.DefaultMaxTxnOps 分段提交，revisions是读取shard时的ModRevision
+func (b *shardBatch) commitAll(ctx context.Context, ops []*batchShardOp, revisions map[string]int64) []*batchShardResult {
+	var results []*batchShardResult
+	for start := 0; start < len(ops); start += etcdutil.DefaultMaxTxnOps {
+		end := start + etcdutil.DefaultMaxTxnOps
+		if end > len(ops) {
+			end = len(ops)
+		}
+		results = append(results, b.commit(ctx, ops[start:end], revisions)...)
+	}
+	return results
+}
+
+// commit 提交一段shard，compare失败说明有shard被并发修改，逐个重试，拿到每个shard准确的结果
+func (b *shardBatch) commit(ctx context.Context, ops []*batchShardOp, revisions map[string]int64) []*batchShardResult {
+	results := make([]*batchShardResult, 0, len(ops))
+
+	succeeded, err := b.txn(ctx, ops, revisions)
+	if err == nil && succeeded {
+		for _, op := range ops {
+			results = append(results, &batchShardResult{ShardId: op.ShardId})
+		}
+		return results
+	}
+	if err != nil || len(ops) == 1 {
+		if err == nil {
+			err = errors.New("shard changed concurrently")
+		}
+		logutil.Warn(
+			"shard batch txn failed",
+			zap.String("service", b.service),
+			zap.String("action", b.action),
+			zap.Int("size", len(ops)),
+			zap.Error(err),
+		)
+		for _, op := range ops {
+			results = append(results, &batchShardResult{ShardId: op.ShardId, Error: err.Error()})
+		}
+		return results
+	}
+
+	for _, op := range ops {
+		results = append(results, b.commit(ctx, []*batchShardOp{op}, revisions)...)
+	}
+	return results
+}
+
+func (b *shardBatch) txn(ctx context.Context, ops []*batchShardOp, revisions map[string]int64) (bool, error) {
+	var (
+		cmps []clientv3.Cmp
+		txns []clientv3.Op
+	)
+	for _, op := range ops {
+		key := b.container.nodeManager.ShardPath(b.service, op.ShardId)
+		switch b.action {
+		case batchActionAdd:
+			cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(key), "=", 0))
+			txns = append(txns, clientv3.OpPut(key, op.Value))
+		case batchActionUpdate:
+			cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", revisions[op.ShardId]))
+			txns = append(txns, clientv3.OpPut(key, op.Value))
+		default:
+			cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", revisions[op.ShardId]))
+			txns = append(txns, clientv3.OpDelete(key))
+		}
+	}
+	resp, err := b.container.Client.CommitTxn(ctx, cmps, txns)
+	if err != nil {
+		return false, errors.Wrap(err, "")
+	}
+	return resp.Succeeded, nil
+}
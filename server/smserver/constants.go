@@ -21,4 +21,27 @@ import (
 const (
 	defaultSleepTimeout = 3 * time.Second
 	defaultLoopInterval = 3 * time.Second
+
+	// defaultResignTimeout leader放弃时等待进行中rb结束的时间，rb中包含两次lease的等待
+	defaultResignTimeout = 45 * time.Second
+	// defaultSuccessorTTL 继任者提名的有效期，单位s
+	defaultSuccessorTTL = 30
+
+	// defaultMaxBatchShards 批量接口单次请求允许的shard数量
+	defaultMaxBatchShards = 20000
+
+	// defaultCommandTimeout 拉模式的container确认指令的等待时间
+	defaultCommandTimeout = 10 * time.Second
+
+	// defaultGRPCTimeout grpc receiver的请求超时，和http client保持一致
+	defaultGRPCTimeout = 3 * time.Second
+
+	// defaultAssignmentInterval WatchAssignment 合并心跳事件的时间窗口，窗口内的多次心跳只重新汇总一次
+	defaultAssignmentInterval = 1 * time.Second
+)
+
+// smAppSpec.Receiver 的取值
+const (
+	receiverHTTP = "http"
+	receiverGRPC = "grpc"
 )
@@ -16,6 +16,7 @@ package smserver
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"sync"
 	"time"
@@ -39,6 +40,11 @@ var (
 	_ core.ShardPrimitives = new(smContainer)
 )
 
+var (
+	errNotLeader         = errors.New("not leader")
+	errSuccessorNotFound = errors.New("successor not campaigning")
+)
+
 // smContainer 竞争leader，管理sm整个集群
 type smContainer struct {
 	*apputil.Container
@@ -62,6 +68,21 @@ type smContainer struct {
 
 	// leaderShard 保证sm运行健康的goroutine，通过task节点下发任务给op
 	leaderShard *smShard
+	// election 当前container作为leader时的选举对象，用于主动放弃leader
+	election *concurrency.Election
+	// resignCh leader主动放弃后通知campaign重新参与竞选
+	resignCh chan struct{}
+
+	// standby 维护所有service的mapper，smShard 创建时直接使用
+	standby *standbyMappers
+
+	// acl sm admin api的认证、鉴权和审计
+	acl *aclManager
+	// tlsConfig sm开启https时，leader给sm节点下发shard也需要走https
+	tlsConfig *tls.Config
+
+	// grpc 配置了grpcAddr时提供gRPC接口
+	grpc *grpcServer
 
 	// shardWrapper 4 unit test，隔离shard和container
 	shardWrapper ShardWrapper
@@ -72,10 +93,14 @@ func newSMContainer(opts *serverOptions) (*smContainer, error) {
 		stopper:      &commonutil.GoroutineStopper{},
 		shards:       make(map[string]Shard),
 		shardWrapper: &smShardWrapper{},
-		nodeManager:  &nodeManager{smService: opts.service},
+		nodeManager:  newNodeManager(opts.service, etcdutil.NewPathBuilder(opts.etcdPrefix)),
 	}
 
-	etcdClient, err := etcdutil.NewEtcdClient(opts.endpoints)
+	etcdClient, err := etcdutil.NewEtcdClient(
+		opts.endpoints,
+		etcdutil.WithAuth(opts.etcdUsername, opts.etcdPassword),
+		etcdutil.WithTLSConfig(opts.etcdTLSConfig),
+	)
 	if err != nil {
 		return nil, errors.Wrap(err, "")
 	}
@@ -103,6 +128,23 @@ func newSMContainer(opts *serverOptions) (*smContainer, error) {
 		return nil, err
 	}
 
+	// acl需要在api可用之前加载完成，防止开启acl后短暂的放行
+	smCtr.tlsConfig = opts.tlsConfig
+	smCtr.acl = newACLManager(&smCtr, opts.aclEnabled, opts.aclAdminToken)
+	var aclRev int64
+	if opts.aclEnabled {
+		aclRev, err = smCtr.acl.load(context.TODO())
+		if err != nil {
+			return nil, errors.Wrap(err, "")
+		}
+	}
+
+	// shardKeeper启动后就会下发shard，standby需要提前准备好
+	smCtr.standby, err = newStandbyMappers(&smCtr)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+
 	container, err := apputil.NewContainer(
 		apputil.WithService(opts.service),
 
@@ -113,18 +155,20 @@ func newSMContainer(opts *serverOptions) (*smContainer, error) {
 
 		// http server
 		apputil.WithAddr(opts.addr),
+		apputil.WithTLSConfig(opts.tlsConfig),
 		// api背后的具体实现
 		apputil.WithShardPrimitives(&smCtr),
 
 		apputil.WithStorageType(storage.Etcd),
 	)
 	if err != nil {
+		smCtr.standby.Close()
 		return nil, errors.Wrap(err, "")
 	}
 
 	engine := container.Receiver().Extract().(*gin.Engine)
 	for router, handler := range smCtr.getHttpHandlers() {
-		engine.Any(router, handler)
+		engine.Any(router, smCtr.acl.handler(router), handler)
 	}
 
 	smCtr.Container = container
@@ -132,9 +176,27 @@ func newSMContainer(opts *serverOptions) (*smContainer, error) {
 		if container != nil {
 			container.Close()
 		}
+		smCtr.standby.Close()
 		return nil, errors.Wrap(err, "")
 	}
 
+	if opts.grpcAddr != "" {
+		smCtr.grpc = newGRPCServer(&smCtr, engine, opts.tlsConfig)
+		if err := smCtr.grpc.serve(opts.grpcAddr); err != nil {
+			container.Close()
+			smCtr.standby.Close()
+			return nil, errors.Wrap(err, "")
+		}
+	}
+
+	if opts.aclEnabled {
+		smCtr.stopper.Wrap(
+			func(ctx context.Context) {
+				smCtr.acl.watch(ctx, aclRev)
+			},
+		)
+	}
+
 	// 竞争leader
 	smCtr.stopper.Wrap(
 		func(ctx context.Context) {
@@ -150,15 +212,28 @@ func (c *smContainer) getHttpHandlers() map[string]func(c *gin.Context) {
 	handlers := make(map[string]func(c *gin.Context))
 	handlers["/sm/server/add-spec"] = apiSrv.GinAddSpec
 	handlers["/sm/server/del-spec"] = apiSrv.GinDelSpec
+	handlers["/sm/server/update-spec"] = apiSrv.GinUpdateSpec
 	handlers["/sm/server/get-spec"] = apiSrv.GinGetSpec
 	handlers["/sm/server/add-shard"] = apiSrv.GinAddShard
 	handlers["/sm/server/del-shard"] = apiSrv.GinDelShard
+	handlers["/sm/server/update-shard"] = apiSrv.GinUpdateShard
 	handlers["/sm/server/get-shard"] = apiSrv.GinGetShard
+	handlers["/sm/server/batch-add-shard"] = apiSrv.GinBatchAddShard
+	handlers["/sm/server/batch-update-shard"] = apiSrv.GinBatchUpdateShard
+	handlers["/sm/server/batch-del-shard"] = apiSrv.GinBatchDelShard
+	handlers["/sm/server/apply"] = apiSrv.GinApply
 	handlers["/sm/server/add-worker"] = apiSrv.GinAddWorker
 	handlers["/sm/server/del-worker"] = apiSrv.GinDelWorker
 	handlers["/sm/server/get-worker"] = apiSrv.GinGetWorker
+	handlers["/sm/server/drain-container"] = apiSrv.GinDrainContainer
+	handlers["/sm/server/undrain-container"] = apiSrv.GinUndrainContainer
 	handlers["/sm/server/detail"] = apiSrv.GinServiceDetail
 	handlers["/sm/server/health"] = apiSrv.GinHealth
+	handlers["/sm/server/resign"] = apiSrv.GinResign
+	handlers["/sm/server/leader"] = apiSrv.GinLeader
+	handlers["/sm/server/acl/set-principal"] = apiSrv.GinSetPrincipal
+	handlers["/sm/server/acl/del-principal"] = apiSrv.GinDelPrincipal
+	handlers["/sm/server/acl/get-principal"] = apiSrv.GinGetPrincipal
 	handlers["/swagger/*any"] = ginSwagger.WrapHandler(swaggerfiles.Handler)
 	return handlers
 }
@@ -183,6 +258,11 @@ func (c *smContainer) Close() error {
 	}
 	c.closing = true
 
+	// 先停止gRPC接口，和http一样不再接收新的请求
+	if c.grpc != nil {
+		c.grpc.close()
+	}
+
 	// 回收sm当前container负责的分片，后面关闭可能的leader身份，
 	// 既然处于关闭状态，也不能再接收shard的移动请求，但是此时http api可能还在工作，
 	// 其他选举出来的leader可能会下发失败的请求，最大限度避免掉。
@@ -204,6 +284,10 @@ func (c *smContainer) Close() error {
 		c.stopper.Close()
 	}
 
+	if c.standby != nil {
+		c.standby.Close()
+	}
+
 	logutil.Info(
 		"smContainer closing",
 		zap.String("id", c.Id()),
@@ -329,6 +413,11 @@ func (c *smContainer) campaign(ctx context.Context) {
 			zap.Int64("lease", int64(c.Session.Lease())),
 		)
 
+		// 前任leader提名了继任者，让出leader，排队等待继任者放弃
+		if c.yieldToSuccessor(ctx, election) {
+			goto loop
+		}
+
 		// leader有几种情况会重新选举：
 		// 1 重启
 		// 2 和etcd之间网络问题
@@ -343,8 +432,7 @@ func (c *smContainer) campaign(ctx context.Context) {
 		// https://github.com/entertainment-venue/sm/wiki/leader%E8%AE%BE%E8%AE%A1%E6%80%9D%E8%B7%AF
 		st := shardTask{GovernedService: c.Service()}
 		spec := storage.ShardSpec{Service: c.Service(), Task: st.String()}
-		var err error
-		c.leaderShard, err = newSMShard(c, &spec)
+		leaderShard, err := newSMShard(c, &spec)
 		if err != nil {
 			logutil.Error(
 				"newSMShard error",
@@ -353,13 +441,173 @@ func (c *smContainer) campaign(ctx context.Context) {
 			)
 			goto loop
 		}
+		resignCh := make(chan struct{})
+		c.mu.Lock()
+		c.leaderShard = leaderShard
+		c.election = election
+		c.resignCh = resignCh
+		c.mu.Unlock()
 
 		// block until出现需要放弃leader职权的事件
 		logutil.Info("leader completed op", zap.String("service", c.Service()))
 
-		<-ctx.Done()
-		logutil.Info("leader exit", zap.String("service", c.Service()))
-		c.leaderShard = nil
-		return
+		select {
+		case <-ctx.Done():
+			// Close 持有mu等待campaign退出，这里不能加锁
+			logutil.Info("leader exit", zap.String("service", c.Service()))
+			c.leaderShard = nil
+			c.election = nil
+			return
+		case <-resignCh:
+			// Resign 已经回收leaderShard，继续参与竞选，排在其他候选者之后
+			logutil.Info("leader resigned, campaign again", zap.String("service", c.Service()))
+		}
+	}
+}
+
+// Resign leader主动放弃，等待进行中的rb结束（超时则中断），然后放弃leader，
+// successor不为空时，提名继任者，其他候选者竞选成功后会让出leader，直到提名过期
+func (c *smContainer) Resign(ctx context.Context, successor string) error {
+	c.mu.Lock()
+	if c.closing {
+		c.mu.Unlock()
+		return commonutil.ErrClosing
 	}
+	if c.leaderShard == nil || c.election == nil {
+		c.mu.Unlock()
+		return errNotLeader
+	}
+	leaderShard := c.leaderShard
+	c.mu.Unlock()
+
+	// 提名需要访问etcd，不持有mu
+	if successor != "" && successor != c.Id() {
+		if err := c.nominate(ctx, successor); err != nil {
+			return err
+		}
+	}
+
+	// 提名期间可能已经关闭或者失去leader
+	c.mu.Lock()
+	if c.closing {
+		c.mu.Unlock()
+		return commonutil.ErrClosing
+	}
+	if c.leaderShard != leaderShard || c.election == nil {
+		c.mu.Unlock()
+		return errNotLeader
+	}
+	election, resignCh := c.election, c.resignCh
+	c.leaderShard, c.election, c.resignCh = nil, nil, nil
+	c.mu.Unlock()
+
+	logutil.Info(
+		"leader resigning",
+		zap.String("service", c.Service()),
+		zap.String("successor", successor),
+	)
+
+	// 先停止leader的rb，防止新leader和旧leader同时在做rb
+	if err := leaderShard.Drain(ctx); err != nil {
+		logutil.Warn(
+			"wait rb timeout, abort rb",
+			zap.String("service", c.Service()),
+			zap.Error(err),
+		)
+	}
+	leaderShard.Close()
+
+	// 放弃失败也需要通知campaign，重新竞选会复用之前的节点，重新成为leader
+	defer close(resignCh)
+	rctx, cancel := context.WithTimeout(context.Background(), etcdutil.DefaultRequestTimeout)
+	defer cancel()
+	if err := election.Resign(rctx); err != nil {
+		return errors.Wrap(err, "")
+	}
+	logutil.Info(
+		"leader resigned",
+		zap.String("service", c.Service()),
+		zap.String("successor", successor),
+	)
+	return nil
+}
+
+// nominate 提名继任者，继任者需要在竞选队列中
+func (c *smContainer) nominate(ctx context.Context, successor string) error {
+	candidates, err := c.candidates(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := candidates[successor]; !ok {
+		return errSuccessorNotFound
+	}
+
+	resp, err := c.Client.GetClient().Grant(ctx, defaultSuccessorTTL)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	if _, err := c.Client.Put(ctx, c.nodeManager.SuccessorPath(), successor, clientv3.WithLease(resp.ID)); err != nil {
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
+
+// candidates 获取参与竞选的所有container
+func (c *smContainer) candidates(ctx context.Context) (ArmorMap, error) {
+	resp, err := c.Client.Get(ctx, c.nodeManager.LeaderPath(), clientv3.WithPrefix())
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	candidates := make(ArmorMap)
+	for _, kv := range resp.Kvs {
+		var lvalue leaderEtcdValue
+		if err := json.Unmarshal(kv.Value, &lvalue); err != nil {
+			return nil, errors.Wrap(err, "")
+		}
+		candidates[lvalue.ContainerId] = ""
+	}
+	return candidates, nil
+}
+
+// yieldToSuccessor 前任leader提名了其他继任者，并且继任者在竞选，放弃当前的leader
+func (c *smContainer) yieldToSuccessor(ctx context.Context, election *concurrency.Election) bool {
+	resp, err := c.Client.GetKV(ctx, c.nodeManager.SuccessorPath(), nil)
+	if err != nil || resp.Count == 0 {
+		return false
+	}
+	successor := string(resp.Kvs[0].Value)
+	if successor == c.Id() {
+		// 继任成功，提名失效
+		if _, err := c.Client.Delete(ctx, c.nodeManager.SuccessorPath()); err != nil {
+			logutil.Warn(
+				"delete successor error",
+				zap.String("service", c.Service()),
+				zap.Error(err),
+			)
+		}
+		return false
+	}
+
+	candidates, err := c.candidates(ctx)
+	if err != nil {
+		return false
+	}
+	if _, ok := candidates[successor]; !ok {
+		return false
+	}
+	if err := election.Resign(ctx); err != nil {
+		logutil.Error(
+			"yield to successor error",
+			zap.String("service", c.Service()),
+			zap.String("successor", successor),
+			zap.Error(err),
+		)
+		return false
+	}
+	logutil.Info(
+		"yield to successor",
+		zap.String("service", c.Service()),
+		zap.String("successor", successor),
+	)
+	return true
 }
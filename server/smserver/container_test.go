@@ -1,6 +1,7 @@
 package smserver
 
 import (
+	"context"
 	"testing"
 
 	"github.com/entertainment-venue/sm/pkg/apputil"
@@ -158,3 +159,14 @@ func (suite *ContainerTestSuite) TestClose_closing() {
 	err := suite.container.Close()
 	assert.Equal(suite.T(), err, commonutil.ErrClosing)
 }
+
+func (suite *ContainerTestSuite) TestResign_closing() {
+	suite.container.closing = true
+	err := suite.container.Resign(context.TODO(), "")
+	assert.Equal(suite.T(), err, commonutil.ErrClosing)
+}
+
+func (suite *ContainerTestSuite) TestResign_notLeader() {
+	err := suite.container.Resign(context.TODO(), "")
+	assert.Equal(suite.T(), err, errNotLeader)
+}
@@ -24,11 +24,18 @@ import (
 // nodeManager 管理sm的etcd prefix
 type nodeManager struct {
 	smService string
+
+	// paths sm集群的etcd prefix，和接入的app共用
+	paths *etcdutil.PathBuilder
+}
+
+func newNodeManager(smService string, paths *etcdutil.PathBuilder) *nodeManager {
+	return &nodeManager{smService: smService, paths: paths}
 }
 
 // SMRootPath /sm/app/foo.bar
 func (n *nodeManager) SMRootPath() string {
-	return etcdutil.ServicePath(n.smService)
+	return n.paths.ServicePath(n.smService)
 }
 
 // LeaderPath /sm/app/foo.bar/leader
@@ -36,6 +43,12 @@ func (n *nodeManager) LeaderPath() string {
 	return path.Join(n.SMRootPath(), "leader")
 }
 
+// SuccessorPath /sm/app/foo.bar/successor
+// leader主动放弃时指定的继任者，节点绑定lease，过期后由election的顺序决定leader
+func (n *nodeManager) SuccessorPath() string {
+	return path.Join(n.SMRootPath(), "successor")
+}
+
 // ServicePath sm会需要得到外部service的路径
 func (n *nodeManager) ServicePath(service string) string {
 	return path.Join(n.SMRootPath(), "service", service)
@@ -69,29 +82,50 @@ func (n *nodeManager) WorkerPath(appService, workerGroup, worker string) string
 	return path.Join(n.WorkerGroupPath(appService), workerGroup, worker)
 }
 
+// DrainDir /sm/app/foo.bar/service/proxy.dev/drain/
+// 被摘除的container不再分配shard，已有的shard会被迁走
+func (n *nodeManager) DrainDir(appService string) string {
+	return path.Join(n.ServicePath(appService), "drain") + "/"
+}
+
+// DrainPath /sm/app/foo.bar/service/proxy.dev/drain/127.0.0.1:8801
+func (n *nodeManager) DrainPath(appService, container string) string {
+	return path.Join(n.DrainDir(appService), container)
+}
+
 // ExternalServiceDir /sm/app/proxy.dev/
 func (n *nodeManager) ExternalServiceDir(service string) string {
-	return etcdutil.ServicePath(service) + "/"
+	return n.paths.ServicePath(service) + "/"
 }
 
 // ExternalShardHbDir /sm/app/proxy.dev/shardhb/
 func (n *nodeManager) ExternalShardHbDir(appService string) string {
-	return path.Join(etcdutil.ServicePath(appService), "shardhb") + "/"
+	return path.Join(n.paths.ServicePath(appService), "shardhb") + "/"
 }
 
 // ExternalContainerHbDir /sm/app/proxy.dev/containerhb/
 func (n *nodeManager) ExternalContainerHbDir(appService string) string {
-	return path.Join(etcdutil.ServicePath(appService), "containerhb") + "/"
+	return path.Join(n.paths.ServicePath(appService), "containerhb") + "/"
 }
 
 // ExternalLeaseGuardPath /sm/app/proxy.dev/lease/guard
 func (n *nodeManager) ExternalLeaseGuardPath(appService string) string {
-	return etcdutil.LeaseGuardPath(appService)
+	return n.paths.LeaseGuardPath(appService)
 }
 
 // ExternalLeaseBridgePath /sm/app/proxy.dev/bridge
 func (n *nodeManager) ExternalLeaseBridgePath(appService string) string {
-	return etcdutil.LeaseBridgePath(appService)
+	return n.paths.LeaseBridgePath(appService)
+}
+
+// ACLPrincipalDir /sm/app/foo.bar/acl/principal/
+func (n *nodeManager) ACLPrincipalDir() string {
+	return path.Join(n.SMRootPath(), "acl", "principal") + "/"
+}
+
+// ACLPrincipalPath /sm/app/foo.bar/acl/principal/ops
+func (n *nodeManager) ACLPrincipalPath(name string) string {
+	return path.Join(n.ACLPrincipalDir(), name)
 }
 
 // parseWorkerGroupAndContainer /sm/app/foo.bar/service/foo.bar/workerpool/g1/127.0.0.1:8801
@@ -1,9 +1,13 @@
 package smserver
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+)
 
 func Test_Etcd(t *testing.T) {
-	nm := nodeManager{smService: "foo"}
+	nm := newNodeManager("foo", etcdutil.NewPathBuilder(""))
 
 	// sm部分
 
@@ -42,6 +46,11 @@ func Test_Etcd(t *testing.T) {
 		t.SkipNow()
 	}
 
+	if nm.DrainPath("bar", "c1") != "/sm/app/foo/service/bar/drain/c1" {
+		t.Error("path error")
+		t.SkipNow()
+	}
+
 	// service部分
 	if nm.ExternalServiceDir("bar") != "/sm/app/bar/" {
 		t.Error("path error")
@@ -0,0 +1,462 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/entertainment-venue/sm/pkg/logutil"
+	"github.com/entertainment-venue/sm/server/smpb"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// grpcServer 提供和 /sm/server/* 相同的操作，rpc请求转换为http请求交给gin处理，
+// 参数校验、acl和审计日志与http接口保持一致
+type grpcServer struct {
+	smpb.UnimplementedSMServer
+
+	container *smContainer
+
+	// handler 注册了 /sm/server/* 的gin engine
+	handler http.Handler
+
+	server *grpc.Server
+}
+
+func newGRPCServer(container *smContainer, handler http.Handler, tlsConfig *tls.Config) *grpcServer {
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s := grpcServer{
+		container: container,
+		handler:   handler,
+		server:    grpc.NewServer(opts...),
+	}
+	smpb.RegisterSMServer(s.server, &s)
+	return &s
+}
+
+func (s *grpcServer) serve(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	go func() {
+		// Stop之后Serve返回nil
+		if err := s.server.Serve(lis); err != nil {
+			logutil.Error(
+				"grpc Serve error",
+				zap.String("addr", addr),
+				zap.Error(err),
+			)
+		}
+	}()
+	logutil.Info(
+		"grpc server started",
+		zap.String("addr", addr),
+	)
+	return nil
+}
+
+// close 直接中断进行中的rpc，WatchAssignment 的stream不会主动结束，不能使用GracefulStop
+func (s *grpcServer) close() {
+	s.server.Stop()
+}
+
+// responseRecorder 收集gin的返回，只在进程内使用
+type responseRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	r.code = code
+}
+
+// invoke metadata中的authorization和客户端证书透传给gin，acl按照http请求的方式做认证
+func (s *grpcServer) invoke(ctx context.Context, method, path string, query url.Values, body interface{}, out interface{}) error {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		reader = bytes.NewReader(b)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, path, reader)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			req.Header.Set("Authorization", v[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		req.RemoteAddr = p.Addr.String()
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state := info.State
+			req.TLS = &state
+		}
+	}
+
+	w := responseRecorder{header: make(http.Header), code: http.StatusOK}
+	s.handler.ServeHTTP(&w, req)
+	if w.code != http.StatusOK {
+		msg := w.body.String()
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(w.body.Bytes(), &resp); err == nil && resp.Error != "" {
+			msg = resp.Error
+		}
+		return status.Error(grpcCode(w.code), msg)
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.body.Bytes(), out); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	return nil
+}
+
+func grpcCode(httpCode int) codes.Code {
+	switch httpCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		// 乐观锁冲突，调用方重新读取后重试
+		return codes.Aborted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+func (s *grpcServer) AddSpec(ctx context.Context, req *smpb.AppSpec) (*emptypb.Empty, error) {
+	spec := smAppSpec{
+		Service:         req.Service,
+		CreateTime:      req.CreateTime,
+		MaxShardCount:   int(req.MaxShardCount),
+		MaxRecoveryTime: int(req.MaxRecoveryTime),
+		Receiver:        req.Receiver,
+	}
+	return &emptypb.Empty{}, s.invoke(ctx, http.MethodPost, "/sm/server/add-spec", nil, &spec, nil)
+}
+
+func (s *grpcServer) UpdateSpec(ctx context.Context, req *smpb.AppSpec) (*emptypb.Empty, error) {
+	spec := smAppSpec{
+		Service:         req.Service,
+		CreateTime:      req.CreateTime,
+		MaxShardCount:   int(req.MaxShardCount),
+		MaxRecoveryTime: int(req.MaxRecoveryTime),
+		Receiver:        req.Receiver,
+	}
+	return &emptypb.Empty{}, s.invoke(ctx, http.MethodPost, "/sm/server/update-spec", nil, &spec, nil)
+}
+
+func (s *grpcServer) DelSpec(ctx context.Context, req *smpb.DelSpecRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, s.invoke(ctx, http.MethodGet, "/sm/server/del-spec", url.Values{"service": {req.Service}}, nil, nil)
+}
+
+func (s *grpcServer) GetSpec(ctx context.Context, _ *emptypb.Empty) (*smpb.GetSpecResponse, error) {
+	var resp struct {
+		Services []string `json:"services"`
+	}
+	if err := s.invoke(ctx, http.MethodGet, "/sm/server/get-spec", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &smpb.GetSpecResponse{Services: resp.Services}, nil
+}
+
+func (s *grpcServer) AddShard(ctx context.Context, req *smpb.AddShardRequest) (*emptypb.Empty, error) {
+	r := addShardRequest{
+		ShardId:           req.ShardId,
+		Service:           req.Service,
+		Task:              req.Task,
+		ManualContainerId: req.ManualContainerId,
+		Group:             req.Group,
+		WorkerGroup:       req.WorkerGroup,
+	}
+	return &emptypb.Empty{}, s.invoke(ctx, http.MethodPost, "/sm/server/add-shard", nil, &r, nil)
+}
+
+func (s *grpcServer) UpdateShard(ctx context.Context, req *smpb.UpdateShardRequest) (*smpb.UpdateShardResponse, error) {
+	r := updateShardRequest{
+		ShardId:           req.ShardId,
+		Service:           req.Service,
+		Task:              req.Task,
+		ManualContainerId: req.ManualContainerId,
+		Group:             req.Group,
+		WorkerGroup:       req.WorkerGroup,
+		Revision:          req.Revision,
+	}
+	var resp struct {
+		Revision int64 `json:"revision"`
+	}
+	if err := s.invoke(ctx, http.MethodPost, "/sm/server/update-shard", nil, &r, &resp); err != nil {
+		return nil, err
+	}
+	return &smpb.UpdateShardResponse{Revision: resp.Revision}, nil
+}
+
+func (s *grpcServer) DelShard(ctx context.Context, req *smpb.DelShardRequest) (*emptypb.Empty, error) {
+	r := delShardRequest{ShardId: req.ShardId, Service: req.Service}
+	return &emptypb.Empty{}, s.invoke(ctx, http.MethodPost, "/sm/server/del-shard", nil, &r, nil)
+}
+
+func (s *grpcServer) GetShard(ctx context.Context, req *smpb.GetShardRequest) (*smpb.GetShardResponse, error) {
+	var resp struct {
+		Shards []string `json:"shards"`
+	}
+	if err := s.invoke(ctx, http.MethodGet, "/sm/server/get-shard", url.Values{"service": {req.Service}}, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &smpb.GetShardResponse{Shards: resp.Shards}, nil
+}
+
+func (s *grpcServer) AddWorker(ctx context.Context, req *smpb.WorkerRequest) (*emptypb.Empty, error) {
+	r := workerRequest{WorkerGroup: req.WorkerGroup, Service: req.Service, Worker: req.Worker}
+	return &emptypb.Empty{}, s.invoke(ctx, http.MethodPost, "/sm/server/add-worker", nil, &r, nil)
+}
+
+func (s *grpcServer) DelWorker(ctx context.Context, req *smpb.WorkerRequest) (*emptypb.Empty, error) {
+	r := workerRequest{WorkerGroup: req.WorkerGroup, Service: req.Service, Worker: req.Worker}
+	return &emptypb.Empty{}, s.invoke(ctx, http.MethodPost, "/sm/server/del-worker", nil, &r, nil)
+}
+
+func (s *grpcServer) GetWorker(ctx context.Context, req *smpb.GetWorkerRequest) (*smpb.GetWorkerResponse, error) {
+	var resp struct {
+		Workers map[string][]string `json:"workers"`
+	}
+	if err := s.invoke(ctx, http.MethodGet, "/sm/server/get-worker", url.Values{"service": {req.Service}}, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &smpb.GetWorkerResponse{Workers: toStringLists(resp.Workers)}, nil
+}
+
+func (s *grpcServer) Detail(ctx context.Context, req *smpb.DetailRequest) (*smpb.ServiceDetail, error) {
+	var detail serviceDetail
+	if err := s.invoke(ctx, http.MethodGet, "/sm/server/detail", url.Values{"service": {req.Service}}, nil, &detail); err != nil {
+		return nil, err
+	}
+	return detail.toPB(), nil
+}
+
+// WatchAssignment 监听service的container心跳，心跳中的shard变化后重新汇总分配关系，推送和上一次的差异，
+// 每次心跳都会触发watch事件，事件在 defaultAssignmentInterval 内合并，避免每个事件都读取etcd
+func (s *grpcServer) WatchAssignment(req *smpb.WatchAssignmentRequest, stream smpb.SM_WatchAssignmentServer) error {
+	ctx := stream.Context()
+
+	// 先watch再查询，查询之后的变化都会触发重新汇总，不会遗漏
+	wctx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+	wch := s.container.Client.Watch(wctx, s.container.nodeManager.ExternalContainerHbDir(req.Service), clientv3.WithPrefix())
+
+	// 通过detail接口完成鉴权，同时获取当前的分配
+	var detail serviceDetail
+	if err := s.invoke(ctx, http.MethodGet, "/sm/server/detail", url.Values{"service": {req.Service}}, nil, &detail); err != nil {
+		return err
+	}
+	prev := detail.assignment()
+	for _, event := range diffAssignment(nil, prev) {
+		event.Initial = true
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+
+	var (
+		// rev 窗口内最新的事件revision，推送时带上
+		rev    int64
+		timer  *time.Timer
+		timerC <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case wresp, ok := <-wch:
+			if !ok {
+				return status.Error(codes.Unavailable, "watch closed")
+			}
+			if err := wresp.Err(); err != nil {
+				return status.Error(codes.Unavailable, err.Error())
+			}
+			rev = wresp.Header.Revision
+			// 窗口已经开启时只记录revision
+			if timerC == nil {
+				timer = time.NewTimer(defaultAssignmentInterval)
+				timerC = timer.C
+			}
+		case <-timerC:
+			timerC = nil
+
+			cur, err := loadServiceDetail(ctx, s.container.Client, s.container.nodeManager, req.Service)
+			if err != nil {
+				if err == errServiceNotExist {
+					return status.Error(codes.NotFound, err.Error())
+				}
+				return status.Error(codes.Internal, err.Error())
+			}
+			next := cur.assignment()
+			for _, event := range diffAssignment(prev, next) {
+				event.Revision = rev
+				if err := stream.Send(event); err != nil {
+					return err
+				}
+			}
+			prev = next
+		}
+	}
+}
+
+// assignment shard到所在container的映射
+func (d *serviceDetail) assignment() map[string]string {
+	r := make(map[string]string)
+	for container, shards := range d.Allocate {
+		for _, shard := range shards {
+			r[shard] = container
+		}
+	}
+	return r
+}
+
+func (d *serviceDetail) toPB() *smpb.ServiceDetail {
+	r := smpb.ServiceDetail{
+		ShardSpec:         make(map[string]*smpb.ShardSpec),
+		WorkerGroup:       toStringLists(d.WorkerGroup),
+		Allocate:          toStringLists(d.Allocate),
+		AliveContainers:   d.AliveContainers,
+		NotAllocateShards: d.NotAllocateShards,
+		DrainedContainers: d.DrainedContainers,
+		ShardState:        make(map[string]string),
+		ShardLoad:         make(map[string]*smpb.ShardLoad),
+	}
+	for id, state := range d.ShardState {
+		r.ShardState[id] = string(state)
+	}
+	for id, load := range d.ShardLoad {
+		r.ShardLoad[id] = &smpb.ShardLoad{Qps: load.QPS, Bytes: load.Bytes, Cost: load.Cost}
+	}
+	if d.Spec != nil {
+		r.Spec = &smpb.AppSpec{
+			Service:         d.Spec.Service,
+			CreateTime:      d.Spec.CreateTime,
+			MaxShardCount:   int32(d.Spec.MaxShardCount),
+			MaxRecoveryTime: int32(d.Spec.MaxRecoveryTime),
+			Receiver:        d.Spec.Receiver,
+		}
+	}
+	for id, spec := range d.ShardSpec {
+		r.ShardSpec[id] = &smpb.ShardSpec{
+			Id:                spec.Id,
+			Service:           spec.Service,
+			Task:              spec.Task,
+			UpdateTime:        spec.UpdateTime,
+			ManualContainerId: spec.ManualContainerId,
+			Group:             spec.Group,
+			WorkerGroup:       spec.WorkerGroup,
+		}
+	}
+	return &r
+}
+
+func toStringLists(m map[string][]string) map[string]*smpb.StringList {
+	r := make(map[string]*smpb.StringList)
+	for k, v := range m {
+		r[k] = &smpb.StringList{Values: v}
+	}
+	return r
+}
+
+// diffAssignment 按照shard排序，保证推送的顺序稳定
+func diffAssignment(prev, cur map[string]string) []*smpb.AssignmentEvent {
+	var ids []string
+	for id := range prev {
+		ids = append(ids, id)
+	}
+	for id := range cur {
+		if _, ok := prev[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var events []*smpb.AssignmentEvent
+	for _, id := range ids {
+		from, to := prev[id], cur[id]
+		if from == to {
+			continue
+		}
+		event := smpb.AssignmentEvent{ShardId: id, From: from, To: to}
+		switch {
+		case from == "":
+			event.Type = smpb.AssignmentEvent_ASSIGNED
+		case to == "":
+			event.Type = smpb.AssignmentEvent_DROPPED
+		default:
+			event.Type = smpb.AssignmentEvent_MOVED
+		}
+		events = append(events, &event)
+	}
+	return events
+}
@@ -0,0 +1,212 @@
+package smserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/entertainment-venue/sm/pkg/apputil"
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/entertainment-venue/sm/server/smpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestGRPC(t *testing.T) {
+	suite.Run(t, new(GRPCTestSuite))
+}
+
+type GRPCTestSuite struct {
+	suite.Suite
+
+	server *Server
+	conn   *grpc.ClientConn
+	client smpb.SMClient
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// SetupSuite 启动进程内的smserver，依赖本地127.0.0.1:2379的etcd
+func (suite *GRPCTestSuite) SetupSuite() {
+	addr := freeAddr(suite.T())
+	grpcAddr := freeAddr(suite.T())
+
+	var err error
+	suite.server, err = NewServer(
+		WithId(addr),
+		WithService("grpc.test"),
+		WithAddr(addr),
+		WithGRPCAddr(grpcAddr),
+		WithEndpoints([]string{"127.0.0.1:2379"}),
+		WithEtcdPrefix(fmt.Sprintf("/grpc-test-%d", time.Now().UnixNano())),
+	)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.conn, err = grpc.Dial(grpcAddr, grpc.WithInsecure())
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.client = smpb.NewSMClient(suite.conn)
+}
+
+func (suite *GRPCTestSuite) TearDownSuite() {
+	if suite.conn != nil {
+		suite.conn.Close()
+	}
+	if suite.server != nil {
+		suite.server.Close()
+	}
+}
+
+func (suite *GRPCTestSuite) TestSpecAndShard() {
+	ctx := context.TODO()
+	service := "foo.spec"
+
+	_, err := suite.client.AddSpec(ctx, &smpb.AppSpec{Service: service})
+	assert.NoError(suite.T(), err)
+	specs, err := suite.client.GetSpec(ctx, &emptypb.Empty{})
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), specs.Services, service)
+
+	_, err = suite.client.AddShard(ctx, &smpb.AddShardRequest{ShardId: "s1", Service: service, WorkerGroup: "g1"})
+	assert.NoError(suite.T(), err)
+	_, err = suite.client.UpdateShard(ctx, &smpb.UpdateShardRequest{ShardId: "s1", Service: service, Task: "t1", Revision: 1})
+	assert.Equal(suite.T(), codes.Aborted, status.Code(err))
+	uresp, err := suite.client.UpdateShard(ctx, &smpb.UpdateShardRequest{ShardId: "s1", Service: service, Task: "t1", WorkerGroup: "g1"})
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), uresp.Revision > 0)
+
+	_, err = suite.client.AddWorker(ctx, &smpb.WorkerRequest{WorkerGroup: "g1", Service: service, Worker: "127.0.0.1:8801"})
+	assert.NoError(suite.T(), err)
+	workers, err := suite.client.GetWorker(ctx, &smpb.GetWorkerRequest{Service: service})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"127.0.0.1:8801"}, workers.Workers["g1"].Values)
+
+	detail, err := suite.client.Detail(ctx, &smpb.DetailRequest{Service: service})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), service, detail.Spec.Service)
+	assert.Equal(suite.T(), "t1", detail.ShardSpec["s1"].Task)
+	assert.Equal(suite.T(), []string{"s1"}, detail.NotAllocateShards)
+
+	_, err = suite.client.DelShard(ctx, &smpb.DelShardRequest{ShardId: "s1", Service: service})
+	assert.NoError(suite.T(), err)
+	shards, err := suite.client.GetShard(ctx, &smpb.GetShardRequest{Service: service})
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), shards.Shards)
+
+	_, err = suite.client.DelSpec(ctx, &smpb.DelSpecRequest{Service: service})
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *GRPCTestSuite) TestInvalidArgument() {
+	_, err := suite.client.AddShard(context.TODO(), &smpb.AddShardRequest{ShardId: "s1", Service: "not.exist"})
+	assert.Equal(suite.T(), codes.InvalidArgument, status.Code(err))
+}
+
+func (suite *GRPCTestSuite) TestWatchAssignment() {
+	service := "foo.watch"
+	_, err := suite.client.AddSpec(context.TODO(), &smpb.AppSpec{Service: service})
+	assert.NoError(suite.T(), err)
+
+	nm := suite.server.smContainer.nodeManager
+	heartbeat := func(container string, shards ...string) {
+		hb := apputil.ContainerHeartbeat{}
+		for _, shard := range shards {
+			hb.Shards = append(hb.Shards, &storage.ShardKeeperDbValue{Spec: &storage.ShardSpec{Id: shard, Lease: &storage.Lease{}}, Disp: true})
+		}
+		b, _ := json.Marshal(hb)
+		_, err := suite.server.smContainer.Client.Put(context.TODO(), nm.ExternalContainerHbDir(service)+container+"/1", string(b))
+		assert.NoError(suite.T(), err)
+	}
+	heartbeat("c1", "s1")
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	stream, err := suite.client.WatchAssignment(ctx, &smpb.WatchAssignmentRequest{Service: service})
+	assert.NoError(suite.T(), err)
+
+	event, err := stream.Recv()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), smpb.AssignmentEvent_ASSIGNED, event.Type)
+	assert.Equal(suite.T(), "c1", event.To)
+	assert.True(suite.T(), event.Initial)
+
+	heartbeat("c1")
+	event, err = stream.Recv()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), smpb.AssignmentEvent_DROPPED, event.Type)
+	assert.Equal(suite.T(), "c1", event.From)
+	assert.False(suite.T(), event.Initial)
+
+	heartbeat("c2", "s1")
+	event, err = stream.Recv()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), smpb.AssignmentEvent_ASSIGNED, event.Type)
+	assert.Equal(suite.T(), "c2", event.To)
+	assert.True(suite.T(), event.Revision > 0)
+
+	// 窗口内的多次心跳合并为一次汇总
+	heartbeat("c2")
+	heartbeat("c3", "s1")
+	event, err = stream.Recv()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), smpb.AssignmentEvent_MOVED, event.Type)
+	assert.Equal(suite.T(), "c2", event.From)
+	assert.Equal(suite.T(), "c3", event.To)
+}
+
+func (suite *GRPCTestSuite) TestDetail_shardStateAndLoad() {
+	service := "foo.state"
+	_, err := suite.client.AddSpec(context.TODO(), &smpb.AppSpec{Service: service})
+	assert.NoError(suite.T(), err)
+	defer suite.client.DelSpec(context.TODO(), &smpb.DelSpecRequest{Service: service})
+
+	hb := apputil.ContainerHeartbeat{
+		Shards: []*storage.ShardKeeperDbValue{
+			{Spec: &storage.ShardSpec{Id: "s1", Lease: &storage.Lease{}}, Disp: true, State: storage.ShardStateServing, Load: &storage.ShardLoad{QPS: 100, Bytes: 1024, Cost: 2}},
+			{Spec: &storage.ShardSpec{Id: "s2", Lease: &storage.Lease{}}, Disp: true, State: storage.ShardStateStarting},
+			// 旧版本container不上报
+			{Spec: &storage.ShardSpec{Id: "s3", Lease: &storage.Lease{}}, Disp: true},
+		},
+	}
+	nm := suite.server.smContainer.nodeManager
+	_, err = suite.server.smContainer.Client.Put(context.TODO(), nm.ExternalContainerHbDir(service)+"c1/1", hb.String())
+	assert.NoError(suite.T(), err)
+
+	detail, err := suite.client.Detail(context.TODO(), &smpb.DetailRequest{Service: service})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), map[string]string{"s1": "serving", "s2": "starting"}, detail.ShardState)
+	assert.Len(suite.T(), detail.ShardLoad, 1)
+	assert.Equal(suite.T(), 100.0, detail.ShardLoad["s1"].Qps)
+	assert.Equal(suite.T(), int64(1024), detail.ShardLoad["s1"].Bytes)
+}
+
+func Test_diffAssignment(t *testing.T) {
+	events := diffAssignment(
+		map[string]string{"s1": "c1", "s2": "c1", "s3": "c2"},
+		map[string]string{"s1": "c1", "s2": "c2", "s4": "c2"},
+	)
+	assert.Len(t, events, 3)
+	assert.Equal(t, smpb.AssignmentEvent_MOVED, events[0].Type)
+	assert.Equal(t, "s2", events[0].ShardId)
+	assert.Equal(t, smpb.AssignmentEvent_DROPPED, events[1].Type)
+	assert.Equal(t, "s3", events[1].ShardId)
+	assert.Equal(t, smpb.AssignmentEvent_ASSIGNED, events[2].Type)
+	assert.Equal(t, "s4", events[2].ShardId)
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+	"github.com/pkg/errors"
+)
+
+// Inspector 绕过sm集群直接读取etcd，sm不可用时给smctl的只读命令使用，
+// 返回的json和 /sm/server/* 接口的返回一致
+type Inspector struct {
+	client      etcdutil.EtcdWrapper
+	nodeManager *nodeManager
+}
+
+// NewInspector service是sm集群自身的service，etcdPrefix和sm集群的配置保持一致，为空时使用 etcdutil.DefaultPfx
+func NewInspector(client etcdutil.EtcdWrapper, service string, etcdPrefix string) *Inspector {
+	return &Inspector{
+		client:      client,
+		nodeManager: newNodeManager(service, etcdutil.NewPathBuilder(etcdPrefix)),
+	}
+}
+
+// Services 对应 /sm/server/get-spec
+func (i *Inspector) Services(ctx context.Context) ([]string, error) {
+	return loadServices(ctx, i.client, i.nodeManager)
+}
+
+// Detail 对应 /sm/server/detail
+func (i *Inspector) Detail(ctx context.Context, service string) ([]byte, error) {
+	detail, err := loadServiceDetail(ctx, i.client, i.nodeManager, service)
+	if err != nil {
+		if err == errServiceNotExist {
+			return nil, errors.Errorf("service[%s] not exist", service)
+		}
+		return nil, err
+	}
+	b, err := json.Marshal(detail)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return b, nil
+}
+
+// Leader 对应 /sm/server/leader
+func (i *Inspector) Leader(ctx context.Context) ([]byte, error) {
+	leader, err := loadLeader(ctx, i.client, i.nodeManager)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(leader.String()), nil
+}
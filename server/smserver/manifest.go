@@ -0,0 +1,376 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smserver
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+	"github.com/entertainment-venue/sm/pkg/logutil"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	manifestKindSpec   = "spec"
+	manifestKindShard  = "shard"
+	manifestKindWorker = "worker"
+)
+
+var errSpecNotApplied = errors.New("spec not applied")
+
+// serviceManifest 声明式描述一个service，包括spec、全部shard以及worker pool
+type serviceManifest struct {
+	Spec *smAppSpec `json:"spec" yaml:"spec" binding:"required"`
+
+	Shards []*batchShardItem `json:"shards" yaml:"shards"`
+
+	// Workers workerGroup和worker列表的映射
+	Workers map[string][]string `json:"workers" yaml:"workers"`
+}
+
+type manifestChange struct {
+	Kind   string `json:"kind"`
+	Action string `json:"action"`
+	Name   string `json:"name"`
+
+	// Error 为空表示成功，dryRun时不设置
+	Error string `json:"error,omitempty"`
+}
+
+type manifestDiff struct {
+	DryRun bool `json:"dryRun"`
+	Prune  bool `json:"prune"`
+
+	Changes []*manifestChange `json:"changes"`
+
+	// Unchanged 和etcd中一致的shard和worker数量
+	Unchanged int `json:"unchanged"`
+
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// manifestApplier 对比manifest和etcd中的数据，只提交有变化的部分，prune开启时删除manifest中没有的shard和worker
+type manifestApplier struct {
+	api      *smShardApi
+	manifest *serviceManifest
+	prune    bool
+
+	// curSpec 为nil表示service还不存在
+	curSpec     *smAppSpec
+	specRev     int64
+	specChanged bool
+
+	shardRevisions map[string]int64
+	shardOps       map[string][]*batchShardOp
+
+	workerAdds []string
+	workerDels []string
+
+	diff manifestDiff
+	// changes kind/name和变化的映射，提交失败时设置结果
+	changes map[string]*manifestChange
+}
+
+func newManifestApplier(api *smShardApi, manifest *serviceManifest, prune bool) *manifestApplier {
+	return &manifestApplier{
+		api:            api,
+		manifest:       manifest,
+		prune:          prune,
+		shardRevisions: make(map[string]int64),
+		shardOps:       make(map[string][]*batchShardOp),
+		diff:           manifestDiff{Prune: prune, Changes: []*manifestChange{}},
+		changes:        make(map[string]*manifestChange),
+	}
+}
+
+func (ma *manifestApplier) validate() error {
+	service := ma.manifest.Spec.Service
+	if service == "" {
+		return errors.New("empty service")
+	}
+	if service == ma.api.container.Service() {
+		return errors.New("same as shard manager's service")
+	}
+	if err := ma.manifest.Spec.Validate(); err != nil {
+		return err
+	}
+	if len(ma.manifest.Shards) > 0 {
+		batch := shardBatch{service: service}
+		var ops []*batchShardOp
+		for _, item := range ma.manifest.Shards {
+			if item == nil {
+				return errors.Wrap(errShardIdInvalid, "nil shard")
+			}
+			ops = append(ops, &batchShardOp{ShardId: item.ShardId})
+		}
+		if err := batch.validate(ops); err != nil {
+			return err
+		}
+	}
+	for group, workers := range ma.manifest.Workers {
+		if group == "" || strings.Contains(group, "/") {
+			return errors.Errorf("invalid worker group [%s]", group)
+		}
+		for _, worker := range workers {
+			if worker == "" || strings.Contains(worker, "/") {
+				return errors.Errorf("invalid worker [%s] in group [%s]", worker, group)
+			}
+		}
+	}
+	return nil
+}
+
+// plan 读取etcd中的当前状态，计算需要提交的变化
+func (ma *manifestApplier) plan(ctx context.Context) error {
+	var (
+		container = ma.api.container
+		service   = ma.manifest.Spec.Service
+	)
+
+	resp, err := container.Client.Get(ctx, container.nodeManager.ServiceSpecPath(service))
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	if len(resp.Kvs) == 0 {
+		ma.addChange(manifestKindSpec, batchActionAdd, service)
+	} else {
+		var cur smAppSpec
+		if err := json.Unmarshal(resp.Kvs[0].Value, &cur); err != nil {
+			return errors.Wrap(err, "")
+		}
+		ma.curSpec = &cur
+		ma.specRev = resp.Kvs[0].ModRevision
+		if cur.MaxShardCount != ma.manifest.Spec.MaxShardCount || cur.MaxRecoveryTime != ma.manifest.Spec.MaxRecoveryTime || cur.Receiver != ma.manifest.Spec.Receiver {
+			ma.specChanged = true
+			ma.addChange(manifestKindSpec, batchActionUpdate, service)
+		}
+	}
+
+	// shard
+	pfx := container.nodeManager.ShardDir(service)
+	resp, err = container.Client.Get(ctx, pfx, clientv3.WithPrefix())
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	curShards := make(map[string]*storage.ShardSpec)
+	for _, kv := range resp.Kvs {
+		var spec storage.ShardSpec
+		if err := json.Unmarshal(kv.Value, &spec); err != nil {
+			return errors.Wrap(err, "")
+		}
+		shardId := strings.TrimPrefix(string(kv.Key), pfx)
+		curShards[shardId] = &spec
+		ma.shardRevisions[shardId] = kv.ModRevision
+	}
+	now := time.Now().Unix()
+	expect := make(map[string]struct{})
+	for _, item := range ma.manifest.Shards {
+		expect[item.ShardId] = struct{}{}
+		spec := storage.ShardSpec{
+			Service:           service,
+			Task:              item.Task,
+			UpdateTime:        now,
+			ManualContainerId: item.ManualContainerId,
+			Group:             item.Group,
+			WorkerGroup:       item.WorkerGroup,
+		}
+		op := &batchShardOp{ShardId: item.ShardId, Value: spec.String()}
+
+		cur, ok := curShards[item.ShardId]
+		switch {
+		case !ok:
+			ma.shardOps[batchActionAdd] = append(ma.shardOps[batchActionAdd], op)
+			ma.addChange(manifestKindShard, batchActionAdd, item.ShardId)
+		case cur.Task != spec.Task || cur.ManualContainerId != spec.ManualContainerId || cur.Group != spec.Group || cur.WorkerGroup != spec.WorkerGroup:
+			ma.shardOps[batchActionUpdate] = append(ma.shardOps[batchActionUpdate], op)
+			ma.addChange(manifestKindShard, batchActionUpdate, item.ShardId)
+		default:
+			ma.diff.Unchanged++
+		}
+	}
+	if ma.prune {
+		var dels []string
+		for shardId := range curShards {
+			if _, ok := expect[shardId]; !ok {
+				dels = append(dels, shardId)
+			}
+		}
+		sort.Strings(dels)
+		for _, shardId := range dels {
+			ma.shardOps[batchActionDel] = append(ma.shardOps[batchActionDel], &batchShardOp{ShardId: shardId})
+			ma.addChange(manifestKindShard, batchActionDel, shardId)
+		}
+	}
+
+	// worker
+	wpfx := container.nodeManager.WorkerGroupPath(service) + "/"
+	resp, err = container.Client.Get(ctx, wpfx, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	curWorkers := make(map[string]struct{})
+	for _, kv := range resp.Kvs {
+		curWorkers[strings.TrimPrefix(string(kv.Key), wpfx)] = struct{}{}
+	}
+	var groups []string
+	for group := range ma.manifest.Workers {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	expectWorkers := make(map[string]struct{})
+	for _, group := range groups {
+		for _, worker := range ma.manifest.Workers[group] {
+			name := path.Join(group, worker)
+			expectWorkers[name] = struct{}{}
+			if _, ok := curWorkers[name]; ok {
+				ma.diff.Unchanged++
+				continue
+			}
+			ma.workerAdds = append(ma.workerAdds, name)
+			ma.addChange(manifestKindWorker, batchActionAdd, name)
+		}
+	}
+	if ma.prune {
+		var dels []string
+		for name := range curWorkers {
+			if _, ok := expectWorkers[name]; !ok {
+				dels = append(dels, name)
+			}
+		}
+		sort.Strings(dels)
+		for _, name := range dels {
+			ma.workerDels = append(ma.workerDels, name)
+			ma.addChange(manifestKindWorker, batchActionDel, name)
+		}
+	}
+	return nil
+}
+
+// apply 按照spec、shard、worker的顺序提交，spec失败时不再提交其他变化
+func (ma *manifestApplier) apply(ctx context.Context) {
+	var (
+		container = ma.api.container
+		service   = ma.manifest.Spec.Service
+		specErr   error
+	)
+
+	switch {
+	case ma.curSpec == nil:
+		spec := *ma.manifest.Spec
+		spec.CreateTime = time.Now().Unix()
+		nodes, values := ma.api.specNodes(&spec)
+		specErr = container.Client.CreateAndGet(ctx, nodes, values, clientv3.NoLease)
+	case ma.specChanged:
+		spec := *ma.manifest.Spec
+		spec.CreateTime = ma.curSpec.CreateTime
+		key := container.nodeManager.ServiceSpecPath(service)
+		resp, err := container.Client.CommitTxn(
+			ctx,
+			[]clientv3.Cmp{clientv3.Compare(clientv3.ModRevision(key), "=", ma.specRev)},
+			[]clientv3.Op{clientv3.OpPut(key, spec.String())},
+		)
+		if err == nil && !resp.Succeeded {
+			err = etcdutil.ErrEtcdValueNotMatch
+		}
+		specErr = err
+	}
+	if specErr != nil {
+		ma.setError(manifestKindSpec, service, specErr)
+		if ma.curSpec == nil {
+			for _, change := range ma.diff.Changes {
+				if change.Kind != manifestKindSpec {
+					change.Error = errSpecNotApplied.Error()
+				}
+			}
+			return
+		}
+	}
+
+	for _, action := range []string{batchActionAdd, batchActionUpdate, batchActionDel} {
+		batch := shardBatch{container: container, service: service, action: action}
+		for _, r := range batch.commitAll(ctx, ma.shardOps[action], ma.shardRevisions) {
+			if r.Error != "" {
+				ma.setError(manifestKindShard, r.ShardId, errors.New(r.Error))
+			}
+		}
+	}
+
+	ma.commitWorkers(ctx, ma.workerAdds, func(name string) clientv3.Op {
+		return clientv3.OpPut(path.Join(container.nodeManager.WorkerGroupPath(service), name), "")
+	})
+	ma.commitWorkers(ctx, ma.workerDels, func(name string) clientv3.Op {
+		return clientv3.OpDelete(path.Join(container.nodeManager.WorkerGroupPath(service), name))
+	})
+}
+
+// commitWorkers worker节点没有内容，add和del都是幂等的，不需要compare
+func (ma *manifestApplier) commitWorkers(ctx context.Context, names []string, opFn func(name string) clientv3.Op) {
+	for start := 0; start < len(names); start += etcdutil.DefaultMaxTxnOps {
+		end := start + etcdutil.DefaultMaxTxnOps
+		if end > len(names) {
+			end = len(names)
+		}
+		var ops []clientv3.Op
+		for _, name := range names[start:end] {
+			ops = append(ops, opFn(name))
+		}
+		if _, err := ma.api.container.Client.CommitTxn(ctx, nil, ops); err != nil {
+			for _, name := range names[start:end] {
+				ma.setError(manifestKindWorker, name, err)
+			}
+		}
+	}
+}
+
+func (ma *manifestApplier) addChange(kind, action, name string) {
+	change := &manifestChange{Kind: kind, Action: action, Name: name}
+	ma.diff.Changes = append(ma.diff.Changes, change)
+	ma.changes[path.Join(kind, name)] = change
+}
+
+func (ma *manifestApplier) setError(kind, name string, err error) {
+	if change, ok := ma.changes[path.Join(kind, name)]; ok {
+		change.Error = err.Error()
+	}
+	logutil.Warn(
+		"manifest change failed",
+		zap.String("service", ma.manifest.Spec.Service),
+		zap.String("kind", kind),
+		zap.String("name", name),
+		zap.Error(err),
+	)
+}
+
+func (ma *manifestApplier) summary() *manifestDiff {
+	if !ma.diff.DryRun {
+		for _, change := range ma.diff.Changes {
+			if change.Error == "" {
+				ma.diff.Succeeded++
+			} else {
+				ma.diff.Failed++
+			}
+		}
+	}
+	return &ma.diff
+}
@@ -2,16 +2,17 @@ package smserver
 
 import (
 	"context"
-	"encoding/json"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/entertainment-venue/sm/pkg/apputil"
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
 	"github.com/entertainment-venue/sm/pkg/commonutil"
 	"github.com/entertainment-venue/sm/pkg/etcdutil"
 	"github.com/entertainment-venue/sm/pkg/logutil"
 	"github.com/pkg/errors"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
 )
@@ -19,14 +20,22 @@ import (
 const (
 	// 通过key的区分，在UpdateState中区分情况处理，降低代码冗余
 	containerTrigger = "containerTrigger"
+	// expireTrigger container等待恢复超时的事件，和心跳事件在同一个trigger中顺序处理
+	expireTrigger = "expireTrigger"
+	// resyncDeleteTrigger watch丢失事件后，快照中已经不存在的container
+	resyncDeleteTrigger = "resyncDeleteTrigger"
 
-	// triggerWorkerSize 必须是1，事件顺序执行
-	triggerWorkerSize = 1
+	// triggerWorkerSize 同一个container的事件按照containerId哈希到同一个goroutine顺序执行，不同container之间可以并发，
+	// 不同container的事件之间通过 temporary.revision 判断shard归属的先后
+	triggerWorkerSize = 4
 
 	// defaultMaxRecoveryTime 不设定，需要提供默认阈值，理论上应该能应对大多应用的重启时间
 	defaultMaxRecoveryTime = 10 * time.Second
 	// maxRecoveryWaitTime 给个上限，防止异常情况导致等待时间过长问题排查难
 	maxRecoveryWaitTime = 30 * time.Second
+
+	// defaultSnapshotTimeout 增量心跳展开时从etcd获取全量心跳的超时
+	defaultSnapshotTimeout = 3 * time.Second
 )
 
 // mapper leader或者follower都需要构建当前分片应用的映射关系
@@ -36,7 +45,8 @@ const (
 // 3. 代理mw对etcd的访问，分摊部分mw的逻辑
 type mapper struct {
 	container *smContainer
-	shard     *smShard
+	// shard 为nil时，mapper是follower上的standby mapper，不校验shard的lease，只保留心跳状态
+	shard *smShard
 
 	// appSpec 配置中有container单节点恢复阈值，影响当前service事件处理的方式
 	appSpec *smAppSpec
@@ -48,10 +58,15 @@ type mapper struct {
 	containerState *mapperState
 	// shardState 存活shard
 	shardState *mapperState
+	// snapshots 紧凑格式下每个container最近的全量心跳，用于展开增量心跳
+	snapshots map[string]*heartbeatSnapshot
 
 	// trigger 事件存储在内存中的队列里，逐一执行，尽量不卡在etcd，因为事件丢失是可恢复的
 	trigger commonutil.Trigger
 
+	// delayQueue container删除后等待maxRecoveryTime，到期后通过trigger删除，不阻塞其他事件的处理
+	delayQueue *commonutil.DelayQueue
+
 	// stopper 管理watch goroutine
 	stopper *commonutil.GoroutineStopper
 }
@@ -67,17 +82,19 @@ func newMapper(container *smContainer, appSpec *smAppSpec, shard *smShard) (*map
 		shardState:     newMapperState(),
 	}
 
+	// 同一个container的心跳只需要处理最新的，删除事件和心跳事件合并后只保留最后的状态
 	trigger, _ := commonutil.NewTrigger(
 		commonutil.WithWorkerSize(triggerWorkerSize),
+		commonutil.WithOrdered(true),
+		commonutil.WithCoalesce(true),
 	)
 	mpr.trigger = trigger
 	_ = mpr.trigger.Register(containerTrigger, mpr.UpdateState)
+	_ = mpr.trigger.Register(expireTrigger, mpr.Expire)
+	_ = mpr.trigger.Register(resyncDeleteTrigger, mpr.resyncDelete)
+	mpr.delayQueue = commonutil.NewDelayQueue(mpr.expireCallback)
 
-	if mpr.appSpec.MaxRecoveryTime <= 0 || time.Duration(mpr.appSpec.MaxRecoveryTime)*time.Second > maxRecoveryWaitTime {
-		mpr.maxRecoveryTime = defaultMaxRecoveryTime
-	} else {
-		mpr.maxRecoveryTime = time.Duration(mpr.appSpec.MaxRecoveryTime) * time.Second
-	}
+	mpr.maxRecoveryTime = recoveryTime(appSpec)
 
 	if err := mpr.initAndWatch(); err != nil {
 		return nil, errors.Wrap(err, "")
@@ -86,11 +103,36 @@ func newMapper(container *smContainer, appSpec *smAppSpec, shard *smShard) (*map
 	logutil.Info(
 		"mapper started",
 		zap.String("service", mpr.appSpec.Service),
+		zap.Bool("standby", shard == nil),
 	)
 
 	return &mpr, nil
 }
 
+// recoveryTime container删除后等待恢复的时间
+func recoveryTime(appSpec *smAppSpec) time.Duration {
+	if appSpec.MaxRecoveryTime <= 0 || time.Duration(appSpec.MaxRecoveryTime)*time.Second > maxRecoveryWaitTime {
+		return defaultMaxRecoveryTime
+	}
+	return time.Duration(appSpec.MaxRecoveryTime) * time.Second
+}
+
+// attach standby mapper交给 smShard 使用，保留心跳状态和等待中的恢复计时
+func (mpr *mapper) attach(shard *smShard, appSpec *smAppSpec) {
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+	mpr.shard = shard
+	mpr.appSpec = appSpec
+	mpr.maxRecoveryTime = recoveryTime(appSpec)
+}
+
+// detach smShard 关闭后，mapper回到standby状态，继续维护心跳状态
+func (mpr *mapper) detach() {
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+	mpr.shard = nil
+}
+
 func (mpr *mapper) extractId(key string) string {
 	// https://github.com/entertainment-venue/sm/commit/77c6ba8d36196b6fa5a115483083ae9777f70c7d
 	// 目录结构引入mutex，导致有变化，id在倒数第二段
@@ -114,9 +156,25 @@ func (mpr *mapper) initAndWatch() error {
 	}
 	startRev := resp.Header.Revision + 1
 
+	// 加载当前的心跳，新的leader不需要等待下一次心跳才能得到存活的container
+	for _, kv := range resp.Kvs {
+		if string(kv.Key) == pfx {
+			continue
+		}
+		ev := clientv3.Event{Type: clientv3.EventTypePut, Kv: kv}
+		if err := mpr.Refresh(mpr.extractId(string(kv.Key)), &ev); err != nil {
+			logutil.Warn(
+				"load heartbeat error",
+				zap.String("service", mpr.appSpec.Service),
+				zap.ByteString("key", kv.Key),
+				zap.Error(err),
+			)
+		}
+	}
+
 	mpr.stopper.Wrap(
 		func(ctx context.Context) {
-			etcdutil.WatchLoop(
+			etcdutil.WatchLoopWithResync(
 				ctx,
 				mpr.container.Client,
 				pfx,
@@ -128,11 +186,17 @@ func (mpr *mapper) initAndWatch() error {
 					}
 
 					// 事件写入evtrigger，理论上不会漏事件，除非event不合法
-					if err := mpr.trigger.Put(&commonutil.TriggerEvent{Key: containerTrigger, Value: ev}); err != nil {
+					tev := commonutil.TriggerEvent{
+						Key:          containerTrigger,
+						Value:        ev,
+						PartitionKey: mpr.extractId(string(ev.Kv.Key)),
+					}
+					if err := mpr.trigger.Put(&tev); err != nil {
 						return errors.Wrap(err, "")
 					}
 					return nil
 				},
+				mpr.resync,
 			)
 		},
 	)
@@ -144,6 +208,51 @@ func (mpr *mapper) initAndWatch() error {
 	return nil
 }
 
+// resync watch丢失事件后，用心跳节点的快照修正状态，快照中的container按照心跳事件处理，
+// 快照中不存在的container按照删除处理，都交给trigger，和watch事件保持顺序
+func (mpr *mapper) resync(_ context.Context, resp *clientv3.GetResponse) error {
+	pfx := mpr.container.nodeManager.ExternalContainerHbDir(mpr.appSpec.Service)
+	present := make(map[string]struct{})
+	for _, kv := range resp.Kvs {
+		if string(kv.Key) == pfx {
+			continue
+		}
+		containerId := mpr.extractId(string(kv.Key))
+		present[containerId] = struct{}{}
+		tev := commonutil.TriggerEvent{
+			Key:          containerTrigger,
+			Value:        &clientv3.Event{Type: clientv3.EventTypePut, Kv: kv},
+			PartitionKey: containerId,
+		}
+		if err := mpr.trigger.Put(&tev); err != nil {
+			return errors.Wrap(err, "")
+		}
+	}
+
+	var deleted []string
+	for containerId := range mpr.AliveContainers() {
+		if _, ok := present[containerId]; ok {
+			continue
+		}
+		deleted = append(deleted, containerId)
+		tev := commonutil.TriggerEvent{Key: resyncDeleteTrigger, Value: containerId, PartitionKey: containerId}
+		if err := mpr.trigger.Put(&tev); err != nil {
+			return errors.Wrap(err, "")
+		}
+	}
+	logutil.Info(
+		"state resync",
+		zap.String("service", mpr.appSpec.Service),
+		zap.Int("present", len(present)),
+		zap.Strings("deleted", deleted),
+	)
+	return nil
+}
+
+func (mpr *mapper) resyncDelete(_ string, value interface{}) error {
+	return mpr.schedule(value.(string))
+}
+
 func (mpr *mapper) AliveContainers() ArmorMap {
 	mpr.mu.Lock()
 	defer mpr.mu.Unlock()
@@ -157,11 +266,28 @@ func (mpr *mapper) AliveContainers() ArmorMap {
 	return r
 }
 
+// LeavingContainers 正在优雅退出的container，不参与rb，其上的shard会被迁移走
+func (mpr *mapper) LeavingContainers() ArmorMap {
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+
+	r := make(ArmorMap)
+	for id, tmp := range mpr.containerState.alive {
+		if tmp.leaving {
+			r[id] = ""
+		}
+	}
+	return r
+}
+
 func (mpr *mapper) AliveShards() map[string]*temporary {
 	mpr.mu.Lock()
 	defer mpr.mu.Unlock()
 
 	r := make(map[string]*temporary)
+	if mpr.shard == nil {
+		return r
+	}
 	collectId := func(id string, tmp *temporary) error {
 		if tmp.leaseID == mpr.shard.guardLeaseID {
 			r[id] = tmp
@@ -178,6 +304,7 @@ func (mpr *mapper) Close() {
 	if mpr.stopper != nil {
 		mpr.stopper.Close()
 	}
+	mpr.delayQueue.Close()
 	mpr.trigger.Close()
 }
 
@@ -186,53 +313,121 @@ func (mpr *mapper) UpdateState(_ string, value interface{}) error {
 
 	containerId := mpr.extractId(string(event.Kv.Key))
 	if event.IsCreate() || event.IsModify() {
+		// container恢复心跳，取消等待中的删除
+		mpr.delayQueue.Cancel(containerId)
+
 		// 需要更新container或者shard的存活事件
 		return mpr.Refresh(containerId, event)
 	}
 
-	// container故障(短暂的网络、硬件问题等等)，或者重启
-	if err := mpr.Wait(containerId); err != nil {
+	// container优雅退出，shard已经迁移走，不需要等待恢复
+	if mpr.leaving(containerId) {
+		return mpr.Delete(containerId)
+	}
+
+	// container故障(短暂的网络、硬件问题等等)，或者重启，等待恢复的过程不阻塞其他事件
+	return mpr.schedule(containerId)
+}
+
+// schedule 迟到的删除事件对应的container已经不存在，不算错误
+func (mpr *mapper) schedule(containerId string) error {
+	if err := mpr.Schedule(containerId); err != nil && err != commonutil.ErrNotExist {
 		return err
 	}
+	return nil
+}
+
+// heartbeatSnapshot 紧凑格式的全量心跳，revision是写入etcd时的revision
+type heartbeatSnapshot struct {
+	revision int64
+	hb       *apputil.ContainerHeartbeat
+}
+
+// getHeartbeatSnapshot 从etcd的历史版本中获取增量心跳对应的全量心跳，
+// container每隔几次心跳就会写入全量心跳，etcd compact之前都能获取到
+func getHeartbeatSnapshot(ctx context.Context, client etcdutil.EtcdWrapper, key string, revision int64) (*apputil.ContainerHeartbeat, error) {
+	resp, err := client.Get(ctx, key, clientv3.WithRev(revision))
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	if len(resp.Kvs) == 0 || resp.Kvs[0].ModRevision != revision {
+		return nil, errors.Errorf("heartbeat snapshot not found, key %s revision %d", key, revision)
+	}
+	snapshot, err := apputil.DecodeContainerHeartbeat(resp.Kvs[0].Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	if snapshot.IsDelta() {
+		return nil, errors.Errorf("heartbeat snapshot is delta, key %s revision %d", key, revision)
+	}
+	return snapshot, nil
+}
 
-	// 通过后面的时间检测是否container已经回来，这块有两种方案：
-	// 1. 轮询探测
-	// 2. 遍历evtrigger中的事件，如果有该container的put事件，直接return，否则删除掉
-	// 3. 可能会出现该container最终需要删除，但是存在put事件，轮询的时候直接返回了，导致效率可能不是很高。
-	// 这里不做最终是否删除判断，等最后一个删除事件自己删除即可
-	var hasCreate bool
-	findCreate := func(it interface{}) error {
-		triggerEvent := it.(*commonutil.TriggerEvent)
-		ev := triggerEvent.Value.(*clientv3.Event)
-		if mpr.extractId(string(ev.Kv.Key)) == containerId && ev.IsCreate() {
-			hasCreate = true
+// decodeHeartbeat 兼容完整格式和紧凑格式，增量心跳展开为全量，展开失败时返回未展开的心跳和error，
+// 调用方不能持有mu，从etcd获取全量心跳期间不阻塞其他读取mapper状态的goroutine
+func (mpr *mapper) decodeHeartbeat(containerId string, kv *mvccpb.KeyValue) (*apputil.ContainerHeartbeat, error) {
+	hb, err := apputil.DecodeContainerHeartbeat(kv.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	if !hb.IsDelta() {
+		if len(hb.Shards) == 0 {
+			// 完整格式的心跳不会有增量，只记录紧凑格式
+			mpr.setSnapshot(containerId, &heartbeatSnapshot{revision: kv.ModRevision, hb: hb})
 		}
-		return nil
+		return hb, nil
 	}
-	_ = mpr.trigger.ForEach(findCreate)
-	if hasCreate {
-		logutil.Info(
-			"container recovery",
-			zap.String("service", mpr.appSpec.Service),
-			zap.String("containerId", containerId),
-		)
-		return nil
+
+	// 心跳事件会被合并，container的全量心跳可能没有被处理过
+	mpr.mu.Lock()
+	snapshot, ok := mpr.snapshots[containerId]
+	mpr.mu.Unlock()
+	if !ok || snapshot.revision != hb.BaseRevision {
+		ctx, cancel := context.WithTimeout(context.TODO(), defaultSnapshotTimeout)
+		base, err := getHeartbeatSnapshot(ctx, mpr.container.Client, string(kv.Key), hb.BaseRevision)
+		cancel()
+		if err != nil {
+			return hb, err
+		}
+		snapshot = &heartbeatSnapshot{revision: hb.BaseRevision, hb: base}
+		mpr.setSnapshot(containerId, snapshot)
+	}
+	if err := hb.Merge(snapshot.hb); err != nil {
+		return hb, errors.Wrap(err, "")
 	}
+	return hb, nil
+}
 
-	return mpr.Delete(containerId)
+func (mpr *mapper) setSnapshot(containerId string, snapshot *heartbeatSnapshot) {
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+	if mpr.snapshots == nil {
+		mpr.snapshots = make(map[string]*heartbeatSnapshot)
+	}
+	mpr.snapshots[containerId] = snapshot
+}
+
+func (mpr *mapper) leaving(containerId string) bool {
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+	cur, ok := mpr.containerState.alive[containerId]
+	return ok && cur.leaving
 }
 
 // create 4 unit test
 func (mpr *mapper) create(containerId string, value []byte) error {
-	var ctrHb apputil.ContainerHeartbeat
-	if err := json.Unmarshal(value, &ctrHb); err != nil {
+	ctrHb, err := mpr.decodeHeartbeat(containerId, &mvccpb.KeyValue{Value: value})
+	if err != nil {
 		return errors.Wrap(err, string(value))
 	}
 
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+
 	mpr.containerState.alive[containerId] = newTemporary(ctrHb.Timestamp)
-	for _, shard := range ctrHb.Shards {
-		mpr.shardState.alive[shard.Spec.Id] = newTemporary(ctrHb.Timestamp)
-		mpr.shardState.alive[shard.Spec.Id].curContainerId = containerId
+	for _, shard := range ctrHb.ShardSummaries() {
+		mpr.shardState.alive[shard.Id] = newTemporary(ctrHb.Timestamp)
+		mpr.shardState.alive[shard.Id].curContainerId = containerId
 	}
 
 	logutil.Info(
@@ -240,7 +435,7 @@ func (mpr *mapper) create(containerId string, value []byte) error {
 		zap.String("service", mpr.appSpec.Service),
 		zap.String("containerId", containerId),
 		zap.String("timestamp", time.Unix(ctrHb.Timestamp, 0).String()),
-		zap.Reflect("shards", ctrHb.Shards),
+		zap.Reflect("shards", ctrHb.ShardSummaries()),
 	)
 	return nil
 }
@@ -250,6 +445,7 @@ func (mpr *mapper) Delete(containerId string) error {
 	defer mpr.mu.Unlock()
 
 	delete(mpr.containerState.alive, containerId)
+	delete(mpr.snapshots, containerId)
 
 	// TODO shard多的场景会慢，海量key的场景，单container存巨量shard的时候
 	var shardIds []string
@@ -270,9 +466,6 @@ func (mpr *mapper) Delete(containerId string) error {
 }
 
 func (mpr *mapper) Refresh(containerId string, event *clientv3.Event) error {
-	mpr.mu.Lock()
-	defer mpr.mu.Unlock()
-
 	if event.Kv.Value == nil {
 		logutil.Warn(
 			"empty value",
@@ -283,38 +476,69 @@ func (mpr *mapper) Refresh(containerId string, event *clientv3.Event) error {
 		return nil
 	}
 
-	var ctrHb apputil.ContainerHeartbeat
-	if err := json.Unmarshal(event.Kv.Value, &ctrHb); err != nil {
-		return errors.Wrap(err, string(event.Kv.Value))
+	// 展开增量心跳可能需要访问etcd，在加锁之前完成
+	ctrHb, err := mpr.decodeHeartbeat(containerId, event.Kv)
+
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+	if err != nil {
+		if ctrHb != nil {
+			// 增量心跳无法展开，container仍然存活，shard保持上次的状态，等待下次全量心跳
+			ct := newTemporary(ctrHb.Timestamp)
+			ct.leaving = ctrHb.Leaving
+			mpr.containerState.alive[containerId] = ct
+		}
+		return errors.Wrap(err, "")
 	}
 
 	// container
-	mpr.containerState.alive[containerId] = newTemporary(ctrHb.Timestamp)
+	ct := newTemporary(ctrHb.Timestamp)
+	ct.leaving = ctrHb.Leaving
+	mpr.containerState.alive[containerId] = ct
 	tmpHbShardsMap := make(map[string]string)
 
 	// shard 带有不合法lease的shard，不能认为存活，要触发rb，重新走drop和add
 	// shardkeeper 的作用是尽可能传递合法shard
-	for _, shard := range ctrHb.Shards {
-		tmpHbShardsMap[shard.Spec.Id] = ""
-		if shard.Spec.Lease.ID == mpr.shard.guardLeaseID || shard.Spec.Lease.ID == mpr.shard.bridgeLeaseID {
+	shards := ctrHb.ShardSummaries()
+	for _, shard := range shards {
+		tmpHbShardsMap[shard.Id] = ""
+		// standby状态下，lease的校验交给 AliveShards 在attach后完成
+		if mpr.shard == nil || shard.LeaseID == mpr.shard.guardLeaseID || shard.LeaseID == mpr.shard.bridgeLeaseID {
+			// 不同container的事件并发处理，其他container更新的心跳已经处理过时，当前的心跳已经过时
+			if cur, ok := mpr.shardState.alive[shard.Id]; ok && cur.curContainerId != containerId && cur.revision > event.Kv.ModRevision {
+				logutil.Info(
+					"stale shard heartbeat ignored",
+					zap.String("service", mpr.appSpec.Service),
+					zap.String("containerID", containerId),
+					zap.String("shardID", shard.Id),
+					zap.String("curContainerID", cur.curContainerId),
+					zap.Int64("revision", event.Kv.ModRevision),
+					zap.Int64("curRevision", cur.revision),
+				)
+				continue
+			}
 			t := newTemporary(ctrHb.Timestamp)
+			t.revision = event.Kv.ModRevision
 			t.curContainerId = containerId
-			t.leaseID = shard.Spec.Lease.ID
-			mpr.shardState.alive[shard.Spec.Id] = t
+			t.leaseID = shard.LeaseID
+			t.taskHash = shard.TaskHash
+			t.state = shard.State
+			t.load = shard.Load
+			mpr.shardState.alive[shard.Id] = t
 
 			logutil.Info(
 				"state shard refreshed",
 				zap.String("service", mpr.appSpec.Service),
 				zap.String("containerID", containerId),
-				zap.String("shardID", shard.Spec.Id),
-				zap.Int64("shardLeaseID", int64(shard.Spec.Lease.ID)),
+				zap.String("shardID", shard.Id),
+				zap.Int64("shardLeaseID", int64(shard.LeaseID)),
 			)
 		} else {
 			logutil.Info(
 				"found shard with invalid lease from container",
 				zap.String("service", mpr.appSpec.Service),
 				zap.String("containerID", containerId),
-				zap.Reflect("shardLeaseID", shard.Spec.Lease),
+				zap.Int64("shardLeaseID", int64(shard.LeaseID)),
 				zap.Int64("guardLeaseID", int64(mpr.shard.guardLeaseID)),
 			)
 		}
@@ -344,46 +568,72 @@ func (mpr *mapper) Refresh(containerId string, event *clientv3.Event) error {
 		"state refreshed",
 		zap.String("service", mpr.appSpec.Service),
 		zap.String("containerId", containerId),
-		zap.Reflect("shards", ctrHb.Shards),
+		zap.Reflect("shards", shards),
 		zap.String("timestamp", time.Unix(ctrHb.Timestamp, 0).String()),
 	)
 	return nil
 }
 
-func (mpr *mapper) Wait(containerId string) error {
+// Schedule container的心跳节点被删除，等待maxRecoveryTime之后再删除，
+// 期间container恢复心跳会取消删除，应对container重启的场景
+func (mpr *mapper) Schedule(containerId string) error {
 	mpr.mu.Lock()
 	defer mpr.mu.Unlock()
 
 	cur, ok := mpr.containerState.alive[containerId]
 	if !ok {
-		logutil.Info(
+		logutil.Debug(
 			"not found in alive container",
 			zap.String("service", mpr.appSpec.Service),
 			zap.String("containerId", containerId),
 		)
-		return nil
+		return commonutil.ErrNotExist
 	}
+	cur.deleted = true
 
-	// 判断是否需要等待一会再处理该事件，队列中的事件在第一个等待事件完结后，可能都已经达到需要被处理的时间点
-	timeElapsed := time.Since(cur.lastHeartbeatTime)
-	waitTime := mpr.maxRecoveryTime - timeElapsed
-	if waitTime > 0 {
-		logutil.Info(
-			"wait until timeout",
+	expireAt := cur.lastHeartbeatTime.Add(mpr.maxRecoveryTime)
+	mpr.delayQueue.Schedule(containerId, expireAt)
+	logutil.Info(
+		"wait until timeout",
+		zap.String("service", mpr.appSpec.Service),
+		zap.String("containerId", containerId),
+		zap.Time("expireAt", expireAt),
+		zap.Duration("maxRecoveryTime", mpr.maxRecoveryTime),
+	)
+	return nil
+}
+
+// expireCallback 在 DelayQueue 的goroutine中执行，事件交给trigger，和心跳事件顺序处理
+func (mpr *mapper) expireCallback(containerId string) {
+	tev := commonutil.TriggerEvent{Key: expireTrigger, Value: containerId, PartitionKey: containerId}
+	if err := mpr.trigger.Put(&tev); err != nil {
+		logutil.Error(
+			"put expire event error",
 			zap.String("service", mpr.appSpec.Service),
 			zap.String("containerId", containerId),
-			zap.Duration("wait", waitTime),
-			zap.Duration("maxRecoveryTime", mpr.maxRecoveryTime),
-			zap.Duration("timeElapsed", timeElapsed),
+			zap.Error(err),
 		)
-		time.Sleep(waitTime)
+	}
+}
+
+// Expire container等待恢复超时，期间没有恢复心跳的情况下删除
+func (mpr *mapper) Expire(_ string, value interface{}) error {
+	containerId := value.(string)
+
+	mpr.mu.Lock()
+	cur, ok := mpr.containerState.alive[containerId]
+	deleted := ok && cur.deleted
+	mpr.mu.Unlock()
+
+	if !deleted {
 		logutil.Info(
-			"wait completed",
+			"container recovery",
 			zap.String("service", mpr.appSpec.Service),
 			zap.String("containerId", containerId),
 		)
+		return nil
 	}
-	return nil
+	return mpr.Delete(containerId)
 }
 
 type temporary struct {
@@ -393,8 +643,26 @@ type temporary struct {
 	// curContainerId 针对shard场景，需要存储当前所属containerId，用于做rb
 	curContainerId string
 
+	// revision 设置curContainerId的心跳在etcd中的revision，过时的心跳不能覆盖其他container的归属
+	revision int64
+
 	// leaseID 表示当前shard的合法性
 	leaseID clientv3.LeaseID
+
+	// taskHash 心跳上报的shard工作内容的hash，和配置不一致时原地更新，见 storage.TaskHash
+	taskHash uint32
+
+	// state 心跳上报的shard生命周期状态，旧版本的container为空，rb时优先移动不健康的shard
+	state storage.ShardState
+
+	// load 心跳上报的shard负载，app没有实现 core.ShardLoadReporter 时为nil，rb时决定移动哪些shard以及移动到哪里
+	load *storage.ShardLoad
+
+	// deleted container的心跳节点已经被删除，等待恢复中
+	deleted bool
+
+	// leaving container正在优雅退出，见 apputil.Container.Shutdown
+	leaving bool
 }
 
 func newTemporary(t int64) *temporary {
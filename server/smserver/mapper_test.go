@@ -1,6 +1,7 @@
 package smserver
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/entertainment-venue/sm/pkg/apputil"
 	"github.com/entertainment-venue/sm/pkg/apputil/storage"
 	"github.com/entertainment-venue/sm/pkg/commonutil"
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -86,11 +88,18 @@ func (suite *MapperTestSuite) SetupTest() {
 	trigger, _ := commonutil.NewTrigger(
 		commonutil.WithWorkerSize(triggerWorkerSize),
 	)
+	_ = trigger.Register(expireTrigger, mpr.Expire)
 	mpr.trigger = trigger
+	mpr.delayQueue = commonutil.NewDelayQueue(mpr.expireCallback)
 
 	suite.mpr = &mpr
 }
 
+func (suite *MapperTestSuite) TearDownTest() {
+	suite.mpr.delayQueue.Close()
+	suite.mpr.trigger.Close()
+}
+
 func (suite *MapperTestSuite) createFakeContainer() {
 	// 构造测试数据
 
@@ -250,6 +259,35 @@ func (suite *MapperTestSuite) TestRefresh_containerUpdateTime() {
 	}
 }
 
+func (suite *MapperTestSuite) TestRefresh_shardStateAndLoad() {
+	suite.createFakeContainer()
+
+	var shards []*storage.ShardKeeperDbValue
+	for _, shard := range fakeShards {
+		v := *shard
+		v.State = storage.ShardStateStarting
+		v.Load = &storage.ShardLoad{QPS: 1}
+		shards = append(shards, &v)
+	}
+	hb := apputil.ContainerHeartbeat{
+		Heartbeat: apputil.Heartbeat{Timestamp: time.Now().Unix() + 1},
+		Shards:    shards,
+	}
+	event := clientv3.Event{
+		Kv: &mvccpb.KeyValue{
+			Key:   []byte(""),
+			Value: []byte(hb.String()),
+		},
+	}
+	err := suite.mpr.Refresh(fakeContainerId, &event)
+	assert.Nil(suite.T(), err)
+	assert.NotEmpty(suite.T(), suite.mpr.shardState.alive)
+	for _, sT := range suite.mpr.shardState.alive {
+		assert.Equal(suite.T(), storage.ShardStateStarting, sT.state)
+		assert.Equal(suite.T(), 1.0, sT.load.QPS)
+	}
+}
+
 func (suite *MapperTestSuite) TestRefresh_nilShardsProblem() {
 	suite.createFakeContainer()
 
@@ -295,27 +333,59 @@ func (suite *MapperTestSuite) TestRefresh_reduceShardsProblem() {
 	assert.NotEqual(suite.T(), (*temporary)(nil), suite.mpr.shardState.alive["foo"])
 }
 
-func (suite *MapperTestSuite) TestWait_containerNotFound() {
+func (suite *MapperTestSuite) TestSchedule_containerNotFound() {
 	fakeContainerId := mock.Anything
-	err := suite.mpr.Wait(fakeContainerId)
+	err := suite.mpr.Schedule(fakeContainerId)
 	assert.Equal(suite.T(), err, commonutil.ErrNotExist)
 }
 
-func (suite *MapperTestSuite) TestWait_triggerWait() {
-	// 构造测试数据
-	fakeContainerId := mock.Anything
-	fakeHbA := apputil.ContainerHeartbeat{
-		Heartbeat: apputil.Heartbeat{
-			Timestamp: time.Now().Unix(),
+func (suite *MapperTestSuite) TestSchedule_expire() {
+	suite.createFakeContainer()
+
+	suite.mpr.maxRecoveryTime = 500 * time.Millisecond
+	err := suite.mpr.Schedule(fakeContainerId)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, suite.mpr.delayQueue.Len())
+
+	// 等待过程中不阻塞其他container的事件
+	hb := apputil.ContainerHeartbeat{Heartbeat: apputil.Heartbeat{Timestamp: time.Now().Unix()}}
+	err = suite.mpr.create("bar", []byte(hb.String()))
+	assert.Nil(suite.T(), err)
+
+	time.Sleep(2 * time.Second)
+	suite.mpr.mu.Lock()
+	defer suite.mpr.mu.Unlock()
+	assert.Nil(suite.T(), suite.mpr.containerState.alive[fakeContainerId])
+	assert.NotNil(suite.T(), suite.mpr.containerState.alive["bar"])
+	assert.Empty(suite.T(), suite.mpr.shardState.alive)
+}
+
+func (suite *MapperTestSuite) TestExpire_recovery() {
+	suite.createFakeContainer()
+	suite.mpr.maxRecoveryTime = defaultMaxRecoveryTime
+
+	err := suite.mpr.Schedule(fakeContainerId)
+	assert.Nil(suite.T(), err)
+
+	// 心跳恢复，deleted标记被重置
+	hb := apputil.ContainerHeartbeat{
+		Heartbeat: apputil.Heartbeat{Timestamp: time.Now().Unix()},
+		Shards:    fakeShards,
+	}
+	event := clientv3.Event{
+		Type: clientv3.EventTypePut,
+		Kv: &mvccpb.KeyValue{
+			Key:   []byte(fmt.Sprintf("/sm/app/foo/containerhb/%s/694d7e6d1f5e4fb3", fakeContainerId)),
+			Value: []byte(hb.String()),
 		},
-		Shards: fakeShards,
 	}
-	err := suite.mpr.create(fakeContainerId, []byte(fakeHbA.String()))
+	err = suite.mpr.UpdateState(mock.Anything, &event)
 	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, suite.mpr.delayQueue.Len())
 
-	suite.mpr.maxRecoveryTime = 3 * time.Second
-	err = suite.mpr.Wait(fakeContainerId)
+	err = suite.mpr.Expire(expireTrigger, fakeContainerId)
 	assert.Nil(suite.T(), err)
+	assert.NotNil(suite.T(), suite.mpr.containerState.alive[fakeContainerId])
 }
 
 func (suite *MapperTestSuite) TestUpdateState_createEvent() {
@@ -358,3 +428,199 @@ func (suite *MapperTestSuite) TestUpdateState_deleteEvent() {
 	err := suite.mpr.UpdateState(mock.Anything, &deleteEvent)
 	assert.Nil(suite.T(), err)
 }
+
+func (suite *MapperTestSuite) TestRefresh_standby() {
+	suite.mpr.shard = nil
+
+	hb := apputil.ContainerHeartbeat{
+		Heartbeat: apputil.Heartbeat{Timestamp: time.Now().Unix()},
+		Shards: []*storage.ShardKeeperDbValue{
+			{Spec: &storage.ShardSpec{Id: "foo", Lease: &storage.Lease{ID: 1}}},
+			{Spec: &storage.ShardSpec{Id: "bar", Lease: &storage.Lease{ID: 2}}},
+		},
+	}
+	event := clientv3.Event{
+		Kv: &mvccpb.KeyValue{
+			Key:   []byte(""),
+			Value: []byte(hb.String()),
+		},
+	}
+	err := suite.mpr.Refresh(fakeContainerId, &event)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, len(suite.mpr.shardState.alive))
+	assert.Empty(suite.T(), suite.mpr.AliveShards())
+
+	// attach之后只保留guard lease下的shard
+	suite.mpr.attach(&smShard{guardLeaseID: 1}, suite.mpr.appSpec)
+	actual := suite.mpr.AliveShards()
+	assert.Equal(suite.T(), 1, len(actual))
+	assert.NotNil(suite.T(), actual["foo"])
+	assert.Equal(suite.T(), suite.mpr.containerState.alive[fakeContainerId].lastHeartbeatTime.Unix(), hb.Timestamp)
+
+	suite.mpr.detach()
+	assert.Nil(suite.T(), suite.mpr.shard)
+}
+
+func (suite *MapperTestSuite) TestResync() {
+	suite.createFakeContainer()
+	suite.mpr.maxRecoveryTime = defaultMaxRecoveryTime
+	suite.mpr.container = &smContainer{nodeManager: newNodeManager("foo", etcdutil.NewPathBuilder(""))}
+	_ = suite.mpr.trigger.Register(containerTrigger, suite.mpr.UpdateState)
+	_ = suite.mpr.trigger.Register(resyncDeleteTrigger, suite.mpr.resyncDelete)
+
+	// 快照中只有bar，原有的container进入恢复等待
+	hb := apputil.ContainerHeartbeat{Heartbeat: apputil.Heartbeat{Timestamp: time.Now().Unix()}}
+	resp := clientv3.GetResponse{
+		Kvs: []*mvccpb.KeyValue{
+			{
+				Key:   []byte(suite.mpr.container.nodeManager.ExternalContainerHbDir("test") + "bar/694d7e6d1f5e4fb3"),
+				Value: []byte(hb.String()),
+			},
+		},
+	}
+	err := suite.mpr.resync(context.TODO(), &resp)
+	assert.Nil(suite.T(), err)
+
+	time.Sleep(500 * time.Millisecond)
+	suite.mpr.mu.Lock()
+	defer suite.mpr.mu.Unlock()
+	assert.NotNil(suite.T(), suite.mpr.containerState.alive["bar"])
+	assert.True(suite.T(), suite.mpr.containerState.alive[fakeContainerId].deleted)
+	assert.Equal(suite.T(), 1, suite.mpr.delayQueue.Len())
+}
+
+func (suite *MapperTestSuite) TestRefresh_compactDelta() {
+	snapshot := apputil.ContainerHeartbeat{
+		Heartbeat: apputil.Heartbeat{Timestamp: time.Now().Unix()},
+		CompactShards: []*apputil.CompactShard{
+			{Id: "foo", LeaseID: 1, TaskHash: storage.TaskHash("t1"), State: storage.ShardStateStarting},
+			{Id: "bar", LeaseID: 1},
+		},
+	}
+	value, _ := snapshot.Marshal(apputil.HeartbeatCompactProto)
+	event := clientv3.Event{Kv: &mvccpb.KeyValue{Key: []byte("hb"), Value: value, ModRevision: 10}}
+	assert.Nil(suite.T(), suite.mpr.Refresh(fakeContainerId, &event))
+	assert.Equal(suite.T(), 2, len(suite.mpr.shardState.alive))
+	assert.Equal(suite.T(), storage.TaskHash("t1"), suite.mpr.shardState.alive["foo"].taskHash)
+
+	// 增量心跳基于缓存的全量心跳展开
+	delta := apputil.ContainerHeartbeat{
+		Heartbeat:     apputil.Heartbeat{Timestamp: time.Now().Unix() + 1},
+		BaseRevision:  10,
+		CompactShards: []*apputil.CompactShard{{Id: "foo", LeaseID: 1, State: storage.ShardStateServing}},
+		Removed:       []string{"bar"},
+	}
+	event = clientv3.Event{Kv: &mvccpb.KeyValue{Key: []byte("hb"), Value: []byte(delta.String()), ModRevision: 12}}
+	assert.Nil(suite.T(), suite.mpr.Refresh(fakeContainerId, &event))
+	assert.Equal(suite.T(), 1, len(suite.mpr.shardState.alive))
+	assert.Equal(suite.T(), storage.ShardStateServing, suite.mpr.shardState.alive["foo"].state)
+}
+
+func (suite *MapperTestSuite) TestRefresh_deltaWithoutSnapshot() {
+	snapshot := apputil.ContainerHeartbeat{
+		Heartbeat:     apputil.Heartbeat{Timestamp: time.Now().Unix()},
+		CompactShards: []*apputil.CompactShard{{Id: "foo", LeaseID: 1}},
+	}
+	// 访问etcd带有超时，并且不持有mu
+	hasDeadline := mock.MatchedBy(func(ctx context.Context) bool {
+		_, ok := ctx.Deadline()
+		return ok
+	})
+	mockedEtcdWrapper := new(etcdutil.MockedEtcdWrapper)
+	mockedEtcdWrapper.On("Get", hasDeadline, "hb", mock.Anything).Return(
+		&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Key: []byte("hb"), Value: []byte(snapshot.String()), ModRevision: 10}}},
+		nil,
+	).Run(func(args mock.Arguments) {
+		donec := make(chan struct{})
+		go func() {
+			suite.mpr.AliveContainers()
+			close(donec)
+		}()
+		select {
+		case <-donec:
+		case <-time.After(time.Second):
+			suite.T().Error("mu held while getting heartbeat snapshot")
+		}
+	}).Once()
+	mockedEtcdWrapper.On("Get", mock.Anything, "hb", mock.Anything).Return(&clientv3.GetResponse{}, nil)
+	suite.mpr.container = &smContainer{Client: mockedEtcdWrapper}
+
+	// 全量心跳的事件被合并，从etcd的历史版本获取
+	delta := apputil.ContainerHeartbeat{
+		Heartbeat:     apputil.Heartbeat{Timestamp: time.Now().Unix()},
+		BaseRevision:  10,
+		CompactShards: []*apputil.CompactShard{{Id: "bar", LeaseID: 1}},
+	}
+	event := clientv3.Event{Kv: &mvccpb.KeyValue{Key: []byte("hb"), Value: []byte(delta.String()), ModRevision: 11}}
+	assert.Nil(suite.T(), suite.mpr.Refresh(fakeContainerId, &event))
+	assert.Equal(suite.T(), 2, len(suite.mpr.shardState.alive))
+
+	// 历史版本已经被compact，container保持存活，shard不变
+	delta.BaseRevision = 20
+	delta.Timestamp++
+	event = clientv3.Event{Kv: &mvccpb.KeyValue{Key: []byte("hb"), Value: []byte(delta.String()), ModRevision: 21}}
+	assert.NotNil(suite.T(), suite.mpr.Refresh(fakeContainerId, &event))
+	assert.Equal(suite.T(), 2, len(suite.mpr.shardState.alive))
+	assert.Equal(suite.T(), delta.Timestamp, suite.mpr.containerState.alive[fakeContainerId].lastHeartbeatTime.Unix())
+
+	// 无法展开的增量心跳也要保留优雅退出的标记
+	delta.Leaving = true
+	delta.Timestamp++
+	event = clientv3.Event{Kv: &mvccpb.KeyValue{Key: []byte("hb"), Value: []byte(delta.String()), ModRevision: 22}}
+	assert.NotNil(suite.T(), suite.mpr.Refresh(fakeContainerId, &event))
+	assert.Contains(suite.T(), suite.mpr.LeavingContainers(), fakeContainerId)
+}
+
+func (suite *MapperTestSuite) TestUpdateState_leaving() {
+	suite.createFakeContainer()
+
+	hb := apputil.ContainerHeartbeat{
+		Heartbeat: apputil.Heartbeat{Timestamp: time.Now().Unix() + 1},
+		Shards:    fakeShards,
+		Leaving:   true,
+	}
+	event := clientv3.Event{Kv: &mvccpb.KeyValue{Key: []byte(""), Value: []byte(hb.String())}}
+	assert.Nil(suite.T(), suite.mpr.Refresh(fakeContainerId, &event))
+	assert.Contains(suite.T(), suite.mpr.LeavingContainers(), fakeContainerId)
+	assert.Equal(suite.T(), 2, len(suite.mpr.shardState.alive))
+
+	// 优雅退出的container删除心跳节点后立即删除，不等待恢复
+	deleteEvent := clientv3.Event{
+		Type: clientv3.EventTypeDelete,
+		Kv: &mvccpb.KeyValue{
+			Key: []byte(fmt.Sprintf("/sm/app/foo/containerhb/%s/694d7e6d1f5e4fb3", fakeContainerId)),
+		},
+	}
+	assert.Nil(suite.T(), suite.mpr.UpdateState(mock.Anything, &deleteEvent))
+	assert.Empty(suite.T(), suite.mpr.containerState.alive)
+	assert.Empty(suite.T(), suite.mpr.shardState.alive)
+	assert.Empty(suite.T(), suite.mpr.LeavingContainers())
+}
+
+func (suite *MapperTestSuite) TestRefresh_staleShard() {
+	hb := apputil.ContainerHeartbeat{
+		Heartbeat: apputil.Heartbeat{Timestamp: time.Now().Unix()},
+		Shards:    []*storage.ShardKeeperDbValue{{Spec: &storage.ShardSpec{Id: "foo", Lease: &storage.Lease{ID: 1}}, Disp: true}},
+	}
+	refresh := func(containerId string, revision int64) {
+		event := clientv3.Event{Kv: &mvccpb.KeyValue{Key: []byte(containerId), Value: []byte(hb.String()), ModRevision: revision}}
+		assert.Nil(suite.T(), suite.mpr.Refresh(containerId, &event))
+	}
+
+	refresh("b", 12)
+	// 其他container的心跳已经处理过，过时的心跳不能抢回shard
+	refresh("a", 11)
+	assert.Equal(suite.T(), "b", suite.mpr.shardState.alive["foo"].curContainerId)
+
+	refresh("a", 13)
+	assert.Equal(suite.T(), "a", suite.mpr.shardState.alive["foo"].curContainerId)
+}
+
+func (suite *MapperTestSuite) TestUpdateState_lateDeleteEvent() {
+	// container已经被删除，迟到的删除事件不算错误
+	deleteEvent := clientv3.Event{
+		Type: clientv3.EventTypeDelete,
+		Kv:   &mvccpb.KeyValue{Key: []byte("/sm/app/foo/containerhb/bar/694d7e6d1f5e4fb3")},
+	}
+	assert.Nil(suite.T(), suite.mpr.UpdateState(mock.Anything, &deleteEvent))
+}
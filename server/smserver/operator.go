@@ -16,18 +16,31 @@ package smserver
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/entertainment-venue/sm/pkg/apputil/receiver"
+	"github.com/entertainment-venue/sm/pkg/apputil/receiver/receiverpb"
 	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/entertainment-venue/sm/pkg/commonutil"
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
 	"github.com/entertainment-venue/sm/pkg/logutil"
 	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 type moveAction struct {
@@ -36,6 +49,9 @@ type moveAction struct {
 	DropEndpoint string `json:"dropEndpoint"`
 	AddEndpoint  string `json:"addEndpoint"`
 
+	// UpdateEndpoint shard只有Task变化，由当前所在container原地更新
+	UpdateEndpoint string `json:"updateEndpoint"`
+
 	// Spec 存储分片具体信息
 	Spec *storage.ShardSpec `json:"spec"`
 }
@@ -67,12 +83,172 @@ type operator struct {
 	service string
 
 	httpClient *http.Client
+
+	// https 目标container的receiver开启了https，grpc receiver同样使用tls
+	https     bool
+	tlsConfig *tls.Config
+
+	// client 不为空时，拉模式的container通过etcd中的指令队列下发
+	client etcdutil.EtcdWrapper
+	paths  *etcdutil.PathBuilder
+
+	mu sync.Mutex
+	// receiverType 对应 smAppSpec.Receiver ，spec变化时更新
+	receiverType string
+	// conns grpc receiver的连接，按照container复用
+	conns map[string]*grpc.ClientConn
+	// pulls 声明拉模式的container，secretValue 签名密钥，都由 start 启动的watch维护，下发指令时不再读取etcd
+	pulls       map[string]struct{}
+	secretValue []byte
+
+	stopper commonutil.GoroutineStopper
 }
 
-func newOperator(service string) *operator {
-	return &operator{
+// newOperator tlsConfig不为空时使用https，客户端证书用于对方的mTLS校验
+func newOperator(service string, tlsConfig *tls.Config, client etcdutil.EtcdWrapper, paths *etcdutil.PathBuilder) *operator {
+	o := operator{
 		service:    service,
 		httpClient: newHttpClient(),
+		client:     client,
+		paths:      paths,
+	}
+	if tlsConfig != nil {
+		o.https = true
+		o.tlsConfig = &tls.Config{
+			Certificates: tlsConfig.Certificates,
+			RootCAs:      tlsConfig.ClientCAs,
+			MinVersion:   tlsConfig.MinVersion,
+		}
+		o.httpClient.Transport.(*http.Transport).TLSClientConfig = o.tlsConfig
+	}
+	return &o
+}
+
+func (o *operator) setReceiverType(v string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.receiverType = v
+}
+
+func (o *operator) getReceiverType() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.receiverType
+}
+
+// start 加载service的拉模式container和签名密钥（不存在时创建），并watch后续的变化
+func (o *operator) start() error {
+	dir := o.paths.ReceiverDir(o.service)
+	resp, err := o.client.Get(context.TODO(), dir, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	o.resetPulls(resp)
+
+	// 密钥在Get之后的变化会通过watch补上
+	secret, err := o.secret(o.service)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	o.setSecret(secret)
+
+	rev := resp.Header.Revision + 1
+	o.stopper.Wrap(
+		func(ctx context.Context) {
+			etcdutil.WatchLoopWithResync(
+				ctx,
+				o.client,
+				dir,
+				rev,
+				func(ctx context.Context, ev *clientv3.Event) error {
+					containerId := strings.TrimPrefix(string(ev.Kv.Key), dir)
+					o.mu.Lock()
+					defer o.mu.Unlock()
+					if ev.Type == clientv3.EventTypeDelete {
+						delete(o.pulls, containerId)
+					} else {
+						o.pulls[containerId] = struct{}{}
+					}
+					return nil
+				},
+				func(ctx context.Context, resp *clientv3.GetResponse) error {
+					o.resetPulls(resp)
+					return nil
+				},
+			)
+		},
+	)
+
+	key := o.paths.SecretPath(o.service)
+	o.stopper.Wrap(
+		func(ctx context.Context) {
+			etcdutil.WatchLoopWithResync(
+				ctx,
+				o.client,
+				key,
+				rev,
+				func(ctx context.Context, ev *clientv3.Event) error {
+					// 前缀watch，忽略其他key
+					if string(ev.Kv.Key) != key || ev.Type == clientv3.EventTypeDelete {
+						return nil
+					}
+					o.setSecret(ev.Kv.Value)
+					return nil
+				},
+				func(ctx context.Context, resp *clientv3.GetResponse) error {
+					for _, kv := range resp.Kvs {
+						if string(kv.Key) == key {
+							o.setSecret(kv.Value)
+						}
+					}
+					return nil
+				},
+			)
+		},
+	)
+	return nil
+}
+
+func (o *operator) resetPulls(resp *clientv3.GetResponse) {
+	pulls := make(map[string]struct{})
+	dir := o.paths.ReceiverDir(o.service)
+	for _, kv := range resp.Kvs {
+		pulls[strings.TrimPrefix(string(kv.Key), dir)] = struct{}{}
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pulls = pulls
+}
+
+func (o *operator) isPull(containerId string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, ok := o.pulls[containerId]
+	return ok
+}
+
+func (o *operator) setSecret(v []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.secretValue = v
+}
+
+func (o *operator) getSecret() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.secretValue
+}
+
+// close 停止watch并关闭grpc连接，smShard关闭时调用
+func (o *operator) close() {
+	// watch的回调需要获取mu，先停止watch
+	o.stopper.Close()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for endpoint, conn := range o.conns {
+		conn.Close()
+		delete(o.conns, endpoint)
 	}
 }
 
@@ -123,13 +299,19 @@ func (o *operator) move(mal moveActionList) error {
 
 func (o *operator) dropOrAdd(ma *moveAction) error {
 	if ma.DropEndpoint != "" {
-		if err := o.send(ma.ShardId, ma.Spec, ma.DropEndpoint, "drop"); err != nil {
+		if err := o.dispatch(ma, ma.DropEndpoint, receiver.ActionDrop); err != nil {
 			return errors.Wrap(err, "")
 		}
 	}
 
 	if ma.AddEndpoint != "" {
-		if err := o.send(ma.ShardId, ma.Spec, ma.AddEndpoint, "add"); err != nil {
+		if err := o.dispatch(ma, ma.AddEndpoint, receiver.ActionAdd); err != nil {
+			return errors.Wrap(err, "")
+		}
+	}
+
+	if ma.UpdateEndpoint != "" {
+		if err := o.dispatch(ma, ma.UpdateEndpoint, receiver.ActionUpdate); err != nil {
 			return errors.Wrap(err, "")
 		}
 	}
@@ -141,14 +323,202 @@ func (o *operator) dropOrAdd(ma *moveAction) error {
 	return nil
 }
 
-func (o *operator) send(id string, spec *storage.ShardSpec, endpoint string, action string) error {
+// dispatch container在etcd中声明了拉模式时写入指令队列，否则发送签名的http或grpc请求，
+// 拉模式和密钥使用 start 维护的缓存
+func (o *operator) dispatch(ma *moveAction, endpoint string, action string) error {
+	var secret []byte
+	if o.client != nil {
+		if o.isPull(endpoint) {
+			return o.command(ma.Service, ma.ShardId, ma.Spec, endpoint, action)
+		}
+		secret = o.getSecret()
+	}
+	if o.getReceiverType() == receiverGRPC {
+		return o.sendGRPC(ma.ShardId, ma.Spec, endpoint, action, secret)
+	}
+	return o.send(ma.ShardId, ma.Spec, endpoint, action, secret)
+}
+
+// secret 读取service的签名密钥，不存在时创建，多个leader并发创建时以先写入的为准
+func (o *operator) secret(service string) ([]byte, error) {
+	key := o.paths.SecretPath(service)
+	resp, err := o.client.Get(context.TODO(), key)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	if len(resp.Kvs) > 0 {
+		return resp.Kvs[0].Value, nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	tresp, err := o.client.CommitTxn(
+		context.TODO(),
+		[]clientv3.Cmp{clientv3.Compare(clientv3.CreateRevision(key), "=", 0)},
+		[]clientv3.Op{clientv3.OpPut(key, hex.EncodeToString(b))},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	if !tresp.Succeeded {
+		return o.secret(service)
+	}
+	logutil.Info(
+		"secret created",
+		zap.String("service", service),
+	)
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+// sendGRPC container使用grpc receiver，请求带有 defaultGRPCTimeout 的deadline，secret不为空时签名
+func (o *operator) sendGRPC(id string, spec *storage.ShardSpec, endpoint string, action string, secret []byte) error {
+	conn, err := o.conn(endpoint)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	client := receiverpb.NewReceiverClient(conn)
+	req := receiver.NewShardRequest(id, spec)
+
+	var (
+		method string
+		call   func(ctx context.Context, in *receiverpb.ShardRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	)
+	switch action {
+	case receiver.ActionAdd:
+		method, call = "AddShard", client.AddShard
+	case receiver.ActionDrop:
+		method, call = "DropShard", client.DropShard
+	case receiver.ActionUpdate:
+		method, call = "UpdateShard", client.UpdateShard
+	default:
+		return errors.Errorf("unknown action %s", action)
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), defaultGRPCTimeout)
+	defer cancel()
+	if secret != nil {
+		body, err := receiver.MarshalShardRequest(req)
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		fullMethod := fmt.Sprintf("/%s/%s", receiverpb.Receiver_ServiceDesc.ServiceName, method)
+		ctx = receiver.Sign(secret, fullMethod, body).AppendToOutgoingContext(ctx)
+	}
+	if _, err := call(ctx, req); err != nil {
+		logutil.Error(
+			"grpc send error",
+			zap.String("endpoint", endpoint),
+			zap.String("action", action),
+			zap.String("id", id),
+			zap.Error(err),
+		)
+		return errors.Wrap(err, "")
+	}
+
+	logutil.Info(
+		"grpc send success",
+		zap.String("endpoint", endpoint),
+		zap.String("action", action),
+		zap.Reflect("req", req),
+	)
+	return nil
+}
+
+func (o *operator) conn(endpoint string) (*grpc.ClientConn, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if conn, ok := o.conns[endpoint]; ok {
+		return conn, nil
+	}
+
+	creds := grpc.WithInsecure()
+	if o.tlsConfig != nil {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(o.tlsConfig))
+	}
+	conn, err := grpc.Dial(endpoint, creds)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	if o.conns == nil {
+		o.conns = make(map[string]*grpc.ClientConn)
+	}
+	o.conns[endpoint] = conn
+	return conn, nil
+}
+
+// command 写入container的指令队列，等待container确认，
+// container删除指令表示成功，回写Error表示失败，超时后撤回指令，由move重试
+func (o *operator) command(service string, id string, spec *storage.ShardSpec, endpoint string, action string) error {
+	cmd := receiver.EtcdReceiverCommand{
+		HttpReceiverRequest: receiver.HttpReceiverRequest{Id: id, Spec: spec},
+		Action:              action,
+	}
+	key := o.paths.CommandPath(service, endpoint, fmt.Sprintf("%d-%s-%s", time.Now().UnixNano(), action, id))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), defaultCommandTimeout)
+	defer cancel()
+	presp, err := o.client.Put(ctx, key, cmd.String())
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	// 不论结果，指令都不再保留
+	defer o.client.Delete(context.TODO(), key)
+
+	wch := o.client.Watch(ctx, key, clientv3.WithRev(presp.Header.Revision+1))
+	for wr := range wch {
+		if err := wr.Err(); err != nil {
+			return errors.Wrap(err, "")
+		}
+		for _, ev := range wr.Events {
+			if ev.Type == clientv3.EventTypeDelete {
+				logutil.Info(
+					"command success",
+					zap.String("key", key),
+					zap.Reflect("cmd", cmd),
+				)
+				return nil
+			}
+
+			var ack receiver.EtcdReceiverCommand
+			if err := json.Unmarshal(ev.Kv.Value, &ack); err != nil {
+				return errors.Wrap(err, "")
+			}
+			if ack.Error != "" {
+				logutil.Error(
+					"command failed",
+					zap.String("key", key),
+					zap.Reflect("cmd", cmd),
+					zap.String("error", ack.Error),
+				)
+				return errors.New(ack.Error)
+			}
+		}
+	}
+	err = errors.Errorf("command timeout %s", key)
+	logutil.Error(
+		"command not ack",
+		zap.String("key", key),
+		zap.Reflect("cmd", cmd),
+		zap.Error(err),
+	)
+	return err
+}
+
+// send secret不为空时签名
+func (o *operator) send(id string, spec *storage.ShardSpec, endpoint string, action string, secret []byte) error {
 	msg := receiver.HttpReceiverRequest{Id: id, Spec: spec}
 	b, err := json.Marshal(msg)
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
 
-	urlStr := fmt.Sprintf("http://%s/sm/admin/%s-shard", endpoint, action)
+	scheme := "http"
+	if o.https {
+		scheme = "https"
+	}
+	urlStr := fmt.Sprintf("%s://%s/sm/admin/%s-shard", scheme, endpoint, action)
 	req, err := http.NewRequest(http.MethodPost, urlStr, bytes.NewBuffer(b))
 	if err != nil {
 		logutil.Error(
@@ -159,6 +529,9 @@ func (o *operator) send(id string, spec *storage.ShardSpec, endpoint string, act
 		return errors.Wrap(err, "")
 	}
 	req.Header.Add("Content-Type", "application/json")
+	if secret != nil {
+		receiver.Sign(secret, req.URL.Path, b).SetHeader(req.Header)
+	}
 
 	resp, err := o.httpClient.Do(req)
 	if err != nil {
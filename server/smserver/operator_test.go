@@ -15,6 +15,7 @@
 package smserver
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -22,7 +23,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/entertainment-venue/sm/pkg/apputil/core"
+	"github.com/entertainment-venue/sm/pkg/apputil/receiver"
 	"github.com/entertainment-venue/sm/pkg/apputil/storage"
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
 )
 
@@ -101,7 +109,7 @@ func Test_operator_send(t *testing.T) {
 	o := operator{}
 	o.httpClient = newHttpClient()
 
-	if err := o.send("1", &storage.ShardSpec{}, "127.0.0.1:8889", "add"); err != nil {
+	if err := o.send("1", &storage.ShardSpec{}, "127.0.0.1:8889", "add", nil); err != nil {
 		t.Errorf("err: %+v", err)
 		t.SkipNow()
 	}
@@ -109,3 +117,153 @@ func Test_operator_send(t *testing.T) {
 	stopch := make(chan struct{})
 	<-stopch
 }
+
+// Test_operator_dispatch_pull 依赖本地127.0.0.1:2379的etcd，container使用拉模式
+func Test_operator_dispatch_pull(t *testing.T) {
+	client, err := etcdutil.NewEtcdClient([]string{"127.0.0.1:2379"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	paths := etcdutil.NewPathBuilder(fmt.Sprintf("/operator-test-%d", time.Now().UnixNano()))
+	defer client.Delete(context.TODO(), paths.Pfx(), clientv3.WithPrefix())
+
+	lresp, err := client.Grant(context.TODO(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := new(core.MockedShardPrimitives)
+	sp.On("Add", "s1", mock.Anything).Return(nil)
+	sp.On("Drop", "s2").Return(errors.New("drop failed"))
+	r := receiver.NewEtcdReceiver(client, paths, "foo.bar", "c1", lresp.ID)
+	r.SetShardPrimitives(sp)
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Shutdown()
+
+	o := newOperator("foo.bar", nil, client, paths)
+	if err := o.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.close()
+	spec := &storage.ShardSpec{Service: "foo.bar", UpdateTime: time.Now().Unix()}
+	assert.NoError(t, o.dispatch(&moveAction{Service: "foo.bar", ShardId: "s1", Spec: spec}, "c1", receiver.ActionAdd))
+	assert.EqualError(t, o.dispatch(&moveAction{Service: "foo.bar", ShardId: "s2"}, "c1", receiver.ActionDrop), "drop failed")
+	sp.AssertExpectations(t)
+
+	// 指令处理完成后不保留
+	resp, err := client.Get(context.TODO(), paths.CommandDir("foo.bar", "c1"), clientv3.WithPrefix())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), resp.Count)
+}
+
+func Test_operator_dispatch_grpc(t *testing.T) {
+	addr := freeAddr(t)
+	sp := new(core.MockedShardPrimitives)
+	sp.On("Add", "s1", mock.Anything).Return(nil)
+	r := receiver.NewGRPCReceiver(addr, addr)
+	r.SetShardPrimitives(sp)
+	// operator没有etcd，不签名
+	verifier := receiver.NewVerifier()
+	verifier.SetAllowUnsigned(true)
+	r.SetVerifier(verifier)
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Shutdown()
+
+	o := newOperator("sm", nil, nil, nil)
+	o.setReceiverType(receiverGRPC)
+	defer o.close()
+
+	spec := &storage.ShardSpec{Service: "foo.bar", UpdateTime: time.Now().Unix()}
+	assert.NoError(t, o.dispatch(&moveAction{Service: "foo.bar", ShardId: "s1", Spec: spec}, addr, receiver.ActionAdd))
+	sp.AssertExpectations(t)
+
+	// container没有实现update
+	assert.Error(t, o.dispatch(&moveAction{Service: "foo.bar", ShardId: "s1", Spec: spec}, addr, receiver.ActionUpdate))
+}
+
+// Test_operator_dispatch_signed 依赖本地127.0.0.1:2379的etcd，receiver只接受签名的指令
+func Test_operator_dispatch_signed(t *testing.T) {
+	client, err := etcdutil.NewEtcdClient([]string{"127.0.0.1:2379"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	paths := etcdutil.NewPathBuilder(fmt.Sprintf("/operator-test-%d", time.Now().UnixNano()))
+	defer client.Delete(context.TODO(), paths.Pfx(), clientv3.WithPrefix())
+
+	o := newOperator("foo.bar", nil, client, paths)
+	if err := o.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.close()
+	secret, err := o.secret("foo.bar")
+	assert.NoError(t, err)
+	// 已经存在时不重新生成
+	again, err := o.secret("foo.bar")
+	assert.NoError(t, err)
+	assert.Equal(t, secret, again)
+
+	verifier := receiver.NewVerifier()
+	verifier.SetSecret(secret)
+	sp := new(core.MockedShardPrimitives)
+	sp.On("Drop", "s1").Return(nil)
+
+	addr := freeAddr(t)
+	r := receiver.NewGRPCReceiver(addr, addr)
+	r.SetShardPrimitives(sp)
+	r.SetVerifier(verifier)
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Shutdown()
+
+	o.setReceiverType(receiverGRPC)
+	assert.NoError(t, o.dispatch(&moveAction{Service: "foo.bar", ShardId: "s1"}, addr, receiver.ActionDrop))
+	sp.AssertExpectations(t)
+
+	// 密钥不一致
+	verifier.SetSecret([]byte("foo"))
+	assert.Error(t, o.dispatch(&moveAction{Service: "foo.bar", ShardId: "s1"}, addr, receiver.ActionDrop))
+}
+
+// Test_operator_start 依赖本地127.0.0.1:2379的etcd，拉模式和密钥的变化通过watch同步到缓存
+func Test_operator_start(t *testing.T) {
+	client, err := etcdutil.NewEtcdClient([]string{"127.0.0.1:2379"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	paths := etcdutil.NewPathBuilder(fmt.Sprintf("/operator-test-%d", time.Now().UnixNano()))
+	defer client.Delete(context.TODO(), paths.Pfx(), clientv3.WithPrefix())
+
+	_, err = client.Put(context.TODO(), paths.ReceiverPath("foo.bar", "c1"), "")
+	assert.NoError(t, err)
+
+	o := newOperator("foo.bar", nil, client, paths)
+	if err := o.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.close()
+	assert.True(t, o.isPull("c1"))
+	assert.False(t, o.isPull("c2"))
+	assert.NotEmpty(t, o.getSecret())
+
+	_, err = client.Put(context.TODO(), paths.ReceiverPath("foo.bar", "c2"), "")
+	assert.NoError(t, err)
+	_, err = client.Delete(context.TODO(), paths.ReceiverPath("foo.bar", "c1"))
+	assert.NoError(t, err)
+	_, err = client.Put(context.TODO(), paths.SecretPath("foo.bar"), "foo")
+	assert.NoError(t, err)
+	assert.Eventually(
+		t,
+		func() bool {
+			return !o.isPull("c1") && o.isPull("c2") && string(o.getSecret()) == "foo"
+		},
+		3*time.Second,
+		100*time.Millisecond,
+	)
+}
@@ -15,9 +15,10 @@
 package smserver
 
 import (
+	"context"
+	"crypto/tls"
 	"time"
 
-	"github.com/entertainment-venue/sm/pkg/etcdutil"
 	"github.com/entertainment-venue/sm/pkg/logutil"
 	_ "github.com/entertainment-venue/sm/server/docs"
 	"github.com/pkg/errors"
@@ -45,8 +46,26 @@ type serverOptions struct {
 	addr string
 
 	// etcdPrefix 这个路径是etcd中开辟出来给sm使用的，etcd可能是多个组件公用
-	// TODO 要有用户名和密码限制
 	etcdPrefix string
+
+	// etcdUsername和etcdPassword etcd开启RBAC时使用
+	etcdUsername string
+	etcdPassword string
+
+	// etcdTLSConfig 访问etcd使用的tls配置
+	etcdTLSConfig *tls.Config
+
+	// tlsConfig 不为空时管理端口使用https，设置ClientCAs和ClientAuth后支持mTLS认证
+	tlsConfig *tls.Config
+
+	// aclEnabled 开启后 /sm/server/* 需要认证和按service鉴权，默认关闭
+	aclEnabled bool
+
+	// aclAdminToken 拥有所有service admin权限的token，用于初始化etcd中的acl
+	aclAdminToken string
+
+	// grpcAddr 不为空时在该地址提供gRPC接口，和管理端口使用相同的tls配置
+	grpcAddr string
 }
 
 type ServerOption func(options *serverOptions)
@@ -81,6 +100,48 @@ func WithEtcdPrefix(v string) ServerOption {
 	}
 }
 
+func WithEtcdUsername(v string) ServerOption {
+	return func(options *serverOptions) {
+		options.etcdUsername = v
+	}
+}
+
+func WithEtcdPassword(v string) ServerOption {
+	return func(options *serverOptions) {
+		options.etcdPassword = v
+	}
+}
+
+func WithEtcdTLSConfig(v *tls.Config) ServerOption {
+	return func(options *serverOptions) {
+		options.etcdTLSConfig = v
+	}
+}
+
+func WithTLSConfig(v *tls.Config) ServerOption {
+	return func(options *serverOptions) {
+		options.tlsConfig = v
+	}
+}
+
+func WithACL(v bool) ServerOption {
+	return func(options *serverOptions) {
+		options.aclEnabled = v
+	}
+}
+
+func WithACLAdminToken(v string) ServerOption {
+	return func(options *serverOptions) {
+		options.aclAdminToken = v
+	}
+}
+
+func WithGRPCAddr(v string) ServerOption {
+	return func(options *serverOptions) {
+		options.grpcAddr = v
+	}
+}
+
 func NewServer(fn ...ServerOption) (*Server, error) {
 	ops := serverOptions{}
 	for _, f := range fn {
@@ -99,7 +160,6 @@ func NewServer(fn ...ServerOption) (*Server, error) {
 	if len(ops.endpoints) == 0 {
 		return nil, errors.New("endpoints err")
 	}
-	etcdutil.SetPfx(ops.etcdPrefix)
 
 	srv := Server{opts: &ops, donec: make(chan struct{})}
 	if err := srv.run(); err != nil {
@@ -177,6 +237,17 @@ func (s *Server) Close() {
 	// 关闭后，进程退出，至于smContainer的关闭依赖shardServer即可
 }
 
+// Resign 当前sm节点是leader时主动放弃，用于sm集群的滚动发布，successor为空时不提名继任者，
+// ctx没有设置deadline时，最长等待 defaultResignTimeout
+func (s *Server) Resign(ctx context.Context, successor string) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultResignTimeout)
+		defer cancel()
+	}
+	return s.smContainer.Resign(ctx, successor)
+}
+
 func (s *Server) close() {
 	defer logutil.Sync()
 	s.smContainer.Close()
@@ -16,6 +16,7 @@ package smserver
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -75,8 +76,9 @@ type smShard struct {
 
 	// service 从属于leader或者sm smShard，service和container不一定一样
 	service string
-	// appSpec 需要通过配置影响balance算法
+	// appSpec 需要通过配置影响balance算法，spec节点变化时更新，通过specMu保护
 	appSpec *smAppSpec
+	specMu  sync.Mutex
 	// shardSpec 分片的配置信息
 	shardSpec *storage.ShardSpec
 
@@ -86,9 +88,14 @@ type smShard struct {
 	// operator 对接接入方，通过http请求下发shard move指令
 	operator *operator
 
+	// mu 只保护rb的状态，rb过程中不持有
 	mu sync.Mutex
 	// balancing 正在rb的情况下，不能开启下一次rb
 	balancing bool
+	// balanceDone rb结束时关闭，Drain 通过它等待进行中的rb
+	balanceDone chan struct{}
+	// draining leader放弃过程中，不再开启新的rb
+	draining bool
 
 	bridgeLeaseID clientv3.LeaseID
 	guardLeaseID  clientv3.LeaseID
@@ -133,15 +140,9 @@ func newSMShard(container *smContainer, shardSpec *storage.ShardSpec) (*smShard,
 		appSpec.MaxShardCount = defaultMaxShardCount
 	}
 	ss.appSpec = &appSpec
+	specRev := resp.Header.Revision + 1
 
-	ss.operator = newOperator(shardSpec.Service)
-	// TODO 参数传递的有些冗余，需要重新梳理
-	ss.mpr, err = newMapper(container, &appSpec, ss)
-	if err != nil {
-		return nil, errors.Wrap(err, "")
-	}
-
-	// 提供当前的guard lease
+	// 提供当前的guard lease，mapper加载心跳时需要校验shard的lease
 	leasePfx := ss.container.nodeManager.ExternalLeaseGuardPath(ss.service)
 	gresp, err := ss.container.Client.Get(context.TODO(), leasePfx, clientv3.WithPrefix())
 	if err != nil {
@@ -160,8 +161,55 @@ func newSMShard(container *smContainer, shardSpec *storage.ShardSpec) (*smShard,
 	var dv storage.Lease
 	json.Unmarshal(gresp.Kvs[0].Value, &dv)
 	ss.guardLeaseID = dv.ID
+
+	// 只有sm自身的container和当前进程共用tls配置，接入的业务app仍然使用http
+	var tlsConfig *tls.Config
+	if shardSpec.Service == container.Service() {
+		tlsConfig = container.tlsConfig
+	}
+	ss.operator = newOperator(shardSpec.Service, tlsConfig, container.Client, container.nodeManager.paths)
+	ss.operator.setReceiverType(appSpec.Receiver)
+	// 接管service时准备好签名密钥，container尽早watch到，不需要等第一次下发指令
+	if err := ss.operator.start(); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	// 优先使用standby mapper，保留之前的心跳状态
+	ss.mpr = container.standby.acquire(ss, &appSpec)
+	if ss.mpr == nil {
+		// TODO 参数传递的有些冗余，需要重新梳理
+		ss.mpr, err = newMapper(container, &appSpec, ss)
+		if err != nil {
+			ss.operator.close()
+			return nil, errors.Wrap(err, "")
+		}
+	}
+
 	ss.leaseKeepAlive(ss.guardLeaseID, defaultGuardLeaseTimeout*time.Second)
 
+	// spec的变化直接应用到运行中的smShard和mapper，不需要leader重启
+	ss.stopper.Wrap(
+		func(ctx context.Context) {
+			etcdutil.WatchLoopWithResync(
+				ctx,
+				ss.container.Client,
+				serviceSpec,
+				specRev,
+				func(ctx context.Context, ev *clientv3.Event) error {
+					if ev.Type == clientv3.EventTypeDelete {
+						return nil
+					}
+					return ss.updateAppSpec(ev.Kv.Value)
+				},
+				func(ctx context.Context, resp *clientv3.GetResponse) error {
+					if len(resp.Kvs) == 0 {
+						return nil
+					}
+					return ss.updateAppSpec(resp.Kvs[0].Value)
+				},
+			)
+		},
+	)
+
 	ss.stopper.Wrap(
 		func(ctx context.Context) {
 			commonutil.SequenceTickerLoop(
@@ -182,26 +230,64 @@ func newSMShard(container *smContainer, shardSpec *storage.ShardSpec) (*smShard,
 
 func (ss *smShard) SetMaxShardCount(maxShardCount int) {
 	if maxShardCount > 0 {
+		ss.specMu.Lock()
 		ss.appSpec.MaxShardCount = maxShardCount
+		ss.specMu.Unlock()
 	}
 }
 
 func (ss *smShard) SetMaxRecoveryTime(maxRecoveryTime int) {
 	if maxRecoveryTime > 0 && time.Duration(maxRecoveryTime)*time.Second <= maxRecoveryWaitTime {
+		ss.specMu.Lock()
+		ss.appSpec.MaxRecoveryTime = maxRecoveryTime
+		ss.specMu.Unlock()
+
+		ss.mpr.mu.Lock()
 		ss.mpr.maxRecoveryTime = time.Duration(maxRecoveryTime) * time.Second
+		ss.mpr.mu.Unlock()
 	}
 }
 
+// updateAppSpec spec节点变化，字段没有配置时恢复默认值
+func (ss *smShard) updateAppSpec(value []byte) error {
+	var appSpec smAppSpec
+	if err := json.Unmarshal(value, &appSpec); err != nil {
+		return errors.Wrap(err, "")
+	}
+	if appSpec.MaxShardCount <= 0 {
+		appSpec.MaxShardCount = defaultMaxShardCount
+	}
+	ss.SetMaxShardCount(appSpec.MaxShardCount)
+	ss.SetMaxRecoveryTime(int(recoveryTime(&appSpec) / time.Second))
+	ss.operator.setReceiverType(appSpec.Receiver)
+
+	ss.specMu.Lock()
+	ss.appSpec.CreateTime = appSpec.CreateTime
+	ss.specMu.Unlock()
+
+	logutil.Info(
+		"app spec updated",
+		zap.String("service", ss.service),
+		zap.Int("maxShardCount", appSpec.MaxShardCount),
+		zap.Int("maxRecoveryTime", appSpec.MaxRecoveryTime),
+		zap.String("receiver", appSpec.Receiver),
+	)
+	return nil
+}
+
 func (ss *smShard) Spec() *storage.ShardSpec {
 	return ss.shardSpec
 }
 
 func (ss *smShard) Close() error {
 	close(ss.closeCh)
-	ss.mpr.Close()
+	if !ss.container.standby.release(ss.service, ss.mpr) {
+		ss.mpr.Close()
+	}
 
 	ss.stopper.Close()
 	ss.leaseStopper.Close()
+	ss.operator.close()
 
 	logutil.Info(
 		"smShard closed",
@@ -210,21 +296,49 @@ func (ss *smShard) Close() error {
 	return nil
 }
 
+// Drain 等待进行中的rb结束，并阻止新的rb开启，ctx超时返回error，调用方可以通过 Close 中断rb
+func (ss *smShard) Drain(ctx context.Context) error {
+	ss.mu.Lock()
+	ss.draining = true
+	donec := ss.balanceDone
+	ss.mu.Unlock()
+	if donec == nil {
+		return nil
+	}
+
+	select {
+	case <-donec:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // 1 smContainer 的增加/减少是优先级最高，目前可能涉及大量shard move
 // 2 smShard 被漏掉作为container检测的补充，最后校验，这种情况只涉及到漏掉的shard任务下发下去
 func (ss *smShard) balanceChecker(ctx context.Context) error {
 	ss.mu.Lock()
-	defer ss.mu.Unlock()
-
 	// rb中，没必要再次rb
 	// 当前进行的rb要根据container存活现状（方法调用时刻的一个切面）计算策略
 	if ss.balancing {
+		ss.mu.Unlock()
 		logutil.Info("balancing", zap.String("service", ss.service))
 		return nil
 	}
+	if ss.draining {
+		ss.mu.Unlock()
+		logutil.Info("draining, skip rb", zap.String("service", ss.service))
+		return nil
+	}
 	ss.balancing = true
+	ss.balanceDone = make(chan struct{})
+	ss.mu.Unlock()
 	defer func() {
+		ss.mu.Lock()
 		ss.balancing = false
+		close(ss.balanceDone)
+		ss.balanceDone = nil
+		ss.mu.Unlock()
 	}()
 
 	// 判断lease是否过期,如果lease过期,需要触发一次rb来更新lease
@@ -262,6 +376,18 @@ func (ss *smShard) balanceChecker(ctx context.Context) error {
 
 	// 现有存活containers
 	etcdHbContainerIdAndAny := ss.mpr.AliveContainers()
+	// 被摘除的container不参与rb，其上的shard会因为不在workerGroup的containers中被删除
+	drained, err := ss.getDrainedContainers()
+	if err != nil {
+		return err
+	}
+	for container := range drained {
+		delete(etcdHbContainerIdAndAny, container)
+	}
+	// 优雅退出的container和被摘除的container一样处理
+	for container := range ss.mpr.LeavingContainers() {
+		delete(etcdHbContainerIdAndAny, container)
+	}
 	// 没有存活的container，不需要做shard移动
 	if len(etcdHbContainerIdAndAny) == 0 {
 		logutil.Info(
@@ -274,7 +400,6 @@ func (ss *smShard) balanceChecker(ctx context.Context) error {
 	// 获取当前所有shard配置
 	var (
 		etcdShardIdAndAny ArmorMap
-		err               error
 
 		// 针对特定service，区分group做rb，允许同一service可以划分多个小的业务场景
 		groups = newBalanceWorkerGroupManager()
@@ -339,7 +464,11 @@ func (ss *smShard) balanceChecker(ctx context.Context) error {
 
 	// shard被清除的场景，从rebalance方法中提前到这里，应对完全不配置shard，且sdk本地存活的场景
 	// 提取需要被移除的shard
-	var deleting moveActionList
+	var (
+		deleting moveActionList
+		// updating 只有Task变化的shard，不需要rb，直接下发给当前所在container
+		updating moveActionList
+	)
 	for hbShardId, value := range etcdHbShardIdAndValue {
 		// shard配置不存在，需要删除
 		spec, ok := shardIdAndShardSpec[hbShardId]
@@ -386,9 +515,39 @@ func (ss *smShard) balanceChecker(ctx context.Context) error {
 				continue
 			}
 		}
+		if value.taskHash != storage.TaskHash(spec.Task) {
+			updateSpec := *spec
+			updateSpec.Id = hbShardId
+			updateSpec.Lease = &storage.Lease{ID: ss.guardLeaseID, Expire: math.MaxInt64 - 30}
+			updating = append(
+				updating,
+				&moveAction{
+					Service:        ss.service,
+					ShardId:        hbShardId,
+					UpdateEndpoint: value.curContainerId,
+					Spec:           &updateSpec,
+				},
+			)
+		}
 		groups.addHbShard(hbShardId, value.curContainerId, spec.Group, spec.WorkerGroup)
 	}
 
+	if len(updating) > 0 {
+		logutil.Info(
+			"shard updating",
+			zap.String("service", ss.service),
+			zap.Reflect("updating", updating),
+		)
+		if err := ss.dispatchMALs(updating); err != nil {
+			logutil.Error(
+				"dispatchMALs error",
+				zap.String("service", ss.service),
+				zap.Reflect("mal", updating),
+				zap.Error(err),
+			)
+		}
+	}
+
 	if len(deleting) > 0 {
 		allShardMoves = append(allShardMoves, deleting...)
 		logutil.Info(
@@ -408,31 +567,15 @@ func (ss *smShard) balanceChecker(ctx context.Context) error {
 	}
 
 	// 增加workerGroup粒度阈值限制，防止单进程过载导致雪崩
-	//workerGroupShards := make(map[string]int)
-	//for shardId := range etcdShardIdAndAny {
-	//	workerGroupShards[shardIdAndShardSpec[shardId].WorkerGroup] = workerGroupShards[shardIdAndShardSpec[shardId].WorkerGroup] + 1
-	//}
-	//for wg, shardCnt := range workerGroupShards {
-	//	maxHold := ss.maxHold(len(workerGroupAndContainers[wg]), shardCnt)
-	//	if maxHold > ss.appSpec.MaxShardCount {
-	//		err := errors.New("MaxShardCount exceeded")
-	//		ss.lg.Error(
-	//			err.Error(),
-	//			zap.String("service", ss.service),
-	//			zap.String("workerGroup", wg),
-	//			zap.Int("maxHold", maxHold),
-	//			zap.Int("containerCnt", len(workerGroupAndContainers[wg])),
-	//			zap.Int("shardCnt", shardCnt),
-	//			zap.Error(err),
-	//		)
-	//		// 一个资源组的分配超过最大限制，不影响其他资源组的分配
-	//		delete(workerGroupShards, wg)
-	//	}
-	//}
+	overloaded := ss.overloadedWorkerGroups(etcdShardIdAndAny, shardIdAndShardSpec, workerGroupAndContainers)
 
 	// 现存shard的分配
 	for groupkey, bg := range groups.balancerGroup {
 		group, wGroup := getGroupAndWorkerGroupByKey(groupkey)
+		// 一个资源组的分配超过最大限制，不影响其他资源组的分配
+		if _, ok := overloaded[wGroup]; ok {
+			continue
+		}
 		hbContainerIds := workerGroupAndContainers[wGroup].KeyList()
 		fixShardIds := bg.fixShardIdAndManualContainerId.KeyList()
 		hbShardIds := bg.hbShardIdAndContainerId.KeyList()
@@ -465,7 +608,7 @@ func (ss *smShard) balanceChecker(ctx context.Context) error {
 			zap.Reflect("hbShardIds", hbShardIds),
 		)
 
-		shardMoves := ss.extractShardMoves(bg.fixShardIdAndManualContainerId, workerGroupAndContainers[wGroup], bg.hbShardIdAndContainerId, shardIdAndShardSpec)
+		shardMoves := ss.extractShardMoves(bg.fixShardIdAndManualContainerId, workerGroupAndContainers[wGroup], bg.hbShardIdAndContainerId, etcdHbShardIdAndValue, shardIdAndShardSpec)
 		if len(shardMoves) > 0 {
 			allShardMoves = append(allShardMoves, shardMoves...)
 			continue
@@ -556,6 +699,10 @@ func (ss *smShard) rb(shardMoves moveActionList) error {
 	// A2, creates the bridge lease, delays for Slicelets to acquire the bridge lease for reading, and only then does it
 	// recall and rewrite the guard lease.
 	commonutil.SleepCanClose(defaultGuardLeaseTimeout*time.Second, ss.closeCh)
+	if ss.closed() {
+		// leader放弃或者shard关闭，中断rb，由新的leader重新计算
+		return errors.Wrap(commonutil.ErrClosing, "")
+	}
 	logutil.Info(
 		"old guard expired",
 		zap.Int64("oldGuardLease", int64(ss.guardLeaseID)),
@@ -595,6 +742,9 @@ func (ss *smShard) rb(shardMoves moveActionList) error {
 		zap.Reflect("newGuardLease", guardLease),
 	)
 	commonutil.SleepCanClose(defaultGuardLeaseTimeout*time.Second, ss.closeCh)
+	if ss.closed() {
+		return errors.Wrap(commonutil.ErrClosing, "")
+	}
 	logutil.Info(
 		"bridge expired",
 		zap.String("guardPfx", guardPfx),
@@ -653,6 +803,16 @@ func (ss *smShard) rb(shardMoves moveActionList) error {
 	return nil
 }
 
+// closed 判断 smShard 是否已经被关闭
+func (ss *smShard) closed() bool {
+	select {
+	case <-ss.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
 // leaseKeepAlive
 // proposal https://github.com/entertainment-venue/sm/wiki/%5Bproposal%5D%E4%BD%BF%E7%94%A8clientv3%E4%B8%AD%E7%9A%84lease%E7%9A%84keepalive%E6%9B%BF%E4%BB%A3guardKeepaliver%E6%9C%BA%E5%88%B6
 func (ss *smShard) leaseKeepAlive(leaseID clientv3.LeaseID, leaseTimeout time.Duration) {
@@ -742,11 +902,14 @@ func (ss *smShard) changed(a []string, b []string) bool {
 	return !reflect.DeepEqual(a, b)
 }
 
-// 只负责shard移动的场景，删除在balanceChecker中处理
+// 只负责shard移动的场景，删除在balanceChecker中处理，
+// hbShards 是心跳上报的shard状态和负载，container超出maxHold时优先移动不健康和负载低的shard，
+// 待分配的shard优先放到负载低的container
 func (ss *smShard) extractShardMoves(
 	fixShardIdAndManualContainerId ArmorMap,
 	hbContainerIdAndAny ArmorMap,
 	hbShardIdAndContainerId ArmorMap,
+	hbShards map[string]*temporary,
 	shardIdAndShardSpec map[string]*storage.ShardSpec) moveActionList {
 	// 保证shard在hb中上报的container和存活container一致
 	containerIdAndHbShardIds := hbShardIdAndContainerId.SwapKV()
@@ -821,7 +984,13 @@ func (ss *smShard) extractShardMoves(
 			continue
 		}
 
-		br.put(currentContainerId, fixShardId, false)
+		bs := br.put(currentContainerId, fixShardId, false)
+		if t, ok := hbShards[fixShardId]; ok {
+			bs.state = t.state
+			if t.load != nil {
+				bs.cost = t.load.Cost
+			}
+		}
 	}
 
 	// 处理新增container
@@ -845,11 +1014,7 @@ func (ss *smShard) extractShardMoves(
 			return
 		}
 
-		for _, bs := range bc.shards {
-			// 不能变动的shard
-			if bs.isManual {
-				continue
-			}
+		for _, bs := range bc.movable() {
 			dropFroms[bs.id] = bc.id
 			delete(bc.shards, bs.id)
 			dropCnt--
@@ -906,7 +1071,7 @@ func (ss *smShard) extractShardMoves(
 			}
 			adding = adding[idx:]
 		}
-		br.forEach(add)
+		br.forEachByCost(add)
 	}
 
 	logutil.Info(
@@ -920,6 +1085,39 @@ func (ss *smShard) extractShardMoves(
 	return mals
 }
 
+// overloadedWorkerGroups 平均分配后单container持有的shard超过 MaxShardCount 的workerGroup，
+// MaxShardCount 通过spec修改后在下一次rb生效
+func (ss *smShard) overloadedWorkerGroups(etcdShardIdAndAny ArmorMap, shardIdAndShardSpec map[string]*storage.ShardSpec, workerGroupAndContainers map[string]ArmorMap) map[string]struct{} {
+	ss.specMu.Lock()
+	maxShardCount := ss.appSpec.MaxShardCount
+	ss.specMu.Unlock()
+
+	workerGroupShards := make(map[string]int)
+	for shardId := range etcdShardIdAndAny {
+		workerGroupShards[shardIdAndShardSpec[shardId].WorkerGroup]++
+	}
+	r := make(map[string]struct{})
+	for wg, shardCnt := range workerGroupShards {
+		maxHold := ss.maxHold(len(workerGroupAndContainers[wg]), shardCnt)
+		if maxShardCount <= 0 || maxHold <= maxShardCount {
+			continue
+		}
+		err := errors.New("MaxShardCount exceeded")
+		logutil.Error(
+			err.Error(),
+			zap.String("service", ss.service),
+			zap.String("workerGroup", wg),
+			zap.Int("maxHold", maxHold),
+			zap.Int("maxShardCount", maxShardCount),
+			zap.Int("containerCnt", len(workerGroupAndContainers[wg])),
+			zap.Int("shardCnt", shardCnt),
+			zap.Error(err),
+		)
+		r[wg] = struct{}{}
+	}
+	return r
+}
+
 func (ss *smShard) maxHold(containerCnt, shardCnt int) int {
 	if containerCnt == 0 {
 		// 不做过滤
@@ -979,6 +1177,26 @@ func (ss *smShard) getHbWorkerGroupAndContainers(hbContainers ArmorMap) (map[str
 	return wgc, nil
 }
 
+// 获取被摘除的containers
+func (ss *smShard) getDrainedContainers() (ArmorMap, error) {
+	pfx := ss.container.nodeManager.DrainDir(ss.service)
+	kvs, err := ss.container.Client.GetKVs(context.TODO(), pfx)
+	if err != nil {
+		logutil.Error(
+			"GetKVs error",
+			zap.String("service", ss.service),
+			zap.String("pfx", pfx),
+			zap.Error(err),
+		)
+		return nil, errors.Wrap(err, "")
+	}
+	r := make(ArmorMap)
+	for container := range kvs {
+		r[container] = ""
+	}
+	return r, nil
+}
+
 func ErrLog(err error) {
 	logutil.Info("error happen %+v", zap.Error(err))
 }
@@ -15,8 +15,11 @@
 package smserver
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/entertainment-venue/sm/pkg/apputil/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -198,7 +201,116 @@ func (suite *ShardTestSuite) TestRB() {
 	}
 
 	for _, tt := range tests {
-		r := suite.shard.extractShardMoves(tt.fixShardIdAndManualContainerId, tt.hbContainerIdAndAny, tt.hbShardIdAndContainerId, nil)
+		r := suite.shard.extractShardMoves(tt.fixShardIdAndManualContainerId, tt.hbContainerIdAndAny, tt.hbShardIdAndContainerId, nil, nil)
 		assert.Equal(suite.T(), r, tt.expect)
 	}
 }
+
+func (suite *ShardTestSuite) TestRB_state() {
+	fixShardIdAndManualContainerId := ArmorMap{"s1": "", "s2": "", "s3": "", "s4": ""}
+	hbContainerIdAndAny := ArmorMap{"c1": "", "c2": ""}
+	hbShardIdAndContainerId := ArmorMap{"s1": "c1", "s2": "c1", "s3": "c1", "s4": "c1"}
+	hbShards := map[string]*temporary{
+		"s1": {curContainerId: "c1", state: storage.ShardStateServing},
+		"s2": {curContainerId: "c1", state: storage.ShardStateStarting},
+		"s3": {curContainerId: "c1", state: storage.ShardStateServing},
+		"s4": {curContainerId: "c1", state: storage.ShardStateFailed},
+	}
+
+	// 优先移动failed和还在加载的shard，serving的shard保持不动
+	r := suite.shard.extractShardMoves(fixShardIdAndManualContainerId, hbContainerIdAndAny, hbShardIdAndContainerId, hbShards, nil)
+	var moved []string
+	for _, ma := range r {
+		assert.Equal(suite.T(), "c1", ma.DropEndpoint)
+		assert.Equal(suite.T(), "c2", ma.AddEndpoint)
+		moved = append(moved, ma.ShardId)
+	}
+	assert.ElementsMatch(suite.T(), []string{"s2", "s4"}, moved)
+}
+
+func (suite *ShardTestSuite) TestRB_load() {
+	fixShardIdAndManualContainerId := ArmorMap{"s1": "", "s2": "", "s3": "", "s4": ""}
+	hbContainerIdAndAny := ArmorMap{"c1": "", "c2": "", "c3": ""}
+	hbShardIdAndContainerId := ArmorMap{"s1": "c1", "s2": "c1", "s3": "c1", "s4": "c2"}
+	hbShards := map[string]*temporary{
+		"s1": {curContainerId: "c1", load: &storage.ShardLoad{Cost: 3}},
+		"s2": {curContainerId: "c1", load: &storage.ShardLoad{Cost: 1}},
+		"s3": {curContainerId: "c1", load: &storage.ShardLoad{Cost: 2}},
+		"s4": {curContainerId: "c2", load: &storage.ShardLoad{Cost: 100}},
+	}
+
+	// 移动负载最低的shard，放到负载最低的container
+	r := suite.shard.extractShardMoves(fixShardIdAndManualContainerId, hbContainerIdAndAny, hbShardIdAndContainerId, hbShards, nil)
+	assert.Equal(suite.T(), moveActionList{
+		&moveAction{Service: suite.shard.service, ShardId: "s2", DropEndpoint: "c1", AddEndpoint: "c3"},
+	}, r)
+}
+
+func (suite *ShardTestSuite) TestDrain_balancing() {
+	suite.shard.balancing = true
+	suite.shard.balanceDone = make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	err := suite.shard.Drain(ctx)
+	assert.Equal(suite.T(), err, context.DeadlineExceeded)
+
+	// rb结束
+	suite.shard.balancing = false
+	close(suite.shard.balanceDone)
+	err = suite.shard.Drain(context.TODO())
+	assert.Nil(suite.T(), err)
+	suite.shard.mu.Lock()
+	assert.True(suite.T(), suite.shard.draining)
+	suite.shard.mu.Unlock()
+
+	err = suite.shard.balanceChecker(context.TODO())
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *ShardTestSuite) TestUpdateAppSpec() {
+	suite.shard.appSpec = &smAppSpec{Service: suite.shard.service, MaxShardCount: 1}
+	suite.shard.mpr = &mapper{maxRecoveryTime: defaultMaxRecoveryTime}
+	suite.shard.operator = &operator{}
+
+	spec := smAppSpec{Service: suite.shard.service, MaxShardCount: 5, MaxRecoveryTime: 3, Receiver: receiverGRPC}
+	err := suite.shard.updateAppSpec([]byte(spec.String()))
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 5, suite.shard.appSpec.MaxShardCount)
+	assert.Equal(suite.T(), 3*time.Second, suite.shard.mpr.maxRecoveryTime)
+	assert.Equal(suite.T(), receiverGRPC, suite.shard.operator.getReceiverType())
+
+	// 字段删除后恢复默认值
+	spec = smAppSpec{Service: suite.shard.service}
+	err = suite.shard.updateAppSpec([]byte(spec.String()))
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), defaultMaxShardCount, suite.shard.appSpec.MaxShardCount)
+	assert.Equal(suite.T(), defaultMaxRecoveryTime, suite.shard.mpr.maxRecoveryTime)
+	assert.Equal(suite.T(), "", suite.shard.operator.getReceiverType())
+}
+
+func (suite *ShardTestSuite) TestOverloadedWorkerGroups() {
+	suite.shard.appSpec = &smAppSpec{Service: suite.shard.service, MaxShardCount: defaultMaxShardCount}
+	suite.shard.mpr = &mapper{maxRecoveryTime: defaultMaxRecoveryTime}
+	suite.shard.operator = &operator{}
+
+	etcdShardIdAndAny := ArmorMap{"s1": "", "s2": "", "s3": ""}
+	shardIdAndShardSpec := map[string]*storage.ShardSpec{
+		"s1": {WorkerGroup: "g1"},
+		"s2": {WorkerGroup: "g1"},
+		"s3": {WorkerGroup: "g2"},
+	}
+	workerGroupAndContainers := map[string]ArmorMap{
+		"g1": {"c1": ""},
+		"g2": {"c2": ""},
+	}
+	assert.Empty(suite.T(), suite.shard.overloadedWorkerGroups(etcdShardIdAndAny, shardIdAndShardSpec, workerGroupAndContainers))
+
+	// spec修改后立即生效
+	spec := smAppSpec{Service: suite.shard.service, MaxShardCount: 1}
+	assert.Nil(suite.T(), suite.shard.updateAppSpec([]byte(spec.String())))
+	assert.Equal(
+		suite.T(),
+		map[string]struct{}{"g1": {}},
+		suite.shard.overloadedWorkerGroups(etcdShardIdAndAny, shardIdAndShardSpec, workerGroupAndContainers),
+	)
+}
@@ -0,0 +1,237 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/entertainment-venue/sm/pkg/commonutil"
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+	"github.com/entertainment-venue/sm/pkg/logutil"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// standbyMappers 每个sm节点为所有被管理的service维护只读的mapper，
+// 新的leader或者接手service的节点可以直接使用，心跳状态和container恢复的计时不会因为切换丢失
+type standbyMappers struct {
+	container *smContainer
+
+	mu sync.Mutex
+	// mappers service和standby mapper的映射
+	mappers map[string]*mapper
+	// attached 已经交给 smShard 使用的service
+	attached map[string]struct{}
+
+	// stopper 管理spec的watch
+	stopper *commonutil.GoroutineStopper
+}
+
+func newStandbyMappers(container *smContainer) (*standbyMappers, error) {
+	sm := standbyMappers{
+		container: container,
+		mappers:   make(map[string]*mapper),
+		attached:  make(map[string]struct{}),
+		stopper:   &commonutil.GoroutineStopper{},
+	}
+
+	// /sm/app/foo.bar/service/ 下的spec节点代表被管理的service
+	pfx := container.nodeManager.ServicePath("") + "/"
+	resp, err := container.Client.Get(context.TODO(), pfx, clientv3.WithPrefix())
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	for _, kv := range resp.Kvs {
+		sm.update(string(kv.Key), kv.Value)
+	}
+
+	sm.stopper.Wrap(
+		func(ctx context.Context) {
+			etcdutil.WatchLoopWithResync(
+				ctx,
+				container.Client,
+				pfx,
+				resp.Header.Revision+1,
+				func(ctx context.Context, ev *clientv3.Event) error {
+					if ev.Type == clientv3.EventTypeDelete {
+						sm.remove(sm.parseService(string(ev.Kv.Key)))
+						return nil
+					}
+					sm.update(string(ev.Kv.Key), ev.Kv.Value)
+					return nil
+				},
+				sm.resync,
+			)
+		},
+	)
+	return &sm, nil
+}
+
+// parseService 从spec节点中提取service，非spec节点返回空
+func (sm *standbyMappers) parseService(key string) string {
+	pfx := sm.container.nodeManager.ServicePath("") + "/"
+	arr := strings.Split(strings.TrimPrefix(key, pfx), "/")
+	if len(arr) != 2 || arr[1] != "spec" {
+		return ""
+	}
+	return arr[0]
+}
+
+func (sm *standbyMappers) update(key string, value []byte) {
+	service := sm.parseService(key)
+	if service == "" {
+		return
+	}
+	var appSpec smAppSpec
+	if err := json.Unmarshal(value, &appSpec); err != nil {
+		logutil.Warn(
+			"unmarshal spec error",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return
+	}
+	// 和 newSMShard 保持一致，watch的service以spec所在的路径为准
+	appSpec.Service = service
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if mpr, ok := sm.mappers[service]; ok {
+		if _, ok := sm.attached[service]; !ok {
+			mpr.mu.Lock()
+			mpr.maxRecoveryTime = recoveryTime(&appSpec)
+			mpr.mu.Unlock()
+		}
+		return
+	}
+
+	mpr, err := newMapper(sm.container, &appSpec, nil)
+	if err != nil {
+		logutil.Error(
+			"newMapper error",
+			zap.String("service", service),
+			zap.Error(err),
+		)
+		return
+	}
+	sm.mappers[service] = mpr
+}
+
+// resync spec的watch丢失事件后，按照快照补齐或者移除standby mapper
+func (sm *standbyMappers) resync(_ context.Context, resp *clientv3.GetResponse) error {
+	present := make(map[string]struct{})
+	for _, kv := range resp.Kvs {
+		if service := sm.parseService(string(kv.Key)); service != "" {
+			present[service] = struct{}{}
+		}
+		sm.update(string(kv.Key), kv.Value)
+	}
+
+	var removed []string
+	sm.mu.Lock()
+	for service := range sm.mappers {
+		if _, ok := present[service]; !ok {
+			removed = append(removed, service)
+		}
+	}
+	sm.mu.Unlock()
+	for _, service := range removed {
+		sm.remove(service)
+	}
+	return nil
+}
+
+func (sm *standbyMappers) remove(service string) {
+	if service == "" {
+		return
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	mpr, ok := sm.mappers[service]
+	if !ok {
+		return
+	}
+	delete(sm.mappers, service)
+	// 使用中的mapper在 release 时关闭
+	if _, ok := sm.attached[service]; ok {
+		delete(sm.attached, service)
+		return
+	}
+	mpr.Close()
+	logutil.Info("standby mapper removed", zap.String("service", service))
+}
+
+// acquire 获取service的standby mapper，不存在或者已经被使用返回nil
+func (sm *standbyMappers) acquire(shard *smShard, appSpec *smAppSpec) *mapper {
+	if sm == nil {
+		return nil
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	mpr, ok := sm.mappers[appSpec.Service]
+	if !ok {
+		return nil
+	}
+	if _, ok := sm.attached[appSpec.Service]; ok {
+		return nil
+	}
+	sm.attached[appSpec.Service] = struct{}{}
+	mpr.attach(shard, appSpec)
+
+	logutil.Info("standby mapper attached", zap.String("service", appSpec.Service))
+	return mpr
+}
+
+// release smShard 关闭时归还mapper，不是standby mapper的返回false，由调用方关闭
+func (sm *standbyMappers) release(service string, mpr *mapper) bool {
+	if sm == nil {
+		return false
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.mappers[service] != mpr {
+		return false
+	}
+	delete(sm.attached, service)
+	mpr.detach()
+
+	logutil.Info("standby mapper detached", zap.String("service", service))
+	return true
+}
+
+func (sm *standbyMappers) Close() {
+	sm.stopper.Close()
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for service, mpr := range sm.mappers {
+		if _, ok := sm.attached[service]; !ok {
+			mpr.Close()
+		}
+	}
+	sm.mappers = make(map[string]*mapper)
+	sm.attached = make(map[string]struct{})
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 The entertainment-venue Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smserver
+
+import (
+	"testing"
+
+	"github.com/entertainment-venue/sm/pkg/etcdutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestStandby(t *testing.T) {
+	suite.Run(t, new(StandbyTestSuite))
+}
+
+type StandbyTestSuite struct {
+	suite.Suite
+
+	standby *standbyMappers
+}
+
+func (suite *StandbyTestSuite) SetupTest() {
+	suite.standby = &standbyMappers{
+		container: &smContainer{nodeManager: newNodeManager("foo", etcdutil.NewPathBuilder(""))},
+		mappers: map[string]*mapper{
+			"bar": {
+				appSpec:        &smAppSpec{Service: "bar"},
+				containerState: newMapperState(),
+				shardState:     newMapperState(),
+			},
+		},
+		attached: make(map[string]struct{}),
+	}
+}
+
+func (suite *StandbyTestSuite) TestParseService() {
+	var tests = []struct {
+		key    string
+		expect string
+	}{
+		{
+			key:    "/sm/app/foo/service/bar/spec",
+			expect: "bar",
+		},
+		{
+			key:    "/sm/app/foo/service/bar/shard/s1",
+			expect: "",
+		},
+		{
+			key:    "/sm/app/foo/service/bar/workerpool",
+			expect: "",
+		},
+	}
+	for _, tt := range tests {
+		actual := suite.standby.parseService(tt.key)
+		assert.Equal(suite.T(), tt.expect, actual)
+	}
+}
+
+func (suite *StandbyTestSuite) TestAcquire_notExist() {
+	mpr := suite.standby.acquire(&smShard{}, &smAppSpec{Service: "baz"})
+	assert.Nil(suite.T(), mpr)
+}
+
+func (suite *StandbyTestSuite) TestAcquire_attached() {
+	ss := &smShard{}
+	mpr := suite.standby.acquire(ss, &smAppSpec{Service: "bar"})
+	assert.NotNil(suite.T(), mpr)
+	assert.Equal(suite.T(), ss, mpr.shard)
+
+	// 同一个service的mapper只能被一个smShard使用
+	assert.Nil(suite.T(), suite.standby.acquire(&smShard{}, &smAppSpec{Service: "bar"}))
+
+	assert.True(suite.T(), suite.standby.release("bar", mpr))
+	assert.Nil(suite.T(), mpr.shard)
+	assert.NotNil(suite.T(), suite.standby.acquire(ss, &smAppSpec{Service: "bar"}))
+}
+
+func (suite *StandbyTestSuite) TestRelease_notStandby() {
+	assert.False(suite.T(), suite.standby.release("bar", &mapper{}))
+
+	var standby *standbyMappers
+	assert.False(suite.T(), standby.release("bar", &mapper{}))
+	assert.Nil(suite.T(), standby.acquire(&smShard{}, &smAppSpec{Service: "bar"}))
+}